@@ -7,10 +7,13 @@ import (
 	"os"
 	"time"
 
+	"github.com/zrik/agent/appagent/internal/source"
 	"github.com/zrik/agent/appagent/internal/source/stv"
 	"github.com/zrik/agent/appagent/pkg/config"
+	"github.com/zrik/agent/appagent/pkg/http"
 	"github.com/zrik/agent/appagent/pkg/logger"
 	"github.com/zrik/agent/appagent/pkg/rabbitmq"
+	"github.com/zrik/protocol"
 )
 
 func main() {
@@ -56,24 +59,10 @@ func main() {
 		}
 	}()
 
-	// Register source clients
-	websites, err := service.GetHTTPService().GetWebsiteService().GetWebsites(context.Background())
-	if err != nil {
-		logger.Fatal().Err(err).Msg("Error getting websites")
-	}
-
-	for _, website := range websites {
-		switch website.ScriptName {
-		case string(rabbitmq.SourceTypeSangTacViet):
-			stvClient := stv.New(website.Username, website.Password, website.URL)
-			service.RegisterSourceClient(rabbitmq.SourceTypeSangTacViet, stvClient)
-		case string(rabbitmq.SourceTypeMetruyenchu):
-			// metruyenchuClient := &metruyenchu.Metruyenchu{...}
-			// service.RegisterSourceClient(rabbitmq.SourceTypeMetruyenchu, metruyenchuClient)
-		case string(rabbitmq.SourceTypeWikiDich):
-			// wikiDichClient := &wikidich.WikiDich{...}
-			// service.RegisterSourceClient(rabbitmq.SourceTypeWikiDich, wikiDichClient)
-		}
+	// Register source clients, they are kept in sync with the control API while running
+	service.SetSourceClientFactory(sourceClientFactory(cfg))
+	if err := service.SyncWebsites(context.Background()); err != nil {
+		logger.Fatal().Err(err).Msg("Error syncing websites")
 	}
 
 	// Start the service
@@ -82,3 +71,18 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// sourceClientFactory returns the factory creating the source client for a website based on its script name
+func sourceClientFactory(cfg *config.Config) rabbitmq.SourceClientFactory {
+	return func(website http.Website) (source.WebSource, bool) {
+		switch website.ScriptName {
+		case string(protocol.SourceTypeSangTacViet):
+			return stv.New(website.Username, website.Password, website.URL, cfg.MaxChapterPages), true
+		case string(protocol.SourceTypeMetruyenchu):
+			// return &metruyenchu.Metruyenchu{...}, true
+		case string(protocol.SourceTypeWikiDich):
+			// return &wikidich.WikiDich{...}, true
+		}
+		return nil, false
+	}
+}
@@ -5,9 +5,14 @@ import (
 	"github.com/zrik/agent/appagent/pkg/spider"
 )
 
+// WebSource extracts books, chapters and sessions from a website. A client holding
+// resources implements io.Closer, it is closed once replaced or removed by a website
+// sync and no running task uses it anymore.
 type WebSource interface {
 	ExtractSourceSession(browser *rod.Browser, spider spider.TaskSpider) (any, error)
 	ExtractSession(url string, page *rod.Page, spider spider.TaskSpider) (any, error)
 	ExtractChapter(url string, page *rod.Page, spider spider.TaskSpider) (any, error)
-	ExtractBookInfo(url string, page *rod.Page, spider spider.TaskSpider) (any, error)
+	// ExtractBookInfo extracts a book, the cover is only downloaded when its URL isn't
+	// storedCoverURL, the cover the control API already has
+	ExtractBookInfo(url, storedCoverURL string, page *rod.Page, spider spider.TaskSpider) (any, error)
 }
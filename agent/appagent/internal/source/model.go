@@ -1,18 +1,25 @@
 package source
 
-type Book struct {
-	BookUrl      string
-	BookId       string
-	BookName     string
-	BookImageUrl string
-	AuthorName   string
-	Chapters     []Chapter
-	BookHost     string
-}
+import "github.com/zrik/protocol"
 
-type Chapter struct {
-	ChapterId     string
-	ChapterName   string
-	ChapterUrl    string
-	ChapterNumber int
-}
+// Book statuses reported by sources
+const (
+	BookStatusOngoing   = "ongoing"
+	BookStatusCompleted = "completed"
+)
+
+// The results of book and chapter tasks are the shared protocol types, so what sources
+// extract is what the control API reads
+type (
+	Book           = protocol.Book
+	Chapter        = protocol.Chapter
+	ChapterContent = protocol.ChapterContent
+	Image          = protocol.Image
+)
+
+// Content formats of ChapterContent.RichContent
+const (
+	ContentFormatText     = protocol.ContentFormatText
+	ContentFormatHTML     = protocol.ContentFormatHTML
+	ContentFormatMarkdown = protocol.ContentFormatMarkdown
+)
@@ -0,0 +1,136 @@
+package source
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/go-rod/rod"
+	"github.com/zrik/agent/appagent/pkg/logger"
+	"github.com/zrik/agent/appagent/pkg/spider"
+)
+
+// DefaultMaxChapterPages is the page limit used when none is configured
+const DefaultMaxChapterPages = 20
+
+// ChapterPageExtractor is implemented by sources whose chapters can span several pages
+type ChapterPageExtractor interface {
+	// ExtractChapterPage extracts the chapter page loaded in page and returns the URL
+	// of the next page of the same chapter, or an empty string on the last page
+	ExtractChapterPage(url string, page *rod.Page, spider spider.TaskSpider) (ChapterContent, string, error)
+}
+
+// ExtractChapterPages extracts a chapter starting at the page already loaded in page and
+// follows the next-page links in the same tab, so every page shares the session, until
+// the last page or maxPages pages. Links to another host or to a page already visited are
+// not followed. The pages are merged in order.
+func ExtractChapterPages(chapterUrl string, page *rod.Page, hSpider spider.TaskSpider, extractor ChapterPageExtractor, maxPages int) (ChapterContent, error) {
+	extract := func(url string) (ChapterContent, string, error) {
+		return extractor.ExtractChapterPage(url, page, hSpider)
+	}
+	navigate := func(url string) error {
+		if err := page.Navigate(url); err != nil {
+			return err
+		}
+		return page.WaitLoad()
+	}
+	return followChapterPages(chapterUrl, maxPages, extract, navigate)
+}
+
+// followChapterPages runs the page loop of ExtractChapterPages: extract reads the current
+// page and returns the next-page link, navigate loads the next page
+func followChapterPages(chapterUrl string, maxPages int, extract func(url string) (ChapterContent, string, error), navigate func(url string) error) (ChapterContent, error) {
+	if maxPages <= 0 {
+		maxPages = DefaultMaxChapterPages
+	}
+
+	var parts []ChapterContent
+	visited := map[string]bool{}
+	current := chapterUrl
+	for {
+		visited[current] = true
+
+		part, next, err := extract(current)
+		if err != nil {
+			return ChapterContent{}, fmt.Errorf("failed to extract chapter page %d: %w", len(parts)+1, err)
+		}
+		parts = append(parts, part)
+
+		if next == "" {
+			break
+		}
+		if len(parts) >= maxPages {
+			logger.Warn().Str("url", chapterUrl).Int("max_pages", maxPages).Msg("Chapter page limit reached")
+			break
+		}
+
+		nextUrl, err := resolveNextPage(current, next)
+		if err != nil {
+			logger.Warn().Err(err).Str("url", current).Msg("Not following chapter next page")
+			break
+		}
+		if visited[nextUrl] {
+			break
+		}
+
+		if err := navigate(nextUrl); err != nil {
+			return ChapterContent{}, fmt.Errorf("failed to load chapter page %s: %w", nextUrl, err)
+		}
+		current = nextUrl
+	}
+
+	return MergeChapterPages(parts), nil
+}
+
+// resolveNextPage resolves a next-page link against the current page URL and
+// refuses links that leave the current host
+func resolveNextPage(current, next string) (string, error) {
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(next)
+	if err != nil {
+		return "", err
+	}
+
+	resolved := base.ResolveReference(ref)
+	resolved.Fragment = ""
+	if !strings.EqualFold(resolved.Host, base.Host) {
+		return "", fmt.Errorf("next page %s is on another host", resolved)
+	}
+	return resolved.String(), nil
+}
+
+// MergeChapterPages merges the pages of a chapter in order. The rich content is only kept
+// when every page has rich content in the same format.
+func MergeChapterPages(parts []ChapterContent) ChapterContent {
+	merged := ChapterContent{Pages: len(parts)}
+	if len(parts) == 0 {
+		return merged
+	}
+
+	texts := make([]string, 0, len(parts))
+	riches := make([]string, 0, len(parts))
+	format := parts[0].ContentFormat
+	for _, part := range parts {
+		if text := strings.TrimSpace(part.Content); text != "" {
+			texts = append(texts, text)
+		}
+		if part.RichContent == "" || part.ContentFormat != format {
+			format = ""
+		}
+		riches = append(riches, strings.TrimSpace(part.RichContent))
+	}
+
+	merged.Content = strings.Join(texts, "\n")
+	if format != "" {
+		sep := "\n"
+		if format == ContentFormatMarkdown {
+			sep = "\n\n"
+		}
+		merged.RichContent = strings.Join(riches, sep)
+		merged.ContentFormat = format
+	}
+	return merged
+}
@@ -0,0 +1,205 @@
+package source
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakePages is a chapter split across pages, keyed by URL, with the next-page link of each
+type fakePages struct {
+	pages     map[string]fakePage
+	extracted []string
+	navigated []string
+}
+
+type fakePage struct {
+	content ChapterContent
+	next    string
+}
+
+func (f *fakePages) extract(url string) (ChapterContent, string, error) {
+	f.extracted = append(f.extracted, url)
+	p, ok := f.pages[url]
+	if !ok {
+		return ChapterContent{}, "", errors.New("no page " + url)
+	}
+	return p.content, p.next, nil
+}
+
+func (f *fakePages) navigate(url string) error {
+	f.navigated = append(f.navigated, url)
+	return nil
+}
+
+func textPage(text, next string) fakePage {
+	return fakePage{content: ChapterContent{Content: text}, next: next}
+}
+
+func TestFollowChapterPages(t *testing.T) {
+	tests := []struct {
+		name     string
+		pages    map[string]fakePage
+		maxPages int
+		want     string
+		visited  []string
+	}{
+		{
+			name:    "single page",
+			pages:   map[string]fakePage{"https://a/c/1": textPage("one", "")},
+			want:    "one",
+			visited: []string{"https://a/c/1"},
+		},
+		{
+			name: "relative next links",
+			pages: map[string]fakePage{
+				"https://a/c/1":     textPage("one", "1?p=2"),
+				"https://a/c/1?p=2": textPage("two", "/c/1?p=3#top"),
+				"https://a/c/1?p=3": textPage("three", ""),
+			},
+			want:    "one\ntwo\nthree",
+			visited: []string{"https://a/c/1", "https://a/c/1?p=2", "https://a/c/1?p=3"},
+		},
+		{
+			name: "page limit",
+			pages: map[string]fakePage{
+				"https://a/c/1": textPage("one", "/c/2"),
+				"https://a/c/2": textPage("two", "/c/3"),
+				"https://a/c/3": textPage("three", ""),
+			},
+			maxPages: 2,
+			want:     "one\ntwo",
+			visited:  []string{"https://a/c/1", "https://a/c/2"},
+		},
+		{
+			name: "loop back to a visited page",
+			pages: map[string]fakePage{
+				"https://a/c/1": textPage("one", "/c/2"),
+				"https://a/c/2": textPage("two", "/c/1#again"),
+			},
+			want:    "one\ntwo",
+			visited: []string{"https://a/c/1", "https://a/c/2"},
+		},
+		{
+			name: "next page on another host",
+			pages: map[string]fakePage{
+				"https://a/c/1": textPage("one", "https://b/c/2"),
+			},
+			want:    "one",
+			visited: []string{"https://a/c/1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakePages{pages: tt.pages, navigated: []string{}}
+			got, err := followChapterPages("https://a/c/1", tt.maxPages, f.extract, f.navigate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Content != tt.want {
+				t.Errorf("content %q, want %q", got.Content, tt.want)
+			}
+			if got.Pages != len(tt.visited) {
+				t.Errorf("%d pages, want %d", got.Pages, len(tt.visited))
+			}
+			if !reflect.DeepEqual(f.extracted, tt.visited) {
+				t.Errorf("extracted %v, want %v", f.extracted, tt.visited)
+			}
+			if !reflect.DeepEqual(f.navigated, tt.visited[1:]) {
+				t.Errorf("navigated to %v, want %v", f.navigated, tt.visited[1:])
+			}
+		})
+	}
+}
+
+func TestFollowChapterPagesErrors(t *testing.T) {
+	f := &fakePages{pages: map[string]fakePage{"https://a/c/1": textPage("one", "/c/2")}}
+	if _, err := followChapterPages("https://a/c/1", 0, f.extract, f.navigate); err == nil {
+		t.Error("expected an error for a page failing to extract")
+	}
+
+	f = &fakePages{pages: map[string]fakePage{"https://a/c/1": textPage("one", "/c/2")}}
+	failing := func(string) error { return errors.New("timeout") }
+	if _, err := followChapterPages("https://a/c/1", 0, f.extract, failing); err == nil {
+		t.Error("expected an error for a page failing to load")
+	}
+}
+
+func TestMergeChapterPages(t *testing.T) {
+	html := func(text, rich string) ChapterContent {
+		return ChapterContent{Content: text, RichContent: rich, ContentFormat: ContentFormatHTML}
+	}
+
+	tests := []struct {
+		name  string
+		parts []ChapterContent
+		want  ChapterContent
+	}{
+		{name: "no pages", want: ChapterContent{}},
+		{
+			name:  "rich pages",
+			parts: []ChapterContent{html(" one ", "<p>one</p>"), html("two", "<p>two</p>\n")},
+			want:  ChapterContent{Content: "one\ntwo", RichContent: "<p>one</p>\n<p>two</p>", ContentFormat: ContentFormatHTML, Pages: 2},
+		},
+		{
+			name: "markdown pages",
+			parts: []ChapterContent{
+				{Content: "one", RichContent: "*one*", ContentFormat: ContentFormatMarkdown},
+				{Content: "two", RichContent: "*two*", ContentFormat: ContentFormatMarkdown},
+			},
+			want: ChapterContent{Content: "one\ntwo", RichContent: "*one*\n\n*two*", ContentFormat: ContentFormatMarkdown, Pages: 2},
+		},
+		{
+			name:  "page without rich content",
+			parts: []ChapterContent{html("one", "<p>one</p>"), {Content: "two"}},
+			want:  ChapterContent{Content: "one\ntwo", Pages: 2},
+		},
+		{
+			name: "mixed formats",
+			parts: []ChapterContent{
+				html("one", "<p>one</p>"),
+				{Content: "two", RichContent: "two", ContentFormat: ContentFormatMarkdown},
+			},
+			want: ChapterContent{Content: "one\ntwo", Pages: 2},
+		},
+		{
+			name:  "empty page",
+			parts: []ChapterContent{{Content: "one"}, {Content: "  "}, {Content: "three"}},
+			want:  ChapterContent{Content: "one\nthree", Pages: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeChapterPages(tt.parts); got != tt.want {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveNextPage(t *testing.T) {
+	tests := []struct {
+		current, next, want string
+		wantErr             bool
+	}{
+		{current: "https://a/c/1", next: "2", want: "https://a/c/2"},
+		{current: "https://a/c/1/", next: "../2/#content", want: "https://a/c/2/"},
+		{current: "https://a/c/1", next: "//A/c/2", want: "https://A/c/2"},
+		{current: "https://a/c/1", next: "https://b/c/2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveNextPage(tt.current, tt.next)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("resolveNextPage(%q, %q) = %q, want an error", tt.current, tt.next, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolveNextPage(%q, %q) = %q, %v, want %q", tt.current, tt.next, got, err, tt.want)
+		}
+	}
+}
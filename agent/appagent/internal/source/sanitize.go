@@ -0,0 +1,86 @@
+package source
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DefaultAllowedTags is the set of structural tags kept by SanitizeHTML
+var DefaultAllowedTags = map[string]bool{
+	"p": true, "br": true, "hr": true,
+	"em": true, "i": true, "strong": true, "b": true, "u": true, "s": true, "del": true,
+	"sup": true, "sub": true, "small": true,
+	"blockquote": true, "pre": true, "code": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true,
+}
+
+// droppedTags are removed together with their content
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "iframe": true,
+	"template": true, "button": true, "form": true, "input": true, "select": true, "textarea": true,
+}
+
+// voidTags have no closing tag
+var voidTags = map[string]bool{"br": true, "hr": true}
+
+// SanitizeHTML keeps only the allowed tags of an HTML fragment, without any attributes.
+// Tags that are not allowed are unwrapped so their text is kept, except for scripts,
+// styles and form controls which are removed entirely. Empty paragraphs are dropped.
+func SanitizeHTML(htmlContent string, allowed map[string]bool) (string, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(htmlContent), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		writeSanitized(&buf, n, allowed)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// writeSanitized writes the sanitized node n to buf
+func writeSanitized(buf *bytes.Buffer, n *html.Node, allowed map[string]bool) {
+	switch n.Type {
+	case html.TextNode:
+		buf.WriteString(html.EscapeString(n.Data))
+		return
+	case html.CommentNode, html.DoctypeNode:
+		return
+	case html.ElementNode:
+		if droppedTags[n.Data] {
+			return
+		}
+		if allowed[n.Data] {
+			if voidTags[n.Data] {
+				buf.WriteString("<" + n.Data + ">")
+				return
+			}
+
+			var inner bytes.Buffer
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				writeSanitized(&inner, c, allowed)
+			}
+			if n.Data == "p" && strings.TrimSpace(inner.String()) == "" {
+				return
+			}
+			buf.WriteString("<" + n.Data + ">")
+			buf.Write(inner.Bytes())
+			buf.WriteString("</" + n.Data + ">")
+			return
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeSanitized(buf, c, allowed)
+	}
+}
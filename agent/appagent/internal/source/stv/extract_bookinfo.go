@@ -5,11 +5,13 @@ import (
 	"strings"
 
 	"github.com/go-rod/rod"
+	"github.com/zrik/agent/appagent/internal/source"
+	"github.com/zrik/agent/appagent/pkg/logger"
 	"github.com/zrik/agent/appagent/pkg/spider"
 )
 
-func (s *Sangtacviet) ExtractBookInfo(url string, page *rod.Page, spider spider.TaskSpider) (any, error) {
-	_, err := AsHeadSpider(spider)
+func (s *Sangtacviet) ExtractBookInfo(url, storedCoverURL string, page *rod.Page, hSpider spider.TaskSpider) (any, error) {
+	_, err := AsHeadSpider(hSpider)
 	if err != nil {
 		return nil, fmt.Errorf("spider is not of type *spider.HeadSpider")
 	}
@@ -88,6 +90,18 @@ func (s *Sangtacviet) ExtractBookInfo(url string, page *rod.Page, spider spider.
 	}
 
 	bookInfo.Chapters = chapters
+
+	// Download the cover through the browser session unless the control API already has it,
+	// a missing cover is not an error
+	if bookInfo.BookImageUrl != "" && bookInfo.BookImageUrl != storedCoverURL {
+		data, contentType, err := spider.FetchResource(page, bookInfo.BookImageUrl)
+		if err != nil {
+			logger.Warn().Err(err).Str("url", bookInfo.BookImageUrl).Msg("Failed to download book cover")
+		} else {
+			bookInfo.BookImage = &source.Image{ContentType: contentType, Data: data}
+		}
+	}
+
 	bookInfoByte, err := ConvertToRawMessage(bookInfo)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal book info: %+v", err)
@@ -5,10 +5,22 @@ import (
 	"time"
 
 	"github.com/go-rod/rod"
+	"github.com/zrik/agent/appagent/internal/source"
 	"github.com/zrik/agent/appagent/pkg/logger"
 	"github.com/zrik/agent/appagent/pkg/spider"
 )
 
+// allowedChapterTags are the tags kept in the rich chapter content. Sangtacviet wraps
+// every translated phrase in an <i> tag, so italics carry no meaning there.
+var allowedChapterTags = func() map[string]bool {
+	tags := make(map[string]bool, len(source.DefaultAllowedTags))
+	for tag := range source.DefaultAllowedTags {
+		tags[tag] = true
+	}
+	delete(tags, "i")
+	return tags
+}()
+
 func (s *Sangtacviet) ExtractChapter(chapterUrl string, page *rod.Page, hSpider spider.TaskSpider) (any, error) {
 	_, err := AsHeadSpider(hSpider)
 	if err != nil {
@@ -16,6 +28,18 @@ func (s *Sangtacviet) ExtractChapter(chapterUrl string, page *rod.Page, hSpider
 	}
 	defer page.MustClose()
 
+	result, err := source.ExtractChapterPages(chapterUrl, page, hSpider, s, s.maxChapterPages)
+	if err != nil {
+		return nil, err
+	}
+
+	chapterBytes, _ := ConvertToRawMessage(result)
+	return chapterBytes, nil
+}
+
+// ExtractChapterPage extracts the chapter loaded in page. Sangtacviet serves a chapter
+// on a single page, so there is never a next page.
+func (s *Sangtacviet) ExtractChapterPage(chapterUrl string, page *rod.Page, hSpider spider.TaskSpider) (source.ChapterContent, string, error) {
 	hSpider.ApplySessionData(page)
 
 	page.MustWaitLoad()
@@ -46,10 +70,26 @@ func (s *Sangtacviet) ExtractChapter(chapterUrl string, page *rod.Page, hSpider
 	}
 
 	if chapterContent == nil {
-		return nil, fmt.Errorf("failed to locate chapter content")
+		return source.ChapterContent{}, "", fmt.Errorf("failed to locate chapter content")
 	}
 
-	result, _ := ExtractTextFromHTML(chapterContent.MustHTML())
-	chapterBytes, _ := ConvertToRawMessage(result)
-	return chapterBytes, nil
+	return parseChapterContent(chapterUrl, chapterContent.MustHTML()), "", nil
+}
+
+// parseChapterContent reads the HTML of the chapter content box into plain text and
+// sanitized rich content
+func parseChapterContent(chapterUrl, contentHTML string) source.ChapterContent {
+	text, _ := ExtractTextFromHTML(contentHTML)
+	result := source.ChapterContent{Content: text}
+
+	// Keep the paragraph structure next to the plain text, the plain text alone
+	// is still usable when sanitizing fails
+	if rich, err := source.SanitizeHTML(contentHTML, allowedChapterTags); err != nil {
+		logger.Warn().Err(err).Str("url", chapterUrl).Msg("Failed to sanitize chapter HTML")
+	} else if rich != "" {
+		result.RichContent = rich
+		result.ContentFormat = source.ContentFormatHTML
+	}
+
+	return result
 }
@@ -0,0 +1,102 @@
+package stv
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/zrik/agent/appagent/pkg/config"
+	"github.com/zrik/agent/appagent/pkg/spider"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+const testOrigin = "https://sangtacviet.app"
+
+// newReplaySpider returns a headless spider serving every request from testdata/fixtures
+func newReplaySpider(t *testing.T) *spider.HeadSpider {
+	t.Helper()
+
+	browserPath, ok := launcher.LookPath()
+	if !ok {
+		t.Skip("Chromium not found")
+	}
+
+	hs := spider.NewHeadSpider(true, &config.Config{
+		UserAgent:      []string{"crawler-test"},
+		BrowserTimeout: 60,
+	})
+	hs.SetBrowserPath(browserPath)
+	hs.SetHeadless(true)
+	hs.SetFixtureMode(spider.FixtureModeReplay, filepath.Join("testdata", "fixtures"))
+	t.Cleanup(hs.CloseBrowser)
+	return hs
+}
+
+// assertGolden compares the JSON result of an extractor with testdata/golden/name
+func assertGolden(t *testing.T, name string, result any) {
+	t.Helper()
+
+	got, ok := result.(json.RawMessage)
+	if !ok {
+		t.Fatalf("unexpected result type %T", result)
+	}
+
+	path := filepath.Join("testdata", "golden", name)
+	if *update {
+		var indented any
+		if err := json.Unmarshal(got, &indented); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := json.MarshalIndent(indented, "", "  ")
+		if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(want, &wantValue); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("%s mismatch\ngot:  %s\nwant: %s", name, got, want)
+	}
+}
+
+func TestExtractBookInfoGolden(t *testing.T) {
+	hs := newReplaySpider(t)
+	s := New("", "", testOrigin, 0)
+
+	result, err := hs.ProcessPageWithCallback(testOrigin+"/truyen/qidian/1/12345/", func(url string, page *rod.Page, hs spider.TaskSpider) (any, error) {
+		return s.ExtractBookInfo(url, "", page, hs)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "book.json", result)
+}
+
+func TestExtractChapterGolden(t *testing.T) {
+	hs := newReplaySpider(t)
+	s := New("", "", testOrigin, 0)
+
+	result, err := hs.ProcessPageWithCallback(testOrigin+"/truyen/qidian/1/12345/111/", s.ExtractChapter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "chapter.json", result)
+}
@@ -0,0 +1,130 @@
+package stv
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zrik/agent/appagent/internal/source"
+	"github.com/zrik/agent/appagent/pkg/spider"
+	"golang.org/x/net/html"
+)
+
+// The tests in this file parse the recorded responses directly, without a browser, so
+// they run where the golden tests skip for lack of Chromium
+
+// fixtureBody returns the recorded response body of url in the fixture of a task
+func fixtureBody(t *testing.T, taskURL, url string) []byte {
+	t.Helper()
+
+	dir := filepath.Join("testdata", "fixtures", spider.FixtureName(taskURL))
+	fixture, err := spider.LoadFixture(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range fixture.Entries {
+		if e.URL == url {
+			body, err := os.ReadFile(filepath.Join(dir, e.BodyFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return body
+		}
+	}
+	t.Fatalf("no response for %s in fixture %s", url, dir)
+	return nil
+}
+
+// findElement returns the first element under n with the tag and class, or nil
+func findElement(n *html.Node, tag, class string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		for _, a := range n.Attr {
+			if a.Key == "class" && strings.Contains(" "+a.Val+" ", " "+class+" ") {
+				return n
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag, class); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func TestParseChapterFixture(t *testing.T) {
+	chapterURL := testOrigin + "/truyen/qidian/1/12345/111/"
+	doc, err := html.Parse(bytes.NewReader(fixtureBody(t, chapterURL, chapterURL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	box := findElement(doc, "div", "contentbox")
+	if box == nil {
+		t.Fatal("no chapter content box in the fixture")
+	}
+	var contentHTML strings.Builder
+	if err := html.Render(&contentHTML, box); err != nil {
+		t.Fatal(err)
+	}
+
+	chapter := source.MergeChapterPages([]source.ChapterContent{parseChapterContent(chapterURL, contentHTML.String())})
+	result, err := ConvertToRawMessage(chapter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "chapter.json", result)
+}
+
+func TestParseChapterListFixture(t *testing.T) {
+	bookURL := testOrigin + "/truyen/qidian/1/12345/"
+	listURL := testOrigin + "/index.php?ngmar=chapterlist&h=qidian&bookid=12345&sajax=getchapterlist"
+
+	var response struct {
+		Code int    `json:"code"`
+		Data string `json:"data"`
+	}
+	if err := json.Unmarshal(fixtureBody(t, bookURL, listURL), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Code != 1 {
+		t.Fatalf("chapter list response code %d", response.Code)
+	}
+
+	chapters, err := ExtractChapterInfoFromData(response.Data, bookURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The chapters of the golden book are the ones read from this response
+	data, err := os.ReadFile(filepath.Join("testdata", "golden", "book.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var book source.Book
+	if err := json.Unmarshal(data, &book); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(chapters, book.Chapters) {
+		t.Errorf("chapters\ngot:  %+v\nwant: %+v", chapters, book.Chapters)
+	}
+}
+
+func TestExtractBookStatus(t *testing.T) {
+	tests := map[string]string{
+		"":            "",
+		"Còn tiếp":    source.BookStatusOngoing,
+		" Hoàn thành": source.BookStatusCompleted,
+		"Đã xong":     source.BookStatusCompleted,
+		"FULL":        source.BookStatusCompleted,
+		"Tạm dừng":    source.BookStatusOngoing,
+	}
+	for in, want := range tests {
+		if got := ExtractBookStatus(in); got != want {
+			t.Errorf("ExtractBookStatus(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
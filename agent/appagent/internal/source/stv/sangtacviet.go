@@ -7,12 +7,16 @@ type Sangtacviet struct {
 	username string
 	password string
 	origin   string
+
+	// maxChapterPages limits the pages followed for one chapter
+	maxChapterPages int
 }
 
-func New(username, password, origin string) source.WebSource {
+func New(username, password, origin string, maxChapterPages int) source.WebSource {
 	return &Sangtacviet{
-		username: username,
-		password: password,
-		origin:   origin,
+		username:        username,
+		password:        password,
+		origin:          origin,
+		maxChapterPages: maxChapterPages,
 	}
 }
@@ -89,9 +89,77 @@ func ExtractBookInfoFromElement(element *rod.Page) (*source.Book, error) {
 	book.BookName = bookName
 	book.AuthorName = authorName
 	book.BookImageUrl = *avatarURL
+
+	// Optional metadata, not every book page has all of it
+	book.Description = strings.TrimSpace(optionalText(element, descriptionSelector))
+	book.Genres = optionalTexts(element, genreSelector)
+	book.Tags = optionalTexts(element, tagSelector)
+	book.Status = ExtractBookStatus(optionalText(element, statusSelector))
 	return book, nil
 }
 
+// Selectors for the optional book metadata on the book page
+const (
+	descriptionSelector = "#book-sumary"
+	genreSelector       = "a[href*='/theloai/']"
+	tagSelector         = "a[href*='/tag/']"
+	statusSelector      = "#book-status, .book-status"
+)
+
+// optionalText returns the text of the first element matching selector, or an empty string.
+// Unlike page.Element it does not wait for the element to appear.
+func optionalText(page *rod.Page, selector string) string {
+	has, elem, err := page.Has(selector)
+	if err != nil || !has {
+		return ""
+	}
+	text, err := elem.Text()
+	if err != nil {
+		return ""
+	}
+	return text
+}
+
+// optionalTexts returns the de-duplicated, non-empty texts of all elements matching selector
+func optionalTexts(page *rod.Page, selector string) []string {
+	elems, err := page.Elements(selector)
+	if err != nil {
+		return nil
+	}
+
+	var texts []string
+	seen := make(map[string]bool)
+	for _, elem := range elems {
+		text, err := elem.Text()
+		if err != nil {
+			continue
+		}
+		text = strings.TrimSpace(text)
+		if text == "" || seen[text] {
+			continue
+		}
+		seen[text] = true
+		texts = append(texts, text)
+	}
+	return texts
+}
+
+// ExtractBookStatus maps the status text shown on the book page to a source book status.
+// An empty text yields an empty status so that an unknown status is not reported as ongoing.
+func ExtractBookStatus(val string) string {
+	val = strings.ToLower(strings.TrimSpace(val))
+	if val == "" {
+		return ""
+	}
+
+	for _, keyword := range []string{"hoàn thành", "hoàn tất", "full", "completed", "đã xong"} {
+		if strings.Contains(val, keyword) {
+			return source.BookStatusCompleted
+		}
+	}
+	return source.BookStatusOngoing
+}
+
 func ExtractChapterInfoFromData(data, bookUrl string) ([]source.Chapter, error) {
 	var chapters []source.Chapter
 
@@ -112,10 +180,11 @@ func ExtractChapterInfoFromData(data, bookUrl string) ([]source.Chapter, error)
 			idParts := strings.Split(parts[0], "-/-")
 			chapter.ChapterId = idParts[0]
 
-			// Extract ChapterName and ChapterIndex
+			// Extract ChapterName, its position in the list and the numbers in its title
 			title := strings.Trim(parts[1], " ")
 			chapter.ChapterName = title
-			chapter.ChapterNumber = ExtractFirstNumber(title)
+			chapter.Sequence = len(chapters) + 1
+			chapter.ChapterNumber, chapter.Volume, chapter.Part = ExtractChapterNumbers(title)
 			chapter.ChapterUrl = fmt.Sprintf("%s%s/", bookUrl, chapter.ChapterId)
 			chapters = append(chapters, chapter)
 		}
@@ -131,6 +200,39 @@ func IsStartWithUpper(val string) bool {
 	return val[0] >= 'A' && val[0] <= 'Z'
 }
 
+var (
+	volumeNumberRegexp  = regexp.MustCompile(`(?i)(?:^|[^\p{L}])(?:quyển|tập|volume|vol\.?|卷)\s*(\d+)`)
+	partNumberRegexp    = regexp.MustCompile(`(?i)(?:^|[^\p{L}])(?:phần|part)\s*(\d+)`)
+	chapterNumberRegexp = regexp.MustCompile(`(?i)(?:^|[^\p{L}])(?:chương|chapter|chap\.?|hồi|第)\s*(\d+)`)
+)
+
+// ExtractChapterNumbers returns the chapter, volume and part numbers in a chapter title,
+// 0 for those it doesn't have. "Quyển 2 Chương 15" is chapter 15 of volume 2. Without a
+// chapter keyword, the first number that is not a volume or part is the chapter number.
+func ExtractChapterNumbers(title string) (chapter, volume, part int) {
+	volume = submatchNumber(volumeNumberRegexp, title)
+	part = submatchNumber(partNumberRegexp, title)
+	if m := chapterNumberRegexp.FindStringSubmatch(title); m != nil {
+		chapter, _ = strconv.Atoi(m[1])
+		return
+	}
+
+	rest := volumeNumberRegexp.ReplaceAllString(title, " ")
+	rest = partNumberRegexp.ReplaceAllString(rest, " ")
+	chapter = ExtractFirstNumber(rest)
+	return
+}
+
+// submatchNumber returns the number captured by re in val, 0 when it doesn't match
+func submatchNumber(re *regexp.Regexp, val string) int {
+	m := re.FindStringSubmatch(val)
+	if m == nil {
+		return 0
+	}
+	num, _ := strconv.Atoi(m[1])
+	return num
+}
+
 func ExtractFirstNumber(val string) int {
 	re := regexp.MustCompile(`\d+`)
 	match := re.FindString(val)
@@ -0,0 +1,29 @@
+package stv
+
+import "testing"
+
+func TestExtractChapterNumbers(t *testing.T) {
+	tests := []struct {
+		title                 string
+		chapter, volume, part int
+	}{
+		{title: "Chương 1: Khởi đầu", chapter: 1},
+		{title: "Quyển 2 Chương 15", chapter: 15, volume: 2},
+		{title: "Quyển 2 Chương 15: Trở lại", chapter: 15, volume: 2},
+		{title: "Mở đầu"},
+		{title: "Phần 3", part: 3},
+		{title: "Phần 3 - 12: Gặp lại", chapter: 12, part: 3},
+		{title: "第12章", chapter: 12},
+		{title: "Tập 4 第7章 风起", chapter: 7, volume: 4},
+		{title: "Vol.3 Chapter 20", chapter: 20, volume: 3},
+		{title: "45. Trận chiến", chapter: 45},
+	}
+
+	for _, tt := range tests {
+		chapter, volume, part := ExtractChapterNumbers(tt.title)
+		if chapter != tt.chapter || volume != tt.volume || part != tt.part {
+			t.Errorf("ExtractChapterNumbers(%q) = %d, %d, %d, want %d, %d, %d",
+				tt.title, chapter, volume, part, tt.chapter, tt.volume, tt.part)
+		}
+	}
+}
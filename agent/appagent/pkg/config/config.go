@@ -26,8 +26,22 @@ type ControlAPIConfig struct {
 	AgentName              string        `mapstructure:"agent_name"`
 	IPAddress              string        `mapstructure:"ip_address"`
 	AgentHeartbeatInterval time.Duration `mapstructure:"agent_heartbeat_interval"`
+	WebsiteSyncInterval    time.Duration `mapstructure:"website_sync_interval"`
 	ReportResults          bool          `mapstructure:"report_results"`
 	ResultsEndpoint        string        `mapstructure:"results_endpoint"`
+	// Transport is "http" (default) or "grpc". Over gRPC, registration, heartbeats and
+	// results go to GRPCAddr, websites are still read from BaseURL.
+	Transport string `mapstructure:"transport"`
+	GRPCAddr  string `mapstructure:"grpc_addr"`
+	// GRPCCAFile is the PEM CA certificate verifying the gRPC control API, the system
+	// roots are used when empty. GRPCInsecure connects in plaintext instead of TLS.
+	GRPCCAFile   string `mapstructure:"grpc_ca_file"`
+	GRPCInsecure bool   `mapstructure:"grpc_insecure"`
+	// CompressRequests gzips request bodies and gRPC calls
+	CompressRequests bool `mapstructure:"compress_requests"`
+	// ChunkSize is the size above which results are uploaded over HTTP in chunks of this
+	// size, 0 sends every result in one request
+	ChunkSize int `mapstructure:"chunk_size"` // bytes
 }
 
 // LoggerConfig holds the configuration for the logger
@@ -49,6 +63,9 @@ type Config struct {
 	UserAgent   []string      `mapstructure:"user_agent"`
 	MaxDepth    int           `mapstructure:"max_depth"`
 
+	// MaxChapterPages limits the pages followed for a chapter split across several pages
+	MaxChapterPages int `mapstructure:"max_chapter_pages"`
+
 	// Headless browser settings
 	BrowserPath    string        `mapstructure:"browser_path"`
 	BrowserTimeout time.Duration `mapstructure:"browser_timeout"`
@@ -58,6 +75,11 @@ type Config struct {
 	OutputDir   string `mapstructure:"output_dir"`
 	SessionFile string `mapstructure:"session_file"`
 
+	// Fixture settings, FixtureMode is "record" or "replay" and FixtureDir holds one
+	// directory of responses per task
+	FixtureMode string `mapstructure:"fixture_mode"`
+	FixtureDir  string `mapstructure:"fixture_dir"`
+
 	// RabbitMQ settings
 	RabbitMQ RabbitMQConfig `mapstructure:"rabbitmq"`
 
@@ -53,17 +53,17 @@ func (s *AgentService) GetAgent(ctx context.Context, ipAddress, name string) (*A
 	}
 
 	// Decode the response
-	var agents []Agent
-	if err := json.NewDecoder(resp.Body).Decode(&agents); err != nil {
+	var page Page[Agent]
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
 		return nil, fmt.Errorf("failed to decode agent response: %w", err)
 	}
 
 	// Should only have one agent
-	if len(agents) != 1 {
-		return nil, fmt.Errorf("expected 1 agent, got %d", len(agents))
+	if page.Total != 1 || len(page.Data) != 1 {
+		return nil, fmt.Errorf("expected 1 agent, got %d", page.Total)
 	}
 
-	return &agents[0], nil
+	return &page.Data[0], nil
 }
 
 func (s *AgentService) Heartbeat(ctx context.Context, agentID string) error {
@@ -2,6 +2,7 @@ package http
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -14,6 +15,15 @@ type Client struct {
 	baseURL    string
 	httpClient *http.Client
 	headers    map[string]string
+	// compress gzips request bodies
+	compress bool
+}
+
+// Page is one page of a list endpoint
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // NewClient creates a new HTTP client
@@ -32,6 +42,11 @@ func (c *Client) SetHeader(key, value string) {
 	c.headers[key] = value
 }
 
+// SetCompression sets whether request bodies are sent gzip compressed
+func (c *Client) SetCompression(compress bool) {
+	c.compress = compress
+}
+
 // Get makes a GET request to the specified endpoint
 func (c *Client) Get(ctx context.Context, endpoint string) (*http.Response, error) {
 	url := fmt.Sprintf("%s%s", c.baseURL, endpoint)
@@ -56,56 +71,60 @@ func (c *Client) Get(ctx context.Context, endpoint string) (*http.Response, erro
 
 // Post makes a POST request to the specified endpoint with the given payload
 func (c *Client) Post(ctx context.Context, endpoint string, payload interface{}) (*http.Response, error) {
-	url := fmt.Sprintf("%s%s", c.baseURL, endpoint)
+	return c.sendJSON(ctx, "POST", endpoint, payload)
+}
+
+// Put makes a PUT request to the specified endpoint with the given payload
+func (c *Client) Put(ctx context.Context, endpoint string, payload interface{}) (*http.Response, error) {
+	return c.sendJSON(ctx, "PUT", endpoint, payload)
+}
+
+// PutBytes makes a PUT request to the specified endpoint with a raw body
+func (c *Client) PutBytes(ctx context.Context, endpoint string, body []byte, contentType string) (*http.Response, error) {
+	return c.send(ctx, "PUT", endpoint, body, contentType)
+}
 
+// sendJSON makes a request with the payload marshaled to JSON
+func (c *Client) sendJSON(ctx context.Context, method, endpoint string, payload interface{}) (*http.Response, error) {
 	// Marshal the payload
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	// Create the request
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Add headers
-	req.Header.Set("Content-Type", "application/json")
-	for key, value := range c.headers {
-		req.Header.Set(key, value)
-	}
-
-	// Make the request
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-
-	return resp, nil
+	return c.send(ctx, method, endpoint, jsonPayload, "application/json")
 }
 
-// Put makes a PUT request to the specified endpoint with the given payload
-func (c *Client) Put(ctx context.Context, endpoint string, payload interface{}) (*http.Response, error) {
+// send makes a request with a body, gzip compressed when compression is enabled
+func (c *Client) send(ctx context.Context, method, endpoint string, body []byte, contentType string) (*http.Response, error) {
 	url := fmt.Sprintf("%s%s", c.baseURL, endpoint)
 
-	// Marshal the payload
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	if c.compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return nil, fmt.Errorf("failed to compress payload: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress payload: %w", err)
+		}
+		body = buf.Bytes()
 	}
 
 	// Create the request
-	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewBuffer(jsonPayload))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Add headers
-	req.Header.Set("Content-Type", "application/json")
 	for key, value := range c.headers {
 		req.Header.Set(key, value)
 	}
+	req.Header.Set("Content-Type", contentType)
+	if c.compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 
 	// Make the request
 	resp, err := c.httpClient.Do(req)
@@ -0,0 +1,319 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zrik/agent/appagent/pkg/config"
+	"github.com/zrik/agent/appagent/pkg/logger"
+	"github.com/zrik/protocol"
+	"github.com/zrik/protocol/controlpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+)
+
+// commandBuffer is the number of pushed commands waiting to be read from Commands
+const commandBuffer = 16
+
+// GRPCService is the service of the gRPC control API. Registration, heartbeats and
+// results use gRPC, websites are read over HTTP.
+type GRPCService struct {
+	agent      *Agent
+	config     *config.ControlAPIConfig
+	taskSvc    ITaskService
+	agentSvc   *GRPCAgentService
+	websiteSvc IWebsiteService
+}
+
+// NewGRPCService connects to the gRPC control API and registers the agent
+func NewGRPCService(cfg *config.ControlAPIConfig) IService {
+	client := newClient(cfg)
+
+	// Send the API key with every call
+	apiKey := func(ctx context.Context) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, controlpb.APIKeyMetadata, cfg.APIKey)
+	}
+	creds, err := transportCredentials(cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to load gRPC TLS configuration")
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(apiKey(ctx), method, req, reply, cc, opts...)
+		}),
+		grpc.WithStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(apiKey(ctx), desc, cc, method, opts...)
+		}),
+	}
+	if cfg.CompressRequests {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+	}
+	conn, err := grpc.NewClient(cfg.GRPCAddr, opts...)
+	if err != nil {
+		logger.Fatal().Err(err).Str("addr", cfg.GRPCAddr).Msg("Failed to create gRPC client")
+	}
+	control := controlpb.NewAgentControlClient(conn)
+
+	taskSvc := NewGRPCTaskService(control, cfg.Timeout*time.Second)
+	agentSvc := NewGRPCAgentService(control, cfg.Timeout*time.Second)
+	websiteSvc := NewWebsiteService(client)
+
+	agent, err := agentSvc.GetAgent(context.Background(), cfg.IPAddress, cfg.AgentName)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to get agent")
+	} else {
+		logger.Info().Str("agent", agent.Name).Str("addr", cfg.GRPCAddr).Msg("Agent registered over gRPC")
+	}
+
+	return &GRPCService{
+		config:     cfg,
+		agent:      agent,
+		taskSvc:    taskSvc,
+		agentSvc:   agentSvc,
+		websiteSvc: websiteSvc,
+	}
+}
+
+// transportCredentials returns TLS credentials verifying the control API with the
+// configured CA or the system roots, or plaintext when grpc_insecure is set
+func transportCredentials(cfg *config.ControlAPIConfig) (credentials.TransportCredentials, error) {
+	if cfg.GRPCInsecure {
+		return insecure.NewCredentials(), nil
+	}
+	if cfg.GRPCCAFile == "" {
+		return credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12}), nil
+	}
+	return credentials.NewClientTLSFromFile(cfg.GRPCCAFile, "")
+}
+
+func (s *GRPCService) GetTaskService() ITaskService {
+	return s.taskSvc
+}
+
+func (s *GRPCService) GetWebsiteService() IWebsiteService {
+	return s.websiteSvc
+}
+
+func (s *GRPCService) GetAgentService() IAgentService {
+	return s.agentSvc
+}
+
+func (s *GRPCService) GetAgent() *Agent {
+	return s.agent
+}
+
+// IsReportingEnabled returns whether result reporting is enabled
+func (s *GRPCService) IsReportingEnabled() bool {
+	return s.config.ReportResults
+}
+
+// Commands returns the commands pushed on the heartbeat stream
+func (s *GRPCService) Commands() <-chan Command {
+	return s.agentSvc.commands
+}
+
+// GRPCAgentService sends heartbeats on a stream kept open between them. IsActive doesn't
+// call the control API, it follows the pause and resume commands pushed on the stream.
+type GRPCAgentService struct {
+	client   controlpb.AgentControlClient
+	timeout  time.Duration
+	active   atomic.Bool
+	commands chan Command
+
+	mu     sync.Mutex
+	stream controlpb.AgentControl_HeartbeatClient
+	cancel context.CancelFunc
+}
+
+// NewGRPCAgentService creates a new gRPC agent service
+func NewGRPCAgentService(client controlpb.AgentControlClient, timeout time.Duration) *GRPCAgentService {
+	s := &GRPCAgentService{
+		client:   client,
+		timeout:  timeout,
+		commands: make(chan Command, commandBuffer),
+	}
+	s.active.Store(true)
+	return s
+}
+
+func (s *GRPCAgentService) GetAgent(ctx context.Context, ipAddress, name string) (*Agent, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	msg, err := s.client.Register(ctx, &controlpb.RegisterRequest{Name: name, IpAddress: ipAddress})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register agent: %w", err)
+	}
+
+	id, err := uuid.Parse(msg.GetId())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse agent ID: %w", err)
+	}
+	agent := &Agent{
+		ID:        id,
+		Name:      msg.GetName(),
+		IPAddress: msg.GetIpAddress(),
+		IsActive:  msg.GetIsActive(),
+		CreatedAt: msg.GetCreatedAt().AsTime(),
+	}
+	if msg.GetLastHeartbeat() != nil {
+		agent.LastHeartbeat.Time = msg.GetLastHeartbeat().AsTime()
+		agent.LastHeartbeat.Valid = true
+	}
+	return agent, nil
+}
+
+// Heartbeat sends a heartbeat on the stream, opening it first when it is closed
+func (s *GRPCAgentService) Heartbeat(ctx context.Context, agentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stream == nil {
+		// The stream outlives the heartbeat, it is closed when sending fails
+		streamCtx, cancel := context.WithCancel(context.Background())
+		stream, err := s.client.Heartbeat(streamCtx)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("failed to open heartbeat stream: %w", err)
+		}
+		s.stream, s.cancel = stream, cancel
+
+		// The control API treats a new stream as an active agent and pushes a pause
+		// command right away when it isn't
+		s.active.Store(true)
+		go s.receiveCommands(stream)
+	}
+
+	if err := s.stream.Send(&controlpb.HeartbeatRequest{AgentId: agentID}); err != nil {
+		s.closeStream(s.stream)
+		return fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+	return nil
+}
+
+// IsActive reports whether the agent was told to pause
+func (s *GRPCAgentService) IsActive(ctx context.Context, agentID string) (bool, error) {
+	return s.active.Load(), nil
+}
+
+// receiveCommands handles the commands pushed on a heartbeat stream until it is closed
+func (s *GRPCAgentService) receiveCommands(stream controlpb.AgentControl_HeartbeatClient) {
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			logger.Warn().Err(err).Msg("Heartbeat stream closed")
+			s.mu.Lock()
+			s.closeStream(stream)
+			s.mu.Unlock()
+			return
+		}
+
+		var cmd Command
+		switch msg.GetType() {
+		case controlpb.Command_TYPE_PAUSE:
+			s.active.Store(false)
+			cmd.Type = CommandPause
+		case controlpb.Command_TYPE_RESUME:
+			s.active.Store(true)
+			cmd.Type = CommandResume
+		case controlpb.Command_TYPE_SYNC_WEBSITES:
+			cmd.Type = CommandSyncWebsites
+		default:
+			logger.Warn().Str("command", msg.GetType().String()).Msg("Ignoring unknown command")
+			continue
+		}
+		cmd.Reason = msg.GetReason()
+		logger.Info().Str("command", string(cmd.Type)).Str("reason", cmd.Reason).Msg("Received command")
+
+		select {
+		case s.commands <- cmd:
+		default:
+			logger.Warn().Str("command", string(cmd.Type)).Msg("Command queue is full, dropping command")
+		}
+	}
+}
+
+// closeStream closes a heartbeat stream unless it was replaced, the caller must hold mu
+func (s *GRPCAgentService) closeStream(stream controlpb.AgentControl_HeartbeatClient) {
+	if s.stream != stream {
+		return
+	}
+	s.cancel()
+	s.stream, s.cancel = nil, nil
+}
+
+// GRPCTaskService uploads task results on a client stream
+type GRPCTaskService struct {
+	client  controlpb.AgentControlClient
+	timeout time.Duration
+}
+
+// NewGRPCTaskService creates a new gRPC task result service
+func NewGRPCTaskService(client controlpb.AgentControlClient, timeout time.Duration) ITaskService {
+	return &GRPCTaskService{
+		client:  client,
+		timeout: timeout,
+	}
+}
+
+// ReportTaskResult uploads a task result and waits for its outcome
+func (s *GRPCTaskService) ReportTaskResult(ctx context.Context, result *protocol.TaskResult) error {
+	// Set the completion time if not already set
+	if result.CompletedAt.IsZero() {
+		result.CompletedAt = time.Now()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	stream, err := s.client.UploadResults(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to report task result: %w", err)
+	}
+	if err := stream.Send(controlpb.FromTaskResult(result)); err != nil {
+		return fmt.Errorf("failed to report task result: %w", err)
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return fmt.Errorf("failed to report task result: %w", err)
+	}
+
+	if len(resp.GetOutcomes()) != 1 {
+		return fmt.Errorf("failed to report task result: expected 1 outcome, got %d", len(resp.GetOutcomes()))
+	}
+	if outcome := resp.GetOutcomes()[0]; !outcome.GetAccepted() {
+		return fmt.Errorf("task result rejected: %s", outcome.GetError())
+	}
+	return nil
+}
+
+// ReportTaskStarted reports that the agent started a task
+func (s *GRPCTaskService) ReportTaskStarted(ctx context.Context, started *protocol.TaskStarted) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.client.StartTask(ctx, controlpb.FromTaskStarted(started)); err != nil {
+		return fmt.Errorf("failed to report task start: %w", err)
+	}
+	return nil
+}
+
+// ReportTaskSuccess reports a successful task result
+func (s *GRPCTaskService) ReportTaskSuccess(ctx context.Context, taskID string, taskType protocol.TaskType, source protocol.SourceType, url string, data json.RawMessage) error {
+	return s.ReportTaskResult(ctx, successResult(taskID, taskType, source, url, data))
+}
+
+// ReportTaskError reports a failed task result
+func (s *GRPCTaskService) ReportTaskError(ctx context.Context, taskID string, taskType protocol.TaskType, source protocol.SourceType, url string, err error) error {
+	return s.ReportTaskResult(ctx, errorResult(taskID, taskType, source, url, err))
+}
@@ -14,6 +14,25 @@ type IService interface {
 	GetWebsiteService() IWebsiteService
 	IsReportingEnabled() bool
 	GetAgent() *Agent
+	// Commands receives the commands pushed by the control API, it is nil when the
+	// transport can't push commands
+	Commands() <-chan Command
+}
+
+// CommandType is the type of a command pushed by the control API
+type CommandType string
+
+// Command types
+const (
+	CommandPause        CommandType = "pause"
+	CommandResume       CommandType = "resume"
+	CommandSyncWebsites CommandType = "sync_websites"
+)
+
+// Command is an instruction pushed by the control API
+type Command struct {
+	Type   CommandType
+	Reason string
 }
 
 // Service represents the HTTP service
@@ -27,19 +46,10 @@ type Service struct {
 
 // NewService creates a new HTTP service
 func NewService(cfg *config.ControlAPIConfig) IService {
-	// Create the HTTP client
-	client := NewClient(cfg.BaseURL, cfg.Timeout*time.Second)
-
-	// client.SetHeader("Content-Type", "application/json")
-	client.SetHeader("Accept", "*/*")
-	// Set the API key if provided
-	if cfg.APIKey == "" {
-		logger.Fatal().Msg("API key not provided")
-	}
-	client.SetHeader("Api-Key", cfg.APIKey)
+	client := newClient(cfg)
 
 	// Create the task result service
-	taskSvc := NewTaskResultService(client)
+	taskSvc := NewTaskResultService(client, cfg.ChunkSize)
 	agentSvc := NewAgentService(client)
 	websiteSvc := NewWebsiteService(client)
 
@@ -59,6 +69,21 @@ func NewService(cfg *config.ControlAPIConfig) IService {
 	}
 }
 
+// newClient creates the HTTP client of the control API
+func newClient(cfg *config.ControlAPIConfig) *Client {
+	client := NewClient(cfg.BaseURL, cfg.Timeout*time.Second)
+
+	// client.SetHeader("Content-Type", "application/json")
+	client.SetHeader("Accept", "*/*")
+	// Set the API key if provided
+	if cfg.APIKey == "" {
+		logger.Fatal().Msg("API key not provided")
+	}
+	client.SetHeader("Api-Key", cfg.APIKey)
+	client.SetCompression(cfg.CompressRequests)
+	return client
+}
+
 // GetTaskResultService returns the task result service
 func (s *Service) GetTaskService() ITaskService {
 	return s.taskSvc
@@ -80,3 +105,8 @@ func (s *Service) GetAgent() *Agent {
 func (s *Service) IsReportingEnabled() bool {
 	return s.config.ReportResults
 }
+
+// Commands returns nil, commands are not pushed over HTTP
+func (s *Service) Commands() <-chan Command {
+	return nil
+}
@@ -6,82 +6,52 @@ import (
 	"fmt"
 	"io"
 	"time"
-)
-
-// TaskResultStatus represents the status of a task result
-type TaskResultStatus string
-
-const (
-	// TaskResultStatusSuccess indicates a successful task
-	TaskResultStatusSuccess TaskResultStatus = "success"
-	// TaskResultStatusError indicates a failed task
-	TaskResultStatusError TaskResultStatus = "error"
-)
-
-// TaskType represents the type of task
-type TaskType string
 
-// SourceType represents the source of the task
-type SourceType string
-
-const (
-	// TaskTypeBook represents a book crawling task
-	TaskTypeBook TaskType = "book"
-	// TaskTypeChapter represents a chapter crawling task
-	TaskTypeChapter TaskType = "chapter"
-	// TaskTypeSession represents a session extraction task
-	TaskTypeSession TaskType = "session"
-)
-
-const (
-	// SourceTypeSangTacViet represents the SangTacViet source
-	SourceTypeSangTacViet SourceType = "sangtacviet"
-	// SourceTypeWikiDich represents the WikiDich source
-	SourceTypeWikiDich SourceType = "wikidich"
-	// SourceTypeMetruyenchu represents the Metruyenchu source
-	SourceTypeMetruyenchu SourceType = "metruyenchu"
+	"github.com/google/uuid"
+	"github.com/zrik/agent/appagent/pkg/logger"
+	"github.com/zrik/protocol"
 )
 
-// TaskResult represents the result of a task
-type TaskResult struct {
-	TaskID      string           `json:"task_id"`
-	TaskType    TaskType         `json:"task_type"`
-	Source      SourceType       `json:"source"`
-	Status      TaskResultStatus `json:"status"`
-	Message     string           `json:"message"`
-	Data        json.RawMessage  `json:"data,omitempty"`
-	URL         string           `json:"url"`
-	CompletedAt time.Time        `json:"completed_at"`
-}
-
 type ITaskService interface {
-	ReportTaskResult(ctx context.Context, result *TaskResult) error
-	ReportTaskSuccess(ctx context.Context, taskID string, taskType TaskType, source SourceType, url string, data json.RawMessage) error
-	ReportTaskError(ctx context.Context, taskID string, taskType TaskType, source SourceType, url string, err error) error
+	ReportTaskStarted(ctx context.Context, started *protocol.TaskStarted) error
+	ReportTaskResult(ctx context.Context, result *protocol.TaskResult) error
+	ReportTaskSuccess(ctx context.Context, taskID string, taskType protocol.TaskType, source protocol.SourceType, url string, data json.RawMessage) error
+	ReportTaskError(ctx context.Context, taskID string, taskType protocol.TaskType, source protocol.SourceType, url string, err error) error
 }
 
 // TaskService handles task result reporting
 type TaskService struct {
 	client *Client
+	// chunkSize is the size above which results are uploaded in chunks, 0 disables chunks
+	chunkSize int
 }
 
 // NewTaskResultService creates a new task result service
-func NewTaskResultService(client *Client) ITaskService {
+func NewTaskResultService(client *Client, chunkSize int) ITaskService {
 	return &TaskService{
-		client: client,
+		client:    client,
+		chunkSize: chunkSize,
 	}
 }
 
 // ReportTaskResult reports a task result to the control API
-func (s *TaskService) ReportTaskResult(ctx context.Context, result *TaskResult) error {
+func (s *TaskService) ReportTaskResult(ctx context.Context, result *protocol.TaskResult) error {
 	// Set the completion time if not already set
 	if result.CompletedAt.IsZero() {
 		result.CompletedAt = time.Now()
 	}
 
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task result: %w", err)
+	}
+	if s.chunkSize > 0 && len(data) > s.chunkSize {
+		return s.uploadChunks(ctx, result.TaskID, data)
+	}
+
 	// Make the request
 	s.client.SetHeader("Content-Type", "application/json")
-	resp, err := s.client.Post(ctx, "/api/tasks/result", result)
+	resp, err := s.client.Post(ctx, "/api/tasks/result", json.RawMessage(data))
 	if err != nil {
 		return fmt.Errorf("failed to report task result: %w", err)
 	}
@@ -96,38 +66,82 @@ func (s *TaskService) ReportTaskResult(ctx context.Context, result *TaskResult)
 	return nil
 }
 
-// ReportTaskSuccess reports a successful task result
-func (s *TaskService) ReportTaskSuccess(ctx context.Context, taskID string, taskType TaskType, source SourceType, url string, data json.RawMessage) error {
-	result := &TaskResult{
-		TaskID:      taskID,
-		TaskType:    taskType,
-		Source:      source,
-		Status:      TaskResultStatusSuccess,
-		Message:     "Task completed successfully",
-		Data:        data,
-		URL:         url,
-		CompletedAt: time.Now(),
+// ReportTaskStarted reports that the agent started a task
+func (s *TaskService) ReportTaskStarted(ctx context.Context, started *protocol.TaskStarted) error {
+	s.client.SetHeader("Content-Type", "application/json")
+	resp, err := s.client.Post(ctx, "/api/tasks/started", started)
+	if err != nil {
+		return fmt.Errorf("failed to report task start: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check the response status
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to report task start: status %d, body: %s", resp.StatusCode, string(body))
 	}
 
-	return s.ReportTaskResult(ctx, result)
+	return nil
 }
 
-// ReportTaskError reports a failed task result
-func (s *TaskService) ReportTaskError(ctx context.Context, taskID string, taskType TaskType, source SourceType, url string, err error) error {
-	result := &TaskResult{
-		TaskID:      taskID,
-		TaskType:    taskType,
-		Source:      source,
-		Status:      TaskResultStatusError,
-		Message:     err.Error(),
-		URL:         url,
-		CompletedAt: time.Now(),
+// uploadChunks uploads a large task result body in chunks, the control API processes it
+// when the last one arrives
+func (s *TaskService) uploadChunks(ctx context.Context, taskID string, data []byte) error {
+	uploadID := uuid.New().String()
+	chunks := (len(data) + s.chunkSize - 1) / s.chunkSize
+	logger.Debug().Str("taskID", taskID).Str("upload_id", uploadID).Int("size", len(data)).Int("chunks", chunks).Msg("Uploading task result in chunks")
+
+	for i := 0; i < chunks; i++ {
+		chunk := data[i*s.chunkSize : min((i+1)*s.chunkSize, len(data))]
+		endpoint := fmt.Sprintf("/api/tasks/result/uploads/%s/chunks/%d", uploadID, i)
+		if i == chunks-1 {
+			endpoint += "?final=true"
+		}
+
+		resp, err := s.client.PutBytes(ctx, endpoint, chunk, "application/octet-stream")
+		if err != nil {
+			return fmt.Errorf("failed to upload task result chunk %d: %w", i, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		// Check the response status
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("failed to upload task result chunk %d: status %d, body: %s", i, resp.StatusCode, string(body))
+		}
 	}
 
-	return s.ReportTaskResult(ctx, result)
+	return nil
+}
+
+// ReportTaskSuccess reports a successful task result
+func (s *TaskService) ReportTaskSuccess(ctx context.Context, taskID string, taskType protocol.TaskType, source protocol.SourceType, url string, data json.RawMessage) error {
+	return s.ReportTaskResult(ctx, successResult(taskID, taskType, source, url, data))
+}
+
+// ReportTaskError reports a failed task result
+func (s *TaskService) ReportTaskError(ctx context.Context, taskID string, taskType protocol.TaskType, source protocol.SourceType, url string, err error) error {
+	return s.ReportTaskResult(ctx, errorResult(taskID, taskType, source, url, err))
+}
+
+// successResult creates the result of a successful task
+func successResult(taskID string, taskType protocol.TaskType, source protocol.SourceType, url string, data json.RawMessage) *protocol.TaskResult {
+	result := protocol.NewResult(taskID, taskType, source, url)
+	result.Status = protocol.ResultStatusSuccess
+	result.Message = "Task completed successfully"
+	result.Data = data
+	return result
+}
+
+// errorResult creates the result of a failed task
+func errorResult(taskID string, taskType protocol.TaskType, source protocol.SourceType, url string, err error) *protocol.TaskResult {
+	result := protocol.NewResult(taskID, taskType, source, url)
+	result.Status = protocol.ResultStatusError
+	result.Message = err.Error()
+	return result
 }
 
-// GenerateTaskID generates a task ID from a task URL and type
-func GenerateTaskID(taskType TaskType, source SourceType, url string) string {
+// GenerateTaskID generates a task ID from a task URL and type, for tasks published without one
+func GenerateTaskID(taskType protocol.TaskType, source protocol.SourceType, url string) string {
 	return fmt.Sprintf("%s-%s-%s-%d", string(source), string(taskType), url, time.Now().Unix())
 }
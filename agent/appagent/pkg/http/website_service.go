@@ -5,8 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
+	"strconv"
 )
 
+// websitePageSize is the number of websites requested per page
+const websitePageSize = 100
+
 type Website struct {
 	ID            int    `json:"id"`
 	Name          string `json:"name"`
@@ -35,24 +40,49 @@ func NewWebsiteService(client *Client) IWebsiteService {
 
 func (s *WebsiteService) GetWebsites(ctx context.Context) ([]Website, error) {
 	s.client.SetHeader("Content-Type", "application/json")
-	resp, err := s.client.Get(ctx, "/api/websites")
+
+	// Follow the pages of the list until the last one
+	websites := []Website{}
+	cursor := ""
+	for {
+		endpoint := "/api/websites?limit=" + strconv.Itoa(websitePageSize)
+		if cursor != "" {
+			endpoint += "&cursor=" + url.QueryEscape(cursor)
+		}
+
+		page, err := s.getWebsitePage(ctx, endpoint)
+		if err != nil {
+			return []Website{}, err
+		}
+		websites = append(websites, page.Data...)
+
+		if page.NextCursor == "" {
+			return websites, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// getWebsitePage gets one page of the website list
+func (s *WebsiteService) getWebsitePage(ctx context.Context, endpoint string) (*Page[Website], error) {
+	resp, err := s.client.Get(ctx, endpoint)
 	if err != nil {
-		return []Website{}, fmt.Errorf("failed to get websites: %w", err)
+		return nil, fmt.Errorf("failed to get websites: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Check the response status
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return []Website{}, fmt.Errorf("failed to get websites: status %d, body: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("failed to get websites: status %d, body: %s", resp.StatusCode, string(body))
 	}
 
 	// Decode the response
-	var websites []Website
-	if err := json.NewDecoder(resp.Body).Decode(&websites); err != nil {
-		return []Website{}, fmt.Errorf("failed to decode website response: %w", err)
+	var page Page[Website]
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to decode website response: %w", err)
 	}
-	return websites, nil
+	return &page, nil
 }
 
 func (s *WebsiteService) GetWebsite(ctx context.Context, id int) (Website, error) {
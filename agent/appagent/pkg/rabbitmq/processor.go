@@ -4,30 +4,39 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
+	"github.com/go-rod/rod"
 	"github.com/zrik/agent/appagent/internal/source"
 	"github.com/zrik/agent/appagent/pkg/config"
 	http "github.com/zrik/agent/appagent/pkg/http"
 	"github.com/zrik/agent/appagent/pkg/logger"
 	"github.com/zrik/agent/appagent/pkg/spider"
+	"github.com/zrik/protocol"
 )
 
-// SourceClientRegistry is a registry for source clients
-type SourceClientRegistry map[SourceType]source.WebSource
+// sourceClient is a registered source client with the number of running tasks using it.
+// A client replaced or unregistered is retired and closed once its last task ends.
+type sourceClient struct {
+	source.WebSource
+	tasks   int
+	retired bool
+}
 
 // Processor represents a task processor
 type Processor struct {
 	service        *Service
 	config         *config.Config
 	spider         spider.TaskSpider
-	sourceClients  SourceClientRegistry
+	sourceClients  map[protocol.SourceType]*sourceClient
+	clientsMu      sync.Mutex
 	wg             sync.WaitGroup
 	ctx            context.Context
 	cancel         context.CancelFunc
-	priorityTask   chan Task
-	normalTask     chan Task
+	priorityTask   chan protocol.Task
+	normalTask     chan protocol.Task
 	taskProcessors map[string]TaskProcessor
 	httpService    http.IService
 }
@@ -43,23 +52,93 @@ func NewProcessor(service *Service, cfg *config.Config, spider *spider.HeadSpide
 		service:        service,
 		config:         cfg,
 		spider:         spider,
-		sourceClients:  make(SourceClientRegistry),
+		sourceClients:  make(map[protocol.SourceType]*sourceClient),
 		ctx:            ctx,
 		cancel:         cancel,
-		priorityTask:   make(chan Task, 10),
-		normalTask:     make(chan Task, 100),
+		priorityTask:   make(chan protocol.Task, 10),
+		normalTask:     make(chan protocol.Task, 100),
 		taskProcessors: make(map[string]TaskProcessor),
 		httpService:    httpService,
 	}
 }
 
-// RegisterSourceClient registers a source client for a specific source type
-func (p *Processor) RegisterSourceClient(sourceType SourceType, client source.WebSource) {
-	p.sourceClients[sourceType] = client
+// RegisterSourceClient registers a source client for a specific source type,
+// replacing any client already registered for it. The replaced client is closed like
+// an unregistered one.
+func (p *Processor) RegisterSourceClient(sourceType protocol.SourceType, client source.WebSource) {
+	p.clientsMu.Lock()
+	old := p.sourceClients[sourceType]
+	p.sourceClients[sourceType] = &sourceClient{WebSource: client}
+	closeOld := old != nil && old.retire()
+	p.clientsMu.Unlock()
+
+	if closeOld {
+		closeSourceClient(sourceType, old)
+	}
+}
+
+// UnregisterSourceClient removes the source client for a specific source type.
+// Tasks already running keep the client they started with, it is closed when they end
+// if it implements io.Closer.
+func (p *Processor) UnregisterSourceClient(sourceType protocol.SourceType) {
+	p.clientsMu.Lock()
+	old := p.sourceClients[sourceType]
+	delete(p.sourceClients, sourceType)
+	closeOld := old != nil && old.retire()
+	p.clientsMu.Unlock()
+
+	if closeOld {
+		closeSourceClient(sourceType, old)
+	}
+}
+
+// acquireSourceClient returns the source client registered for a source type, counting
+// a running task until releaseSourceClient
+func (p *Processor) acquireSourceClient(sourceType protocol.SourceType) (*sourceClient, bool) {
+	p.clientsMu.Lock()
+	defer p.clientsMu.Unlock()
+	client, ok := p.sourceClients[sourceType]
+	if ok {
+		client.tasks++
+	}
+	return client, ok
+}
+
+// releaseSourceClient ends a task using a client, closing the client when it was retired
+// and this was its last task
+func (p *Processor) releaseSourceClient(sourceType protocol.SourceType, client *sourceClient) {
+	p.clientsMu.Lock()
+	client.tasks--
+	closeClient := client.retired && client.tasks == 0
+	p.clientsMu.Unlock()
+
+	if closeClient {
+		closeSourceClient(sourceType, client)
+	}
+}
+
+// retire marks a client replaced or unregistered, reporting whether it can be closed now.
+// The caller must hold clientsMu.
+func (c *sourceClient) retire() bool {
+	c.retired = true
+	return c.tasks == 0
+}
+
+// closeSourceClient releases the resources of a retired client that holds any
+func closeSourceClient(sourceType protocol.SourceType, client *sourceClient) {
+	closer, ok := client.WebSource.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		logger.Error().Err(err).Str("source", string(sourceType)).Msg("Error closing source client")
+		return
+	}
+	logger.Debug().Str("source", string(sourceType)).Msg("Closed retired source client")
 }
 
 // RegisterTaskProcessor registers a task processor for a specific task type
-func (p *Processor) RegisterTaskProcessor(taskType TaskType, processor TaskProcessor) {
+func (p *Processor) RegisterTaskProcessor(taskType protocol.TaskType, processor TaskProcessor) {
 	p.taskProcessors[string(taskType)] = processor
 }
 
@@ -100,28 +179,29 @@ func (p *Processor) checkAgentActive() (bool, error) {
 }
 
 // processTask processes a single task
-func (p *Processor) processTask(task Task) {
+func (p *Processor) processTask(task protocol.Task) {
 	isActive, err := p.checkAgentActive()
 	if err != nil || !isActive {
 		logger.Warn().Msg("Agent is not active, skipping task")
 		return
 	}
 
-	source, taskType, err := ParseTopicInfo(task.Topic)
+	source, taskType, err := protocol.ParseTopic(task.Topic)
 	if err != nil {
 		logger.Error().Err(err).Str("topic", task.Topic).Msg("Error parsing topic")
 		return
 	}
 
-	// Get the source client
-	sourceClient, ok := p.sourceClients[source]
+	// Get the source client, kept open until the task ends
+	sourceClient, ok := p.acquireSourceClient(source)
 	if !ok {
 		logger.Error().Str("source", string(source)).Msg("No source client registered for source")
 		return
 	}
+	defer p.releaseSourceClient(source, sourceClient)
 
 	// Parse the task
-	parsedTask, err := ParseTask(task)
+	parsedTask, err := protocol.ParseTask(task)
 	if err != nil {
 		logger.Error().Err(err).Str("topic", task.Topic).Msg("Error parsing task")
 		return
@@ -134,48 +214,28 @@ func (p *Processor) processTask(task Task) {
 		return
 	}
 
-	// Extract task info for reporting
+	// Get URL from task for reporting
 	var url string
-	var httpTaskType http.TaskType
-	var httpSourceType http.SourceType
-
-	// Convert task type and source to HTTP types
-	switch taskType {
-	case TaskTypeBook:
-		httpTaskType = http.TaskTypeBook
-	case TaskTypeChapter:
-		httpTaskType = http.TaskTypeChapter
-	case TaskTypeSession:
-		httpTaskType = http.TaskTypeSession
-	}
-
-	switch source {
-	case SourceTypeSangTacViet:
-		httpSourceType = http.SourceTypeSangTacViet
-	case SourceTypeWikiDich:
-		httpSourceType = http.SourceTypeWikiDich
-	case SourceTypeMetruyenchu:
-		httpSourceType = http.SourceTypeMetruyenchu
-	}
-
-	// Get URL from task
 	switch v := parsedTask.(type) {
-	case BookTask:
+	case protocol.BookTask:
 		url = v.BookURL
-	case ChapterTask:
+	case protocol.ChapterTask:
 		url = v.ChapterURL
-	case SessionTask:
+	case protocol.SessionTask:
 		url = v.URL
 	}
 
-	// Generate task ID for reporting
-	taskID := ""
+	// Report under the published task ID, tasks published without one get a generated ID
+	taskID := task.TaskID
 	if p.httpService != nil && p.httpService.IsReportingEnabled() {
-		taskID = http.GenerateTaskID(httpTaskType, httpSourceType, url)
+		if taskID == "" {
+			taskID = http.GenerateTaskID(taskType, source, url)
+		}
+		p.reportTaskStarted(taskID, taskType, source, url)
 	}
 
 	// Process the task
-	data, err := processor(parsedTask, sourceClient, p.spider)
+	data, err := processor(parsedTask, sourceClient.WebSource, p.spider)
 
 	// Report task result if control API is configured
 	if p.httpService != nil && p.httpService.IsReportingEnabled() {
@@ -184,7 +244,7 @@ func (p *Processor) processTask(task Task) {
 
 		if err != nil {
 			// Report error
-			if reportErr := taskSvc.ReportTaskError(ctx, taskID, httpTaskType, httpSourceType, url, err); reportErr != nil {
+			if reportErr := taskSvc.ReportTaskError(ctx, taskID, taskType, source, url, err); reportErr != nil {
 				logger.Error().Err(reportErr).Str("taskID", taskID).Msg("Error reporting task error")
 			}
 			logger.Error().Err(err).Str("taskID", taskID).Str("url", url).Msg("Error processing task")
@@ -192,7 +252,7 @@ func (p *Processor) processTask(task Task) {
 		}
 
 		// Report success
-		if reportErr := taskSvc.ReportTaskSuccess(ctx, taskID, httpTaskType, httpSourceType, url, data.(json.RawMessage)); reportErr != nil {
+		if reportErr := taskSvc.ReportTaskSuccess(ctx, taskID, taskType, source, url, data.(json.RawMessage)); reportErr != nil {
 			logger.Error().Err(reportErr).Str("taskID", taskID).Msg("Error reporting task success")
 		}
 	} else if err != nil {
@@ -201,6 +261,20 @@ func (p *Processor) processTask(task Task) {
 	}
 }
 
+// reportTaskStarted tells the control API that a task started, a failed report doesn't
+// stop the task
+func (p *Processor) reportTaskStarted(taskID string, taskType protocol.TaskType, source protocol.SourceType, url string) {
+	agentID := ""
+	if agent := p.httpService.GetAgent(); agent != nil {
+		agentID = agent.ID.String()
+	}
+
+	started := protocol.NewTaskStarted(taskID, taskType, source, url, agentID)
+	if err := p.httpService.GetTaskService().ReportTaskStarted(context.Background(), started); err != nil {
+		logger.Warn().Err(err).Str("taskID", taskID).Msg("Error reporting task start")
+	}
+}
+
 // ProcessTasks processes tasks with priority
 func (p *Processor) ProcessTasks() {
 	p.wg.Add(1)
@@ -233,9 +307,17 @@ func (p *Processor) Stop() {
 	p.wg.Wait()
 }
 
+// bookInfoExtractor returns the page callback extracting a book with the cover the
+// control API already stored
+func bookInfoExtractor(sourceClient source.WebSource, storedCoverURL string) func(string, *rod.Page, spider.TaskSpider) (any, error) {
+	return func(url string, page *rod.Page, hSpider spider.TaskSpider) (any, error) {
+		return sourceClient.ExtractBookInfo(url, storedCoverURL, page, hSpider)
+	}
+}
+
 func (p *Processor) RegisterDefaultTaskProcessors() {
-	p.RegisterTaskProcessor(TaskTypeBook, func(task any, sourceClient source.WebSource, spider spider.TaskSpider) (any, error) {
-		bookTask, ok := task.(BookTask)
+	p.RegisterTaskProcessor(protocol.TaskTypeBook, func(task any, sourceClient source.WebSource, spider spider.TaskSpider) (any, error) {
+		bookTask, ok := task.(protocol.BookTask)
 		if !ok {
 			return nil, fmt.Errorf("invalid task type, expected BookTask")
 		}
@@ -243,7 +325,7 @@ func (p *Processor) RegisterDefaultTaskProcessors() {
 		logger.Info().Interface("task", bookTask).Msg("Processing book task")
 
 		// Process the book URL using the spider
-		data, err := spider.ProcessPageWithCallback(bookTask.BookURL, sourceClient.ExtractBookInfo)
+		data, err := spider.ProcessPageWithCallback(bookTask.BookURL, bookInfoExtractor(sourceClient, bookTask.CoverURL))
 		if err != nil {
 			return nil, fmt.Errorf("error processing book task: %w", err)
 		}
@@ -252,8 +334,8 @@ func (p *Processor) RegisterDefaultTaskProcessors() {
 	})
 
 	// Register chapter task processor
-	p.RegisterTaskProcessor(TaskTypeChapter, func(task interface{}, sourceClient source.WebSource, spider spider.TaskSpider) (any, error) {
-		chapterTask, ok := task.(ChapterTask)
+	p.RegisterTaskProcessor(protocol.TaskTypeChapter, func(task interface{}, sourceClient source.WebSource, spider spider.TaskSpider) (any, error) {
+		chapterTask, ok := task.(protocol.ChapterTask)
 		if !ok {
 			return nil, fmt.Errorf("invalid task type, expected ChapterTask")
 		}
@@ -271,8 +353,8 @@ func (p *Processor) RegisterDefaultTaskProcessors() {
 	})
 
 	// Register session task processor
-	p.RegisterTaskProcessor(TaskTypeSession, func(task interface{}, sourceClient source.WebSource, spider spider.TaskSpider) (any, error) {
-		sessionTask, ok := task.(SessionTask)
+	p.RegisterTaskProcessor(protocol.TaskTypeSession, func(task interface{}, sourceClient source.WebSource, spider spider.TaskSpider) (any, error) {
+		sessionTask, ok := task.(protocol.SessionTask)
 		if !ok {
 			return nil, fmt.Errorf("invalid task type, expected SessionTask")
 		}
@@ -0,0 +1,64 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	"github.com/zrik/agent/appagent/internal/source"
+	"github.com/zrik/protocol"
+)
+
+// closingSource is a source client counting how often it was closed
+type closingSource struct {
+	source.WebSource
+	closed int
+}
+
+func (c *closingSource) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestRetiredSourceClientsClose(t *testing.T) {
+	p := &Processor{sourceClients: make(map[protocol.SourceType]*sourceClient)}
+	stv := protocol.SourceTypeSangTacViet
+
+	// Replaced without running tasks: closed at once
+	first, second := &closingSource{}, &closingSource{}
+	p.RegisterSourceClient(stv, first)
+	p.RegisterSourceClient(stv, second)
+	if first.closed != 1 || second.closed != 0 {
+		t.Fatalf("closed %d and %d times, want 1 and 0", first.closed, second.closed)
+	}
+
+	// Replaced during tasks: closed when the last one ends
+	running, ok := p.acquireSourceClient(stv)
+	if !ok || running.WebSource != second {
+		t.Fatal("acquired the wrong client")
+	}
+	again, _ := p.acquireSourceClient(stv)
+	third := &closingSource{}
+	p.RegisterSourceClient(stv, third)
+	p.releaseSourceClient(stv, running)
+	if second.closed != 0 {
+		t.Fatal("client closed while a task still uses it")
+	}
+	p.releaseSourceClient(stv, again)
+	if second.closed != 1 {
+		t.Fatalf("replaced client closed %d times after its tasks, want 1", second.closed)
+	}
+
+	// The registered client stays open after its tasks
+	current, _ := p.acquireSourceClient(stv)
+	p.releaseSourceClient(stv, current)
+	if third.closed != 0 {
+		t.Fatal("registered client closed after a task")
+	}
+
+	p.UnregisterSourceClient(stv)
+	if third.closed != 1 {
+		t.Fatalf("unregistered client closed %d times, want 1", third.closed)
+	}
+	if _, ok := p.acquireSourceClient(stv); ok {
+		t.Error("acquired an unregistered client")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/zrik/agent/appagent/pkg/config"
 	"github.com/zrik/agent/appagent/pkg/logger"
+	"github.com/zrik/protocol"
 )
 
 // Service represents a RabbitMQ service
@@ -19,14 +20,7 @@ type Service struct {
 	channel    *amqp.Channel
 	queue      amqp.Queue
 	closed     chan struct{}
-	tasks      chan Task
-}
-
-// Task represents a task to be processed
-type Task struct {
-	Topic   string          `json:"topic"`
-	Payload json.RawMessage `json:"payload"`
-	Source  SourceType      `json:"source"`
+	tasks      chan protocol.Task
 }
 
 // NewService creates a new RabbitMQ service
@@ -34,7 +28,7 @@ func NewService(cfg *config.RabbitMQConfig) *Service {
 	return &Service{
 		config: cfg,
 		closed: make(chan struct{}),
-		tasks:  make(chan Task, 100), // Buffer for 100 tasks
+		tasks:  make(chan protocol.Task, 100), // Buffer for 100 tasks
 	}
 }
 
@@ -167,7 +161,7 @@ func (s *Service) startConsuming() error {
 
 	go func() {
 		for d := range msgs {
-			var task Task
+			var task protocol.Task
 			err := json.Unmarshal(d.Body, &task)
 			if err != nil {
 				logger.Error().Err(err).Msg("Error parsing message")
@@ -211,7 +205,7 @@ func (s *Service) Start() error {
 }
 
 // GetTasks returns the tasks channel
-func (s *Service) GetTasks() <-chan Task {
+func (s *Service) GetTasks() <-chan protocol.Task {
 	return s.tasks
 }
 
@@ -231,7 +225,7 @@ func (s *Service) Close() error {
 }
 
 // PublishTask publishes a task to RabbitMQ
-func (s *Service) PublishTask(ctx context.Context, task Task) error {
+func (s *Service) PublishTask(ctx context.Context, task protocol.Task) error {
 	if s.channel == nil {
 		return errors.New("channel is nil, connection may be closed")
 	}
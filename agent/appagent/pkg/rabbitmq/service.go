@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	http "github.com/zrik/agent/appagent/pkg/http"
 	"github.com/zrik/agent/appagent/pkg/logger"
 	"github.com/zrik/agent/appagent/pkg/spider"
+	"github.com/zrik/protocol"
 )
 
 // AppService represents the main application service
@@ -19,25 +21,49 @@ type AppService struct {
 	config        *config.Config
 	rabbitMQ      *Service
 	spider        *spider.HeadSpider
-	sourceClients map[SourceType]source.WebSource
+	sourceClients map[protocol.SourceType]source.WebSource
 	processor     *Processor
 	httpService   http.IService
+
+	// Website synchronisation state
+	clientFactory SourceClientFactory
+	websites      map[protocol.SourceType]http.Website
+	syncMu        sync.Mutex
+	hupCh         chan os.Signal
+	ctx           context.Context
+	cancel        context.CancelFunc
 }
 
 // NewAppService creates a new application service
 func NewAppService(cfg *config.Config) *AppService {
+	// SIGHUP syncs the websites. It is caught from here on, so one received while the
+	// agent is starting doesn't terminate it and syncs once the service runs.
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+
 	// Create RabbitMQ service
 	rabbitMQ := NewService(&cfg.RabbitMQ)
 
 	// Create HTTP service if control API is configured
 	var httpService http.IService
 	if cfg.ControlAPI.BaseURL != "" {
-		httpService = http.NewService(&cfg.ControlAPI)
-		logger.Info().Str("baseURL", cfg.ControlAPI.BaseURL).Msg("Control API enabled")
+		switch cfg.ControlAPI.Transport {
+		case "grpc":
+			httpService = http.NewGRPCService(&cfg.ControlAPI)
+		case "", "http":
+			httpService = http.NewService(&cfg.ControlAPI)
+		default:
+			logger.Fatal().Str("transport", cfg.ControlAPI.Transport).Msg("Unknown control API transport")
+		}
+		logger.Info().Str("baseURL", cfg.ControlAPI.BaseURL).Str("transport", cfg.ControlAPI.Transport).Msg("Control API enabled")
 	}
 
 	// Create spider
 	spiderInstance := spider.NewHeadSpider(true, cfg)
+	if cfg.FixtureMode != "" {
+		spiderInstance.SetFixtureMode(spider.FixtureMode(cfg.FixtureMode), cfg.FixtureDir)
+		logger.Warn().Str("mode", cfg.FixtureMode).Str("dir", cfg.FixtureDir).Msg("Fixture mode enabled")
+	}
 	_, err := spiderInstance.CreatePage()
 	if err != nil {
 		logger.Error().Err(err).Msg("Error creating page")
@@ -54,23 +80,43 @@ func NewAppService(cfg *config.Config) *AppService {
 	// Register default task processors
 	processor.RegisterDefaultTaskProcessors()
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &AppService{
 		config:        cfg,
 		rabbitMQ:      rabbitMQ,
 		spider:        spiderInstance,
-		sourceClients: make(map[SourceType]source.WebSource),
+		sourceClients: make(map[protocol.SourceType]source.WebSource),
 		processor:     processor,
 		httpService:   httpService,
+		websites:      make(map[protocol.SourceType]http.Website),
+		hupCh:         hupCh,
+		ctx:           ctx,
+		cancel:        cancel,
 	}
 }
 
 // RegisterSourceClient registers a source client
-func (s *AppService) RegisterSourceClient(sourceType SourceType, client source.WebSource) {
+func (s *AppService) RegisterSourceClient(sourceType protocol.SourceType, client source.WebSource) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	s.registerSourceClient(sourceType, client)
+}
+
+// registerSourceClient registers a source client, the caller must hold syncMu
+func (s *AppService) registerSourceClient(sourceType protocol.SourceType, client source.WebSource) {
 	logger.Info().Str("source", string(sourceType)).Msg("Registering source client")
 	s.sourceClients[sourceType] = client
 	s.processor.RegisterSourceClient(sourceType, client)
 }
 
+// unregisterSourceClient unregisters a source client, the caller must hold syncMu
+func (s *AppService) unregisterSourceClient(sourceType protocol.SourceType) {
+	logger.Info().Str("source", string(sourceType)).Msg("Unregistering source client")
+	delete(s.sourceClients, sourceType)
+	s.processor.UnregisterSourceClient(sourceType)
+}
+
 // Start starts the application service
 func (s *AppService) Start() error {
 	logger.Info().Msg("Starting application service...")
@@ -83,6 +129,9 @@ func (s *AppService) Start() error {
 	s.processor.Start()
 	s.processor.ProcessTasks()
 
+	// Keep source clients in sync with the control API
+	go s.runWebsiteSync(s.config.ControlAPI.WebsiteSyncInterval * time.Second)
+
 	// Handle graceful shutdown
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -90,7 +139,8 @@ func (s *AppService) Start() error {
 	<-sigCh
 	logger.Info().Msg("Received shutdown signal, gracefully shutting down...")
 
-	// Stop processor
+	// Stop website sync and processor
+	s.cancel()
 	s.processor.Stop()
 
 	// Close RabbitMQ connection
@@ -109,7 +159,8 @@ func (s *AppService) Start() error {
 
 // Stop stops the application service
 func (s *AppService) Stop() {
-	// Stop processor
+	// Stop website sync and processor
+	s.cancel()
 	s.processor.Stop()
 
 	// Close RabbitMQ connection
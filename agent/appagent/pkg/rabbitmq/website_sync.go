@@ -0,0 +1,117 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/signal"
+	"time"
+
+	"github.com/zrik/agent/appagent/internal/source"
+	http "github.com/zrik/agent/appagent/pkg/http"
+	"github.com/zrik/agent/appagent/pkg/logger"
+	"github.com/zrik/protocol"
+)
+
+// SourceClientFactory creates a source client for a website.
+// It returns false when the website's script is not supported by this agent.
+type SourceClientFactory func(website http.Website) (source.WebSource, bool)
+
+// SetSourceClientFactory sets the factory used to create source clients when syncing websites
+func (s *AppService) SetSourceClientFactory(factory SourceClientFactory) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	s.clientFactory = factory
+}
+
+// SyncWebsites fetches the websites from the control API and registers, replaces or
+// unregisters source clients so that the processor matches the current configuration.
+// Tasks that are already running keep using the client they started with.
+func (s *AppService) SyncWebsites(ctx context.Context) error {
+	if s.httpService == nil {
+		return errors.New("control API is not configured")
+	}
+
+	websites, err := s.httpService.GetWebsiteService().GetWebsites(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get websites: %w", err)
+	}
+
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	if s.clientFactory == nil {
+		return errors.New("source client factory is not set")
+	}
+
+	wanted := make(map[protocol.SourceType]http.Website)
+	for _, website := range websites {
+		if !website.Enabled {
+			continue
+		}
+		wanted[protocol.SourceType(website.ScriptName)] = website
+	}
+
+	// Register new clients and replace the ones whose website changed
+	for sourceType, website := range wanted {
+		if current, ok := s.websites[sourceType]; ok && current == website {
+			continue
+		}
+
+		client, ok := s.clientFactory(website)
+		if !ok {
+			logger.Debug().Str("script", website.ScriptName).Msg("No source client available for website")
+			continue
+		}
+
+		s.registerSourceClient(sourceType, client)
+		s.websites[sourceType] = website
+	}
+
+	// Unregister clients for websites that were removed or disabled
+	for sourceType := range s.websites {
+		if _, ok := wanted[sourceType]; !ok {
+			s.unregisterSourceClient(sourceType)
+			delete(s.websites, sourceType)
+		}
+	}
+
+	return nil
+}
+
+// runWebsiteSync re-syncs websites every interval, whenever the process receives SIGHUP and
+// when the control API pushes a sync command. A zero interval disables the periodic sync.
+func (s *AppService) runWebsiteSync(interval time.Duration) {
+	defer signal.Stop(s.hupCh)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var commands <-chan http.Command
+	if s.httpService != nil {
+		commands = s.httpService.Commands()
+	}
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-tick:
+		case <-s.hupCh:
+			logger.Info().Msg("Received SIGHUP, syncing websites")
+		case cmd := <-commands:
+			if cmd.Type != http.CommandSyncWebsites {
+				continue
+			}
+			logger.Info().Str("reason", cmd.Reason).Msg("Control API asked to sync websites")
+		}
+
+		if err := s.SyncWebsites(s.ctx); err != nil {
+			logger.Error().Err(err).Msg("Error syncing websites")
+		}
+	}
+}
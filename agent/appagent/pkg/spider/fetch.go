@@ -0,0 +1,56 @@
+package spider
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/go-rod/rod"
+)
+
+// MaxFetchSize is the maximum size of a resource downloaded with FetchResource
+const MaxFetchSize = 10 << 20
+
+// FetchResource downloads a resource from inside the page so that the request carries the
+// page's cookies and referer, which gets past hotlink protection. It returns the body and
+// the content type reported by the server.
+func FetchResource(page *rod.Page, url string) ([]byte, string, error) {
+	result, err := page.Eval(`
+		async (url, maxSize) => {
+			try {
+				const resp = await fetch(url, { credentials: "include" });
+				if (!resp.ok) {
+					return { error: "status " + resp.status };
+				}
+
+				const blob = await resp.blob();
+				if (blob.size > maxSize) {
+					return { error: "resource too large: " + blob.size + " bytes" };
+				}
+
+				const dataUrl = await new Promise((resolve, reject) => {
+					const reader = new FileReader();
+					reader.onload = () => resolve(reader.result);
+					reader.onerror = () => reject(reader.error);
+					reader.readAsDataURL(blob);
+				});
+				return { type: blob.type, data: dataUrl.substring(dataUrl.indexOf(",") + 1) };
+			} catch (err) {
+				return { error: String(err) };
+			}
+		}
+	`, url, MaxFetchSize)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+
+	if msg := result.Value.Get("error").String(); msg != "" {
+		return nil, "", fmt.Errorf("failed to fetch %s: %s", url, msg)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(result.Value.Get("data").String())
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode %s: %w", url, err)
+	}
+
+	return data, result.Value.Get("type").String(), nil
+}
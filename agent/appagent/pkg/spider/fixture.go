@@ -0,0 +1,376 @@
+package spider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/zrik/agent/appagent/pkg/logger"
+)
+
+// FixtureMode selects whether a HeadSpider records or replays task fixtures
+type FixtureMode string
+
+const (
+	// FixtureModeOff loads pages from the network
+	FixtureModeOff FixtureMode = ""
+	// FixtureModeRecord loads pages from the network and saves every response of a task
+	FixtureModeRecord FixtureMode = "record"
+	// FixtureModeReplay serves every request of a task from its fixture, without network
+	FixtureModeReplay FixtureMode = "replay"
+)
+
+// fixtureIndexFile is the file listing the responses of a fixture
+const fixtureIndexFile = "fixture.json"
+
+// Fixture holds the responses recorded while processing a task
+type Fixture struct {
+	// URL is the task URL
+	URL     string          `json:"url"`
+	Entries []*FixtureEntry `json:"entries"`
+}
+
+// FixtureEntry is one recorded response. The body is stored next to the index in BodyFile.
+type FixtureEntry struct {
+	Method       string            `json:"method"`
+	URL          string            `json:"url"`
+	ResourceType string            `json:"resource_type,omitempty"`
+	Status       int               `json:"status"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	BodyFile     string            `json:"body_file,omitempty"`
+}
+
+var fixtureNameReplacer = regexp.MustCompile(`[^a-z0-9]+`)
+
+// FixtureName returns the name of the fixture directory of a task URL
+func FixtureName(taskURL string) string {
+	name := strings.ToLower(taskURL)
+	if u, err := url.Parse(taskURL); err == nil && u.Host != "" {
+		name = strings.ToLower(u.Host + u.Path)
+		if u.RawQuery != "" {
+			name += "-" + u.RawQuery
+		}
+	}
+	return strings.Trim(fixtureNameReplacer.ReplaceAllString(name, "-"), "-")
+}
+
+// LoadFixture loads the fixture index stored in dir
+func LoadFixture(dir string) (*Fixture, error) {
+	data, err := os.ReadFile(filepath.Join(dir, fixtureIndexFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture: %w", err)
+	}
+	return &fixture, nil
+}
+
+// SetFixtureMode makes the spider record or replay the responses of every task
+// in a sub-directory of dir named after the task URL
+func (s *HeadSpider) SetFixtureMode(mode FixtureMode, dir string) {
+	s.fixtureMode = mode
+	s.fixtureDir = dir
+}
+
+// startFixture starts recording or replaying the responses of the task at taskURL
+// on page. The returned function stops it and must be called once the task is done.
+func (s *HeadSpider) startFixture(page *rod.Page, taskURL string) (func(), error) {
+	dir := filepath.Join(s.fixtureDir, FixtureName(taskURL))
+
+	switch s.fixtureMode {
+	case FixtureModeOff:
+		return func() {}, nil
+	case FixtureModeRecord:
+		return recordFixture(page, taskURL, dir)
+	case FixtureModeReplay:
+		return replayFixture(page, dir)
+	default:
+		return nil, fmt.Errorf("unknown fixture mode: %s", s.fixtureMode)
+	}
+}
+
+// fixtureRecorder collects the responses received by a page
+type fixtureRecorder struct {
+	page    *rod.Page
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	methods map[proto.NetworkRequestID]string
+	pending map[proto.NetworkRequestID]*FixtureEntry
+	entries []*FixtureEntry
+	bodies  map[*FixtureEntry][]byte
+}
+
+// recordFixture records every response received by page until the returned function
+// is called, which writes the fixture to dir
+func recordFixture(page *rod.Page, taskURL, dir string) (func(), error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+
+	r := &fixtureRecorder{
+		page:    page,
+		methods: make(map[proto.NetworkRequestID]string),
+		pending: make(map[proto.NetworkRequestID]*FixtureEntry),
+		bodies:  make(map[*FixtureEntry][]byte),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wait := page.Context(ctx).EachEvent(
+		func(e *proto.NetworkRequestWillBeSent) {
+			r.mu.Lock()
+			r.methods[e.RequestID] = e.Request.Method
+			r.mu.Unlock()
+		},
+		func(e *proto.NetworkResponseReceived) {
+			r.onResponse(e)
+		},
+		func(e *proto.NetworkLoadingFinished) {
+			r.onFinished(e.RequestID)
+		},
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wait()
+	}()
+
+	return func() {
+		cancel()
+		<-done
+		r.wg.Wait()
+		if err := r.write(taskURL, dir); err != nil {
+			logger.Error().Err(err).Str("dir", dir).Msg("Failed to write fixture")
+			return
+		}
+		logger.Info().Str("dir", dir).Int("responses", len(r.entries)).Msg("Recorded fixture")
+	}, nil
+}
+
+// onResponse records the status and headers of a response
+func (r *fixtureRecorder) onResponse(e *proto.NetworkResponseReceived) {
+	if e.Response == nil || strings.HasPrefix(e.Response.URL, "data:") {
+		return
+	}
+
+	headers := make(map[string]string, len(e.Response.Headers))
+	for name, value := range e.Response.Headers {
+		headers[strings.ToLower(name)] = value.String()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	method := r.methods[e.RequestID]
+	if method == "" {
+		method = "GET"
+	}
+	r.pending[e.RequestID] = &FixtureEntry{
+		Method:       method,
+		URL:          e.Response.URL,
+		ResourceType: string(e.Type),
+		Status:       e.Response.Status,
+		Headers:      headers,
+	}
+}
+
+// onFinished fetches the body of a response once it is fully loaded
+func (r *fixtureRecorder) onFinished(id proto.NetworkRequestID) {
+	r.mu.Lock()
+	entry, ok := r.pending[id]
+	delete(r.pending, id)
+	delete(r.methods, id)
+	if ok {
+		r.entries = append(r.entries, entry)
+	}
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		res, err := proto.NetworkGetResponseBody{RequestID: id}.Call(r.page)
+		if err != nil {
+			logger.Debug().Err(err).Str("url", entry.URL).Msg("Failed to get response body")
+			return
+		}
+
+		body := []byte(res.Body)
+		if res.Base64Encoded {
+			if body, err = base64.StdEncoding.DecodeString(res.Body); err != nil {
+				return
+			}
+		}
+
+		r.mu.Lock()
+		r.bodies[entry] = body
+		r.mu.Unlock()
+	}()
+}
+
+// write stores the fixture index and bodies in dir
+func (r *fixtureRecorder) write(taskURL, dir string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, entry := range r.entries {
+		body, ok := r.bodies[entry]
+		if !ok {
+			continue
+		}
+		entry.BodyFile = fmt.Sprintf("%03d%s", i+1, bodyExtension(entry.Headers["content-type"]))
+		if err := os.WriteFile(filepath.Join(dir, entry.BodyFile), body, 0o644); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(Fixture{URL: taskURL, Entries: r.entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, fixtureIndexFile), data, 0o644)
+}
+
+// bodyExtension returns the file extension used to store a body of contentType
+func bodyExtension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/html":
+		return ".html"
+	case "application/json", "text/json":
+		return ".json"
+	case "text/javascript", "application/javascript":
+		return ".js"
+	case "text/css":
+		return ".css"
+	case "text/plain":
+		return ".txt"
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// replayHeaderSkip are response headers that don't apply to a replayed body
+var replayHeaderSkip = map[string]bool{
+	"content-encoding":  true,
+	"content-length":    true,
+	"transfer-encoding": true,
+}
+
+// fixtureReplayer serves the responses of a fixture
+type fixtureReplayer struct {
+	mu      sync.Mutex
+	byURL   map[string][]*FixtureEntry
+	byPath  map[string][]*FixtureEntry
+	bodies  map[*FixtureEntry][]byte
+	dirName string
+}
+
+// replayFixture serves every request of page from the fixture in dir. Requests that
+// are not in the fixture fail as if the network was down.
+func replayFixture(page *rod.Page, dir string) (func(), error) {
+	fixture, err := LoadFixture(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fixture: %w", err)
+	}
+
+	r := &fixtureReplayer{
+		byURL:   make(map[string][]*FixtureEntry),
+		byPath:  make(map[string][]*FixtureEntry),
+		bodies:  make(map[*FixtureEntry][]byte),
+		dirName: filepath.Base(dir),
+	}
+	for _, entry := range fixture.Entries {
+		if entry.BodyFile != "" {
+			body, err := os.ReadFile(filepath.Join(dir, entry.BodyFile))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read fixture body: %w", err)
+			}
+			r.bodies[entry] = body
+		}
+		key := entry.Method + " " + entry.URL
+		r.byURL[key] = append(r.byURL[key], entry)
+		pathKey := entry.Method + " " + withoutQuery(entry.URL)
+		r.byPath[pathKey] = append(r.byPath[pathKey], entry)
+	}
+
+	router := page.HijackRequests()
+	if err := router.Add("*", "", r.serve); err != nil {
+		return nil, fmt.Errorf("failed to hijack requests: %w", err)
+	}
+	go router.Run()
+
+	return func() {
+		_ = router.Stop()
+	}, nil
+}
+
+// serve responds to a hijacked request with the matching fixture entry
+func (r *fixtureReplayer) serve(ctx *rod.Hijack) {
+	reqURL := ctx.Request.URL().String()
+	if strings.HasPrefix(reqURL, "data:") {
+		ctx.ContinueRequest(&proto.FetchContinueRequest{})
+		return
+	}
+
+	// Requests with cache busting parameters fall back to the same path
+	entry := r.next(r.byURL, ctx.Request.Method()+" "+reqURL)
+	if entry == nil {
+		entry = r.next(r.byPath, ctx.Request.Method()+" "+withoutQuery(reqURL))
+	}
+	if entry == nil {
+		logger.Debug().Str("fixture", r.dirName).Str("url", reqURL).Msg("Request not in fixture")
+		ctx.Response.Fail(proto.NetworkErrorReasonInternetDisconnected)
+		return
+	}
+
+	ctx.Response.Payload().ResponseCode = entry.Status
+	for name, value := range entry.Headers {
+		if !replayHeaderSkip[name] {
+			ctx.Response.SetHeader(name, value)
+		}
+	}
+	ctx.Response.SetBody(r.bodies[entry])
+}
+
+// next returns the next entry recorded for key. Entries are served in recorded
+// order, the last one is repeated once all have been served.
+func (r *fixtureReplayer) next(entries map[string][]*FixtureEntry, key string) *FixtureEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := entries[key]
+	if len(list) == 0 {
+		return nil
+	}
+	entry := list[0]
+	if len(list) > 1 {
+		entries[key] = list[1:]
+	}
+	return entry
+}
+
+// withoutQuery strips the query and fragment of a URL
+func withoutQuery(rawURL string) string {
+	if i := strings.IndexAny(rawURL, "?#"); i >= 0 {
+		return rawURL[:i]
+	}
+	return rawURL
+}
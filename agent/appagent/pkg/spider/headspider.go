@@ -28,6 +28,9 @@ type HeadSpider struct {
 	captchaHandler    CaptchaHandler
 	sessionData       *SessionData
 	sessionFile       string
+	headless          bool
+	fixtureMode       FixtureMode
+	fixtureDir        string
 }
 
 // CreatePage creates a new page
@@ -105,7 +108,7 @@ func (s *HeadSpider) InitBrowser() error {
 
 	s.browserLauncher.Set("enable-features", "NetworkService,NetworkServiceInProcess")
 	s.browserLauncher.Set("user-agent", userAgent)
-	s.browserLauncher.Headless(false)
+	s.browserLauncher.Headless(s.headless)
 
 	url := s.browserLauncher.MustLaunch()
 	s.browser = rod.New().ControlURL(url).MustConnect()
@@ -149,6 +152,11 @@ func (s *HeadSpider) SetBrowserPath(path string) {
 	s.browserPath = path
 }
 
+// SetHeadless runs the browser without a window, it must be set before the browser starts
+func (s *HeadSpider) SetHeadless(headless bool) {
+	s.headless = headless
+}
+
 func (s *HeadSpider) SetBrowserTimeout(timeout time.Duration) {
 	s.browserTimeout = timeout
 }
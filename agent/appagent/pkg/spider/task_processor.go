@@ -66,6 +66,14 @@ func (s *HeadSpider) ProcessPageWithCallback(url string, callback func(url strin
 		return nil, fmt.Errorf("error creating page: %w", err)
 	}
 
+	// Record or replay the responses of the task when a fixture mode is set
+	stopFixture, err := s.startFixture(page, url)
+	if err != nil {
+		page.Close()
+		return nil, fmt.Errorf("error starting fixture: %w", err)
+	}
+	defer stopFixture()
+
 	// Navigate to the URL
 	if err := page.Navigate(url); err != nil {
 		return nil, fmt.Errorf("error navigating to URL: %w", err)
@@ -0,0 +1,53 @@
+// Command content-migrate moves the content of existing chapters between inline storage
+// in the chapters table and compressed storage in chapter_contents.
+//
+//	go run ./cmd/content-migrate -to compressed
+//	go run ./cmd/content-migrate -to inline
+package main
+
+import (
+	"flag"
+	"log" // Standard log for initialization only
+
+	"cct/config"
+	"cct/models"
+	"cct/pkg/logger"
+	"cct/utils"
+)
+
+func main() {
+	to := flag.String("to", models.ContentStorageCompressed, "storage to move chapter content to: inline or compressed")
+	batch := flag.Int("batch", 500, "chapters moved per transaction")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if err := logger.Init(&cfg.Logging); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	if *batch <= 0 {
+		logger.Fatal().Int("batch", *batch).Msg("Batch size must be positive")
+	}
+
+	if err := utils.InitDB(&cfg.Database); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to initialize database")
+	}
+	defer utils.CloseDB()
+
+	total := 0
+	for {
+		moved, err := models.MigrateChapterContent(*to, *batch)
+		if err != nil {
+			logger.Fatal().Err(err).Int("moved", total).Msg("Failed to migrate chapter content")
+		}
+		if moved == 0 {
+			break
+		}
+		total += moved
+		logger.Info().Int("moved", total).Str("to", *to).Msg("Migrated chapter content")
+	}
+
+	logger.Info().Int("moved", total).Str("to", *to).Msg("Chapter content migration complete")
+}
@@ -16,6 +16,12 @@ type Config struct {
 	Logging   LoggingConfig   `mapstructure:"logging"`
 	RabbitMQ  RabbitMQConfig  `mapstructure:"rabbitmq"`
 	Scheduler SchedulerConfig `mapstructure:"scheduler"`
+	Storage   StorageConfig   `mapstructure:"storage"`
+	Chapters  ChaptersConfig  `mapstructure:"chapters"`
+	Export    ExportConfig    `mapstructure:"export"`
+	GRPC      GRPCConfig      `mapstructure:"grpc"`
+	Results   ResultsConfig   `mapstructure:"results"`
+	Webhooks  WebhooksConfig  `mapstructure:"webhooks"`
 }
 
 // ServerConfig holds all server-related configuration
@@ -24,6 +30,9 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
 	WriteTimeout time.Duration `mapstructure:"write_timeout"`
 	IdleTimeout  time.Duration `mapstructure:"idle_timeout"`
+	// MaxBodySize bounds a request body as sent, MaxDecodedBodySize once a gzip body is decoded
+	MaxBodySize        int64 `mapstructure:"max_body_size"`         // bytes
+	MaxDecodedBodySize int64 `mapstructure:"max_decoded_body_size"` // bytes
 }
 
 // DatabaseConfig holds all database-related configuration
@@ -58,6 +67,77 @@ type LoggingConfig struct {
 type SchedulerConfig struct {
 	Enabled       bool `mapstructure:"enabled"`
 	CheckInterval int  `mapstructure:"check_interval"` // seconds
+	// CompletedInterval is the interval of the schedules of completed novels once every
+	// chapter has content, 0 disables them
+	CompletedInterval int `mapstructure:"completed_interval"` // seconds
+}
+
+// ChaptersConfig holds chapter content configuration
+type ChaptersConfig struct {
+	// RevisionRetention is the number of previous versions kept per chapter, 0 keeps all of them
+	RevisionRetention int `mapstructure:"revision_retention"`
+	// ContentStorage is where new content is written: inline in the chapters table or
+	// compressed in chapter_contents. Content is read from either.
+	ContentStorage string `mapstructure:"content_storage"`
+}
+
+// ExportConfig holds novel export configuration
+type ExportConfig struct {
+	// Dir stores the exports built in the background
+	Dir string `mapstructure:"dir"`
+	// AsyncThreshold is the chapter count above which exports are built in the background
+	AsyncThreshold int `mapstructure:"async_threshold"`
+	// Workers is the number of background exports built at once
+	Workers int `mapstructure:"workers"`
+	// WriteTimeout bounds the time to stream an export, it replaces the server write timeout
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+}
+
+// GRPCConfig holds the configuration of the gRPC control API for agents
+type GRPCConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	Port    int  `mapstructure:"port"`
+	// MaxMessageSize bounds a received message, a task result with its data
+	MaxMessageSize int `mapstructure:"max_message_size"` // bytes
+	// TLSCertFile and TLSKeyFile are the PEM certificate and key served over TLS
+	TLSCertFile string `mapstructure:"tls_cert_file"`
+	TLSKeyFile  string `mapstructure:"tls_key_file"`
+	// Insecure serves plaintext instead of TLS, API keys are then sent in the clear
+	Insecure bool `mapstructure:"insecure"`
+}
+
+// ResultsConfig holds task result configuration
+type ResultsConfig struct {
+	// ProcessedTTL is how long a processed task ID is kept, a result repeated within it
+	// returns the stored outcome instead of being applied again
+	ProcessedTTL time.Duration `mapstructure:"processed_ttl"`
+	// ProcessingTimeout is after how long a result still being processed, by a request that
+	// died, may be applied by a repeated one
+	ProcessingTimeout time.Duration `mapstructure:"processing_timeout"`
+	// ReadTimeout bounds the time to receive a result or a chunk, it replaces the server read timeout
+	ReadTimeout time.Duration `mapstructure:"read_timeout"`
+	// MaxUploadSize bounds a result uploaded in chunks once its chunks are joined
+	MaxUploadSize int64 `mapstructure:"max_upload_size"` // bytes
+	// UploadTTL is how long the chunks of an upload whose final chunk never came are kept
+	UploadTTL time.Duration `mapstructure:"upload_ttl"`
+	// UnhealthyAfter is the number of consecutive failed tasks after which a website is
+	// announced unhealthy, 0 disables it
+	UnhealthyAfter int `mapstructure:"unhealthy_after"`
+}
+
+// WebhooksConfig holds the configuration of outbound webhook deliveries
+type WebhooksConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Workers is the number of deliveries sent at the same time
+	Workers int           `mapstructure:"workers"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	// MaxAttempts bounds the attempts to deliver an event, retries wait RetryDelay doubled
+	// after every attempt, up to MaxRetryDelay
+	MaxAttempts   int           `mapstructure:"max_attempts"`
+	RetryDelay    time.Duration `mapstructure:"retry_delay"`
+	MaxRetryDelay time.Duration `mapstructure:"max_retry_delay"`
+	// DeliveryTTL is how long the delivery attempts are logged
+	DeliveryTTL time.Duration `mapstructure:"delivery_ttl"`
 }
 
 // Load loads the configuration from config.yml
@@ -94,6 +174,15 @@ func Load() (*Config, error) {
 	config.Server.IdleTimeout = time.Duration(config.Server.IdleTimeout) * time.Second
 	config.Auth.TokenExpiry = time.Duration(config.Auth.TokenExpiry) * time.Hour
 	config.RabbitMQ.ReconnectInterval = time.Duration(config.RabbitMQ.ReconnectInterval) * time.Second
+	config.Export.WriteTimeout = time.Duration(config.Export.WriteTimeout) * time.Second
+	config.Results.ProcessedTTL = time.Duration(config.Results.ProcessedTTL) * time.Second
+	config.Results.ProcessingTimeout = time.Duration(config.Results.ProcessingTimeout) * time.Second
+	config.Results.ReadTimeout = time.Duration(config.Results.ReadTimeout) * time.Second
+	config.Results.UploadTTL = time.Duration(config.Results.UploadTTL) * time.Second
+	config.Webhooks.Timeout = time.Duration(config.Webhooks.Timeout) * time.Second
+	config.Webhooks.RetryDelay = time.Duration(config.Webhooks.RetryDelay) * time.Second
+	config.Webhooks.MaxRetryDelay = time.Duration(config.Webhooks.MaxRetryDelay) * time.Second
+	config.Webhooks.DeliveryTTL = time.Duration(config.Webhooks.DeliveryTTL) * time.Second
 
 	return &config, nil
 }
@@ -105,6 +194,8 @@ func setDefaults() {
 	viper.SetDefault("server.read_timeout", 15)
 	viper.SetDefault("server.write_timeout", 15)
 	viper.SetDefault("server.idle_timeout", 60)
+	viper.SetDefault("server.max_body_size", 32<<20)          // bytes
+	viper.SetDefault("server.max_decoded_body_size", 128<<20) // bytes
 
 	// Database defaults
 	viper.SetDefault("database.host", "localhost")
@@ -139,7 +230,46 @@ func setDefaults() {
 
 	// Scheduler defaults
 	viper.SetDefault("scheduler.enabled", true)
-	viper.SetDefault("scheduler.check_interval", 60) // seconds
+	viper.SetDefault("scheduler.check_interval", 60)         // seconds
+	viper.SetDefault("scheduler.completed_interval", 604800) // seconds, 7 days
+
+	// Storage defaults
+	viper.SetDefault("storage.driver", "local")
+	viper.SetDefault("storage.local_path", "data/blobs")
+	viper.SetDefault("storage.max_cover_size", 5<<20) // bytes
+
+	// Chapters defaults
+	viper.SetDefault("chapters.revision_retention", 20)
+	viper.SetDefault("chapters.content_storage", "compressed")
+
+	// Export defaults
+	viper.SetDefault("export.dir", "data/exports")
+	viper.SetDefault("export.async_threshold", 500)
+	viper.SetDefault("export.workers", 2)
+	viper.SetDefault("export.write_timeout", 600) // seconds
+
+	// gRPC defaults
+	viper.SetDefault("grpc.enabled", false)
+	viper.SetDefault("grpc.port", 9090)
+	viper.SetDefault("grpc.max_message_size", 32<<20) // bytes
+	viper.SetDefault("grpc.insecure", false)
+
+	// Results defaults
+	viper.SetDefault("results.processed_ttl", 86400)     // seconds, 1 day
+	viper.SetDefault("results.processing_timeout", 600)  // seconds
+	viper.SetDefault("results.read_timeout", 120)        // seconds
+	viper.SetDefault("results.max_upload_size", 256<<20) // bytes
+	viper.SetDefault("results.upload_ttl", 3600)         // seconds
+	viper.SetDefault("results.unhealthy_after", 5)
+
+	// Webhooks defaults
+	viper.SetDefault("webhooks.enabled", true)
+	viper.SetDefault("webhooks.workers", 4)
+	viper.SetDefault("webhooks.timeout", 10) // seconds
+	viper.SetDefault("webhooks.max_attempts", 6)
+	viper.SetDefault("webhooks.retry_delay", 30)       // seconds
+	viper.SetDefault("webhooks.max_retry_delay", 3600) // seconds
+	viper.SetDefault("webhooks.delivery_ttl", 604800)  // seconds, 7 days
 }
 
 // GetDSN returns the database connection string
@@ -0,0 +1,8 @@
+package config
+
+// StorageConfig holds the configuration for blob storage
+type StorageConfig struct {
+	Driver       string `mapstructure:"driver"`
+	LocalPath    string `mapstructure:"local_path"`
+	MaxCoverSize int64  `mapstructure:"max_cover_size"`
+}
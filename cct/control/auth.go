@@ -0,0 +1,52 @@
+package control
+
+import (
+	"context"
+	"errors"
+
+	"cct/middleware"
+	"cct/pkg/logger"
+
+	"github.com/zrik/protocol/controlpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authUnary checks the API key of unary calls
+func authUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := authenticate(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authStream checks the API key of streaming calls
+func authStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := authenticate(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// authenticate validates the API key in the metadata of a call like the Api-Key header
+func authenticate(ctx context.Context, method string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get(controlpb.APIKeyMetadata)
+	if len(keys) == 0 || keys[0] == "" {
+		logger.Debug().Str("method", method).Msg("Missing api-key metadata")
+		return status.Error(codes.Unauthenticated, "api-key metadata is required")
+	}
+
+	_, err := middleware.ValidateToken(keys[0])
+	switch {
+	case errors.Is(err, middleware.ErrInvalidToken), errors.Is(err, middleware.ErrTokenExpired):
+		logger.Debug().Str("method", method).Err(err).Msg("Rejected api-key")
+		return status.Error(codes.Unauthenticated, err.Error())
+	case err != nil:
+		logger.Error().Err(err).Str("method", method).Msg("Failed to validate token")
+		return status.Error(codes.Internal, "failed to validate token: "+err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+// Package control serves the gRPC control API of the agents, see protocol/controlpb.
+// It is an alternative to the agent REST endpoints and shares their handling.
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"cct/config"
+	"cct/handlers"
+	"cct/models"
+	"cct/pkg/events"
+	"cct/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/zrik/protocol"
+	"github.com/zrik/protocol/controlpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // accept gzip compressed calls
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// commandBuffer is the number of commands queued per connected agent
+const commandBuffer = 16
+
+// Server is the gRPC control API
+type Server struct {
+	controlpb.UnimplementedAgentControlServer
+
+	cfg    *config.Config
+	server *grpc.Server
+
+	// agents holds the command queue of every agent with an open heartbeat stream
+	mu     sync.Mutex
+	agents map[uuid.UUID]chan *controlpb.Command
+
+	unsubscribe func()
+	// done ends the heartbeat streams when the server stops
+	done chan struct{}
+}
+
+// NewServer creates the gRPC control API, calls are authenticated with an API key in the
+// api-key metadata when auth is enabled. It serves TLS with the configured certificate,
+// plaintext only when insecure is set.
+func NewServer(cfg *config.Config) (*Server, error) {
+	s := &Server{
+		cfg:    cfg,
+		agents: make(map[uuid.UUID]chan *controlpb.Command),
+		done:   make(chan struct{}),
+	}
+
+	opts := []grpc.ServerOption{grpc.MaxRecvMsgSize(cfg.GRPC.MaxMessageSize)}
+	if !cfg.GRPC.Insecure {
+		if cfg.GRPC.TLSCertFile == "" || cfg.GRPC.TLSKeyFile == "" {
+			return nil, errors.New("grpc.tls_cert_file and grpc.tls_key_file are required unless grpc.insecure is set")
+		}
+		creds, err := credentials.NewServerTLSFromFile(cfg.GRPC.TLSCertFile, cfg.GRPC.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	if cfg.Auth.Enabled {
+		opts = append(opts, grpc.UnaryInterceptor(authUnary), grpc.StreamInterceptor(authStream))
+	}
+	s.server = grpc.NewServer(opts...)
+	controlpb.RegisterAgentControlServer(s.server, s)
+	return s, nil
+}
+
+// Start listens on the configured port and serves in the background
+func (s *Server) Start() error {
+	addr := ":" + strconv.Itoa(s.cfg.GRPC.Port)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	// Agent and website changes are pushed to the connected agents
+	var ch <-chan events.Event
+	ch, s.unsubscribe = events.Subscribe(0)
+	go s.pushEvents(ch)
+
+	go func() {
+		if err := s.server.Serve(lis); err != nil {
+			logger.Error().Err(err).Msg("gRPC server stopped")
+		}
+	}()
+
+	logger.Info().Str("addr", addr).Bool("tls", !s.cfg.GRPC.Insecure).Msg("gRPC control API started")
+	return nil
+}
+
+// Stop closes the heartbeat streams and stops the server once the running uploads end
+func (s *Server) Stop() {
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+	}
+	close(s.done)
+	s.server.GracefulStop()
+}
+
+// Register returns the active agent with the name and IP address of the caller
+func (s *Server) Register(ctx context.Context, req *controlpb.RegisterRequest) (*controlpb.Agent, error) {
+	if req.GetName() == "" || req.GetIpAddress() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name and ip_address are required")
+	}
+
+	agents, err := models.GetAgents(true, req.GetIpAddress(), req.GetName())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to get agent: "+err.Error())
+	}
+	if len(agents) != 1 {
+		return nil, status.Errorf(codes.NotFound, "expected 1 active agent named %q at %s, got %d",
+			req.GetName(), req.GetIpAddress(), len(agents))
+	}
+
+	logger.Info().Str("agent_id", agents[0].ID.String()).Str("name", agents[0].Name).Msg("Agent registered")
+	return agentMessage(agents[0]), nil
+}
+
+// Heartbeat records the heartbeats of an agent and pushes its commands. The agent is told
+// to pause when it is deactivated and to resume when it is activated again.
+func (s *Server) Heartbeat(stream controlpb.AgentControl_HeartbeatServer) error {
+	ctx := stream.Context()
+
+	req, err := stream.Recv()
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	id, err := uuid.Parse(req.GetAgentId())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid agent ID")
+	}
+
+	commands := s.connect(id)
+	defer s.disconnect(id, commands)
+
+	// Heartbeats are received in the background so commands are all sent from here
+	heartbeats := make(chan error)
+	go func() {
+		for {
+			_, err := stream.Recv()
+			select {
+			case heartbeats <- err:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// The agent is active when it registers
+	active := true
+	setActive := func(isActive bool, reason string) error {
+		if isActive == active {
+			return nil
+		}
+		active = isActive
+		cmd := &controlpb.Command{Type: controlpb.Command_TYPE_RESUME, Reason: reason}
+		if !isActive {
+			cmd.Type = controlpb.Command_TYPE_PAUSE
+		}
+		return stream.Send(cmd)
+	}
+	beat := func() error {
+		if err := models.UpdateAgentHeartbeat(id); err != nil {
+			logger.Error().Err(err).Str("agent_id", id.String()).Msg("Failed to update agent heartbeat")
+		}
+		agent, err := models.GetAgent(id)
+		if err != nil {
+			return status.Error(codes.NotFound, err.Error())
+		}
+		return setActive(agent.IsActive, "agent status changed")
+	}
+
+	if err := beat(); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.done:
+			return status.Error(codes.Unavailable, "server is stopping")
+		case err := <-heartbeats:
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := beat(); err != nil {
+				return err
+			}
+		case cmd := <-commands:
+			switch cmd.GetType() {
+			case controlpb.Command_TYPE_PAUSE:
+				err = setActive(false, cmd.GetReason())
+			case controlpb.Command_TYPE_RESUME:
+				err = setActive(true, cmd.GetReason())
+			default:
+				err = stream.Send(cmd)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// UploadResults processes every task result on the stream like POST /tasks/result and
+// returns their outcomes when the agent closes it
+func (s *Server) UploadResults(stream controlpb.AgentControl_UploadResultsServer) error {
+	var outcomes []*controlpb.ResultOutcome
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&controlpb.UploadResultsResponse{Outcomes: outcomes})
+		}
+		if err != nil {
+			return err
+		}
+		outcomes = append(outcomes, processResult(msg))
+	}
+}
+
+// StartTask records that an agent started a task, like POST /tasks/started
+func (s *Server) StartTask(ctx context.Context, req *controlpb.TaskStarted) (*controlpb.StartTaskResponse, error) {
+	started := req.ToProtocol()
+	if err := started.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := handlers.ProcessTaskStarted(started); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &controlpb.StartTaskResponse{}, nil
+}
+
+// processResult validates and applies a task result
+func processResult(msg *controlpb.TaskResult) *controlpb.ResultOutcome {
+	outcome := &controlpb.ResultOutcome{TaskId: msg.GetTaskId()}
+
+	result := msg.ToProtocol()
+	var processed *handlers.TaskResultOutcome
+	err := result.Validate()
+	if err == nil {
+		processed, err = handlers.ProcessTaskResult(result)
+	}
+	if err != nil {
+		event := logger.Error()
+		if errors.Is(err, protocol.ErrInvalidResult) || errors.Is(err, protocol.ErrUnsupportedVersion) {
+			event = logger.Warn()
+		}
+		event.Err(err).Str("task_id", msg.GetTaskId()).Msg("Rejected task result")
+		outcome.Error = err.Error()
+		return outcome
+	}
+
+	outcome.Accepted = true
+	outcome.Duplicate = processed.Duplicate
+	return outcome
+}
+
+// connect opens the command queue of an agent, replacing the one of a previous stream
+func (s *Server) connect(id uuid.UUID) chan *controlpb.Command {
+	commands := make(chan *controlpb.Command, commandBuffer)
+	s.mu.Lock()
+	s.agents[id] = commands
+	s.mu.Unlock()
+
+	logger.Info().Str("agent_id", id.String()).Msg("Agent heartbeat stream opened")
+	return commands
+}
+
+// disconnect removes the command queue of an agent unless a newer stream replaced it
+func (s *Server) disconnect(id uuid.UUID, commands chan *controlpb.Command) {
+	s.mu.Lock()
+	if s.agents[id] == commands {
+		delete(s.agents, id)
+	}
+	s.mu.Unlock()
+
+	logger.Info().Str("agent_id", id.String()).Msg("Agent heartbeat stream closed")
+}
+
+// push queues a command for a connected agent without blocking
+func (s *Server) push(id uuid.UUID, cmd *controlpb.Command) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	commands, ok := s.agents[id]
+	if !ok {
+		return
+	}
+	select {
+	case commands <- cmd:
+	default:
+		logger.Warn().Str("agent_id", id.String()).Str("command", cmd.GetType().String()).Msg("Agent command queue is full, dropping command")
+	}
+}
+
+// pushEvents turns agent and website changes into commands for the connected agents
+func (s *Server) pushEvents(ch <-chan events.Event) {
+	for e := range ch {
+		switch e.Type {
+		case events.TypeAgentUpdated:
+			id, err := uuid.Parse(e.AgentID)
+			if err != nil {
+				continue
+			}
+			data, _ := e.Data.(events.AgentUpdated)
+			cmd := &controlpb.Command{Type: controlpb.Command_TYPE_RESUME, Reason: "agent activated"}
+			switch {
+			case data.Deleted:
+				cmd = &controlpb.Command{Type: controlpb.Command_TYPE_PAUSE, Reason: "agent deleted"}
+			case !data.IsActive:
+				cmd = &controlpb.Command{Type: controlpb.Command_TYPE_PAUSE, Reason: "agent deactivated"}
+			}
+			s.push(id, cmd)
+
+		case events.TypeWebsiteUpdated:
+			s.mu.Lock()
+			ids := make([]uuid.UUID, 0, len(s.agents))
+			for id := range s.agents {
+				ids = append(ids, id)
+			}
+			s.mu.Unlock()
+
+			for _, id := range ids {
+				s.push(id, &controlpb.Command{Type: controlpb.Command_TYPE_SYNC_WEBSITES, Reason: "websites changed"})
+			}
+		}
+	}
+}
+
+// agentMessage converts an agent to its message
+func agentMessage(a models.Agent) *controlpb.Agent {
+	msg := &controlpb.Agent{
+		Id:        a.ID.String(),
+		Name:      a.Name,
+		IpAddress: a.IPAddress,
+		IsActive:  a.IsActive,
+		CreatedAt: timestamppb.New(a.CreatedAt),
+	}
+	if a.LastHeartbeat.Valid {
+		msg.LastHeartbeat = timestamppb.New(a.LastHeartbeat.Time)
+	}
+	return msg
+}
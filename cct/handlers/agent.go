@@ -6,28 +6,44 @@ import (
 	"time"
 
 	"cct/models"
+	"cct/pkg/events"
 
 	"github.com/google/uuid"
 )
 
-// GetAgents handles GET /agents?active_only={true|false}&ip_address={ip_address}&name={name}
+// GetAgents handles GET /agents?active_only={true|false}&ip_address={ip_address}&name={name}.
+// Other filters: heartbeat_after, heartbeat_before. Sorts: created_at (default, newest first),
+// name, last_heartbeat.
 func GetAgents(w http.ResponseWriter, r *http.Request) {
-	// Check if active_only query parameter is provided
-	activeOnly := r.URL.Query().Get("active_only") == "true"
-	ipAddress := r.URL.Query().Get("ip_address")
-	name := r.URL.Query().Get("name")
+	query := r.URL.Query()
+	filter := models.AgentFilter{
+		ActiveOnly: query.Get("active_only") == "true",
+		IPAddress:  query.Get("ip_address"),
+		Name:       query.Get("name"),
+	}
 
-	var agents []models.Agent
-	var err error
+	opts, err := parseListOptions(query, query.Get("sort") == "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields, err := parseFields[models.Agent](query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.HeartbeatAfter, filter.HeartbeatBefore, err = parseTimeRange(query, "heartbeat"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	agents, err = models.GetAgents(activeOnly, ipAddress, name)
+	page, err := models.ListAgents(filter, opts)
 	if err != nil {
-		http.Error(w, "Failed to get agents: "+err.Error(), http.StatusInternalServerError)
+		listError(w, "agents", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(agents)
+	writePage(w, page, fields)
 }
 
 // GetAgent handles GET /agents/{id}
@@ -96,6 +112,7 @@ func UpdateAgent(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to update agent: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	publishAgentUpdated(agent.ID, events.AgentUpdated{IsActive: agent.IsActive})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(agent)
@@ -140,15 +157,18 @@ func DeactivateInactiveAgents(w http.ResponseWriter, r *http.Request) {
 	inactiveDuration := time.Duration(requestBody.InactiveDurationSeconds) * time.Second
 
 	// Deactivate inactive agents
-	count, err := models.DeactivateInactiveAgents(inactiveDuration)
+	ids, err := models.DeactivateInactiveAgents(inactiveDuration)
 	if err != nil {
 		http.Error(w, "Failed to deactivate inactive agents: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	for _, id := range ids {
+		publishAgentUpdated(id, events.AgentUpdated{IsActive: false})
+	}
 
 	// Return the number of deactivated agents
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]int64{"deactivated_count": count})
+	json.NewEncoder(w).Encode(map[string]int64{"deactivated_count": int64(len(ids))})
 }
 
 // DeleteAgent handles DELETE /agents/{id}
@@ -165,6 +185,17 @@ func DeleteAgent(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to delete agent: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	publishAgentUpdated(id, events.AgentUpdated{Deleted: true})
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// publishAgentUpdated announces a change of an agent, connected agents are told to pause
+// or resume
+func publishAgentUpdated(id uuid.UUID, data events.AgentUpdated) {
+	events.Publish(events.Event{
+		Type:    events.TypeAgentUpdated,
+		AgentID: id.String(),
+		Data:    data,
+	})
+}
@@ -2,44 +2,93 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"cct/models"
+	"cct/pkg/events"
+	"cct/pkg/render"
 )
 
-// GetChapters handles GET /chapters
+// GetChapters handles GET /chapters.
+// Filters: novel_id, from_chapter, to_chapter, crawled, has_error, removed, crawled_after, crawled_before.
+// Sorts: sequence (default, source order), chapter_number, id, title, crawled_at. fields= selects the returned fields,
+// content is not read when it is left out.
 func GetChapters(w http.ResponseWriter, r *http.Request) {
-	// Check if novel_id query parameter is provided
-	novelIDStr := r.URL.Query().Get("novel_id")
-	
-	var chapters []models.Chapter
-	var err error
-	
-	if novelIDStr != "" {
-		// Get chapters for a specific novel
-		novelID, err := strconv.Atoi(novelIDStr)
-		if err != nil {
-			http.Error(w, "Invalid novel ID", http.StatusBadRequest)
-			return
+	query := r.URL.Query()
+
+	opts, err := parseListOptions(query, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields, err := parseFields[models.Chapter](query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter := models.ChapterFilter{
+		WithoutContent: !hasField(fields, "content") && !hasField(fields, "rich_content"),
+	}
+	for name, dest := range map[string]*int{
+		"novel_id":     &filter.NovelID,
+		"from_chapter": &filter.FromChapter,
+		"to_chapter":   &filter.ToChapter,
+	} {
+		if value := query.Get(name); value != "" {
+			if *dest, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
 		}
-		
-		chapters, err = models.GetChaptersByNovel(novelID)
-	} else {
-		// Get all chapters
-		chapters, err = models.GetChapters()
 	}
-	
+	if filter.Crawled, err = parseBoolParam(query, "crawled"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.HasError, err = parseBoolParam(query, "has_error"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.Removed, err = parseBoolParam(query, "removed"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.CrawledAfter, filter.CrawledBefore, err = parseTimeRange(query, "crawled"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := models.ListChapters(filter, opts)
 	if err != nil {
-		http.Error(w, "Failed to get chapters: "+err.Error(), http.StatusInternalServerError)
+		listError(w, "chapters", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(chapters)
+	// Render the content of every chapter when a format is requested
+	if formatStr := query.Get("format"); formatStr != "" && !filter.WithoutContent {
+		format, err := render.ParseFormat(formatStr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for i := range page.Data {
+			if err := renderChapter(&page.Data[i], format); err != nil {
+				http.Error(w, "Failed to render chapter: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	writePage(w, page, fields)
 }
 
-// GetChapter handles GET /chapters/{id}
+// GetChapter handles GET /chapters/{id}. The content is returned as JSON by default,
+// or as a text, html or markdown document selected by the format query parameter
+// or the Accept header.
 func GetChapter(w http.ResponseWriter, r *http.Request) {
 	// Extract ID from URL path
 	idStr := r.PathValue("id")
@@ -55,10 +104,56 @@ func GetChapter(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	format := render.FormatFromAccept(r.Header.Get("Accept"))
+	if formatStr := r.URL.Query().Get("format"); formatStr != "" {
+		if format, err = render.ParseFormat(formatStr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if format != "" {
+		content, err := render.Chapter(chapter.Content, chapter.RichContent, chapter.ContentFormat, format)
+		if err != nil {
+			http.Error(w, "Failed to render chapter: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", render.ContentType(format))
+		w.Header().Add("Vary", "Accept")
+		w.Write([]byte(content))
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("Vary", "Accept")
 	json.NewEncoder(w).Encode(chapter)
 }
 
+// renderChapter replaces the content of a chapter with its rendering in format
+func renderChapter(chapter *models.Chapter, format string) error {
+	content, err := render.Chapter(chapter.Content, chapter.RichContent, chapter.ContentFormat, format)
+	if err != nil {
+		return err
+	}
+	chapter.Content = content
+	chapter.RichContent = ""
+	chapter.ContentFormat = format
+	return nil
+}
+
+// sanitizeRichContent sanitizes the HTML rich content of a chapter before it is stored
+func sanitizeRichContent(c *models.Chapter) error {
+	if c.RichContent == "" || c.ContentFormat != models.ContentFormatHTML {
+		return nil
+	}
+	content, err := render.SanitizeHTML(c.RichContent)
+	if err != nil {
+		return fmt.Errorf("failed to sanitize rich content: %w", err)
+	}
+	c.RichContent = content
+	return nil
+}
+
 // CreateChapter handles POST /chapters
 func CreateChapter(w http.ResponseWriter, r *http.Request) {
 	var chapter models.Chapter
@@ -67,10 +162,32 @@ func CreateChapter(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	chapterURL, err := canonicalURL(chapter.URL)
+	if err != nil {
+		http.Error(w, "Failed to canonicalize chapter URL: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	chapter.URL = chapterURL
+
+	if err := sanitizeRichContent(&chapter); err != nil {
+		http.Error(w, "Invalid rich content: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if err := models.CreateChapter(&chapter); err != nil {
 		http.Error(w, "Failed to create chapter: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	events.Publish(events.Event{
+		Type:    events.TypeChapterAdded,
+		NovelID: chapter.NovelID,
+		Data: events.ChapterAdded{Chapters: []events.AddedChapter{{
+			ChapterID: chapter.ID,
+			Title:     chapter.Title,
+			URL:       chapter.URL,
+			Sequence:  chapter.Sequence,
+		}}},
+	})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -96,7 +213,17 @@ func UpdateChapter(w http.ResponseWriter, r *http.Request) {
 	// Ensure ID in URL matches ID in body
 	chapter.ID = id
 
-	if err := models.UpdateChapter(&chapter); err != nil {
+	if chapter.URL, err = canonicalURL(chapter.URL); err != nil {
+		http.Error(w, "Failed to canonicalize chapter URL: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := sanitizeRichContent(&chapter); err != nil {
+		http.Error(w, "Invalid rich content: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := models.UpdateChapter(&chapter, revisionRetention); err != nil {
 		http.Error(w, "Failed to update chapter: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -122,3 +249,60 @@ func DeleteChapter(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// RenumberChaptersRequest represents the request body for renumbering the chapters of a novel
+type RenumberChaptersRequest struct {
+	Chapters []models.ChapterOrder `json:"chapters"`
+	// Compact rewrites the sequences of the novel to 1..n after the changes
+	Compact bool `json:"compact"`
+}
+
+// RenumberChapters handles POST /novels/{id}/chapters/renumber, fixing the ordering of
+// chapters by hand. Changed chapters are locked so crawls don't overwrite their ordering
+// unless locked is false.
+func RenumberChapters(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	idStr := r.PathValue("id")
+	novelID, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid novel ID", http.StatusBadRequest)
+		return
+	}
+
+	var req RenumberChaptersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Chapters) == 0 && !req.Compact {
+		http.Error(w, "Chapters or compact is required", http.StatusBadRequest)
+		return
+	}
+	for _, o := range req.Chapters {
+		if o.Sequence != nil && *o.Sequence < 0 || o.Volume != nil && *o.Volume < 0 || o.Part != nil && *o.Part < 0 {
+			http.Error(w, "Sequence, volume and part must not be negative", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, err := models.GetNovel(novelID); err != nil {
+		http.Error(w, "Novel not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	changed, err := models.RenumberChapters(novelID, req.Chapters, req.Compact)
+	if err != nil {
+		if errors.Is(err, models.ErrChapterNotInNovel) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to renumber chapters: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":  "success",
+		"changed": changed,
+	})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cct/config"
+	"cct/models"
+	"cct/pkg/blobstore"
+	"cct/pkg/logger"
+
+	"github.com/zrik/protocol"
+)
+
+var (
+	blobStore    blobstore.Store
+	maxCoverSize int64
+)
+
+// InitBlobStore initializes the blob store used for novel covers
+func InitBlobStore(cfg *config.Config) error {
+	var err error
+	blobStore, err = blobstore.New(&cfg.Storage)
+	if err != nil {
+		return err
+	}
+	maxCoverSize = cfg.Storage.MaxCoverSize
+	return nil
+}
+
+// GetNovelCover handles GET /novels/{id}/cover
+func GetNovelCover(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid novel ID", http.StatusBadRequest)
+		return
+	}
+
+	cover, err := models.GetNovelCover(id)
+	if err != nil {
+		http.Error(w, "Failed to get novel cover: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	if cover.Hash == "" {
+		http.Error(w, "Novel has no cover", http.StatusNotFound)
+		return
+	}
+
+	f, err := blobStore.Open(cover.Hash)
+	if err != nil {
+		if errors.Is(err, blobstore.ErrNotFound) {
+			http.Error(w, "Cover image not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to open cover: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	// Covers are content addressed, the hash is a strong validator. The URL stays the same
+	// when a cover is refreshed, so caches revalidate every time and get a 304 while the
+	// cover is unchanged.
+	w.Header().Set("Content-Type", cover.ContentType)
+	w.Header().Set("ETag", `"`+cover.Hash+`"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, "", cover.UpdatedAt, f)
+}
+
+// UploadNovelCover handles PUT /novels/{id}/cover with the raw image as request body.
+// The optional source_url query parameter records the upstream URL of the image.
+func UploadNovelCover(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid novel ID", http.StatusBadRequest)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCoverSize))
+	if err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cover, err := storeNovelCover(id, r.URL.Query().Get("source_url"), data, r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, "Failed to store cover: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cover)
+}
+
+// storeNovelCover stores a cover image in the blob store and attaches it to the novel
+func storeNovelCover(novelID int, sourceURL string, data []byte, contentType string) (*models.NovelCover, error) {
+	if len(data) == 0 {
+		return nil, errors.New("cover image is empty")
+	}
+	if int64(len(data)) > maxCoverSize {
+		return nil, fmt.Errorf("cover image is larger than %d bytes", maxCoverSize)
+	}
+
+	// Don't trust the reported type blindly, sniff it when it is not an image type
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("cover is not an image: %s", contentType)
+	}
+
+	key, err := blobStore.Put(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cover := &models.NovelCover{
+		NovelID:     novelID,
+		Hash:        key,
+		ContentType: contentType,
+		SourceURL:   sourceURL,
+	}
+	if err := models.UpdateNovelCover(cover); err != nil {
+		return nil, err
+	}
+
+	logger.Info().
+		Int("novel_id", novelID).
+		Str("hash", key).
+		Int("size", len(data)).
+		Msg("Stored novel cover")
+	return cover, nil
+}
+
+// refreshNovelCover stores the cover downloaded by an agent when the novel has no cover yet
+// or the upstream cover URL changed since it was stored
+func refreshNovelCover(novelID int, book protocol.Book) {
+	if book.BookImage == nil || blobStore == nil {
+		return
+	}
+
+	current, err := models.GetNovelCover(novelID)
+	if err != nil {
+		logger.Error().Err(err).Int("novel_id", novelID).Msg("Failed to get novel cover")
+		return
+	}
+	if current.Hash != "" && current.SourceURL == book.BookImageUrl {
+		return
+	}
+
+	if _, err := storeNovelCover(novelID, book.BookImageUrl, book.BookImage.Data, book.BookImage.ContentType); err != nil {
+		logger.Error().Err(err).Int("novel_id", novelID).Msg("Failed to store novel cover")
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cct/pkg/events"
+	"cct/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// streamPingInterval is how often an idle event stream sends a comment, so proxies keep it open
+const streamPingInterval = 15 * time.Second
+
+// eventFilter selects the events sent on a stream, zero fields match every event
+type eventFilter struct {
+	novelID   int
+	websiteID int
+	agentID   string
+	types     []string
+}
+
+// match reports whether an event passes the filter
+func (f eventFilter) match(e events.Event) bool {
+	switch {
+	case f.novelID != 0 && e.NovelID != f.novelID:
+		return false
+	case f.websiteID != 0 && e.WebsiteID != f.websiteID:
+		return false
+	case f.agentID != "" && e.AgentID != f.agentID:
+		return false
+	case len(f.types) > 0 && !slices.Contains(f.types, e.Type):
+		return false
+	}
+	return true
+}
+
+// parseEventFilter reads the novel_id, website_id, agent_id and types parameters
+func parseEventFilter(r *http.Request) (eventFilter, error) {
+	query := r.URL.Query()
+	var f eventFilter
+	var err error
+
+	if s := query.Get("novel_id"); s != "" {
+		if f.novelID, err = strconv.Atoi(s); err != nil {
+			return f, errors.New("Invalid novel ID")
+		}
+	}
+	if s := query.Get("website_id"); s != "" {
+		if f.websiteID, err = strconv.Atoi(s); err != nil {
+			return f, errors.New("Invalid website ID")
+		}
+	}
+	if s := query.Get("agent_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return f, errors.New("Invalid agent ID")
+		}
+		f.agentID = id.String()
+	}
+	if s := query.Get("types"); s != "" {
+		for _, t := range strings.Split(s, ",") {
+			t = strings.TrimSpace(t)
+			if !slices.Contains(events.Types, t) {
+				return f, fmt.Errorf("unknown event type %q, expected one of %v", t, events.Types)
+			}
+			f.types = append(f.types, t)
+		}
+	}
+	return f, nil
+}
+
+// StreamEvents handles GET /events?novel_id={id}&website_id={id}&agent_id={id}&types={types},
+// a Server-Sent Events stream of the events published from now on. types is a comma separated
+// list of event types. Every event is sent with its ID and type, its data is the event as JSON.
+// Events are dropped for a client that doesn't keep up.
+func StreamEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The stream stays open past the server write timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		logger.Debug().Err(err).Msg("Failed to clear event stream write deadline")
+	}
+
+	ch, unsubscribe := events.Subscribe(0)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		logger.Error().Err(err).Msg("Event stream can't be flushed")
+		return
+	}
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if !filter.match(e) {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				logger.Error().Err(err).Str("type", e.Type).Msg("Failed to encode event")
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"cct/config"
+	"cct/models"
+	"cct/pkg/export"
+	"cct/pkg/logger"
+)
+
+var (
+	exportDir            string
+	exportAsyncThreshold int
+	exportWriteTimeout   time.Duration
+	// exportSlots limits the number of background exports built at once
+	exportSlots chan struct{}
+)
+
+// InitExports initializes the export settings and fails the background exports
+// interrupted by the previous shutdown
+func InitExports(cfg *config.Config) error {
+	exportDir = cfg.Export.Dir
+	exportAsyncThreshold = cfg.Export.AsyncThreshold
+	exportWriteTimeout = cfg.Export.WriteTimeout
+	exportSlots = make(chan struct{}, max(cfg.Export.Workers, 1))
+
+	if err := os.MkdirAll(exportDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	n, err := models.FailInterruptedExportJobs()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		logger.Warn().Int64("jobs", n).Msg("Failed export jobs interrupted by a restart")
+	}
+	return nil
+}
+
+// ExportNovel handles GET /novels/{id}/export.
+// Query parameters: format (epub, txt, md or zip), from and to chapter numbers, async=true to always
+// build the export in the background, with_removed=true to include chapters the source removed. Exports of more chapters than the configured
+// threshold are built in the background and answered with 202 and the export job.
+func ExportNovel(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid novel ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	format, err := export.ParseFormat(query.Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rng, err := parseChapterRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rng.WithRemoved = query.Get("with_removed") == "true"
+
+	novel, err := models.GetNovel(id)
+	if err != nil {
+		http.Error(w, "Failed to get novel: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	count, err := models.CountNovelChapters(id, rng)
+	if err != nil {
+		http.Error(w, "Failed to count chapters: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if count == 0 {
+		http.Error(w, "Novel has no chapters to export", http.StatusNotFound)
+		return
+	}
+
+	if query.Get("async") == "true" || (exportAsyncThreshold > 0 && count > exportAsyncThreshold) {
+		job := &models.ExportJob{
+			NovelID:     id,
+			Format:      format,
+			FromChapter: rng.FromChapter,
+			ToChapter:   rng.ToChapter,
+			WithRemoved: rng.WithRemoved,
+		}
+		if err := models.CreateExportJob(job); err != nil {
+			http.Error(w, "Failed to create export job: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		go runExportJob(*job, novel)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/api/exports/"+strconv.Itoa(job.ID))
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+		return
+	}
+
+	// Large books take longer than the server write timeout to stream
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportWriteTimeout)); err != nil {
+		logger.Debug().Err(err).Msg("Failed to extend export write deadline")
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", attachment(export.FileName(novel, format)))
+
+	// The archive is streamed, errors can't be reported once it started
+	stats, err := export.Write(w, format, newExportBook(novel, rng))
+	if err != nil {
+		logger.Error().Err(err).Int("novel_id", id).Str("format", format).Msg("Failed to export novel")
+		return
+	}
+	logger.Info().
+		Int("novel_id", id).
+		Str("format", format).
+		Int("chapters", stats.Chapters).
+		Int("missing", len(stats.Missing)).
+		Msg("Exported novel")
+}
+
+// GetNovelExports handles GET /novels/{id}/exports
+func GetNovelExports(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid novel ID", http.StatusBadRequest)
+		return
+	}
+
+	jobs, err := models.GetExportJobsByNovel(id)
+	if err != nil {
+		http.Error(w, "Failed to get export jobs: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// GetExportJob handles GET /exports/{id}
+func GetExportJob(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid export ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := models.GetExportJob(id)
+	if err != nil {
+		http.Error(w, "Failed to get export job: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// DownloadExport handles GET /exports/{id}/download
+func DownloadExport(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid export ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := models.GetExportJob(id)
+	if err != nil {
+		http.Error(w, "Failed to get export job: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	if job.Status != models.ExportJobStatusCompleted {
+		http.Error(w, "Export is "+job.Status, http.StatusConflict)
+		return
+	}
+
+	f, err := os.Open(exportPath(job))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "Export file not found", http.StatusGone)
+			return
+		}
+		http.Error(w, "Failed to open export: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportWriteTimeout)); err != nil {
+		logger.Debug().Err(err).Msg("Failed to extend export write deadline")
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(job.Format))
+	w.Header().Set("Content-Disposition", attachment(job.FileName))
+	http.ServeContent(w, r, "", job.CompletedAt.Time, f)
+}
+
+// runExportJob builds an export in the background and stores it in the export directory
+func runExportJob(job models.ExportJob, novel models.Novel) {
+	exportSlots <- struct{}{}
+	defer func() { <-exportSlots }()
+
+	if err := models.StartExportJob(job.ID); err != nil {
+		logger.Error().Err(err).Int("export_id", job.ID).Msg("Failed to start export job")
+		return
+	}
+
+	stats, err := writeExportFile(&job, novel)
+	if err != nil {
+		logger.Error().Err(err).Int("export_id", job.ID).Msg("Failed to build export")
+		if err := models.FailExportJob(job.ID, err.Error()); err != nil {
+			logger.Error().Err(err).Int("export_id", job.ID).Msg("Failed to fail export job")
+		}
+		return
+	}
+
+	job.FileName = export.FileName(novel, job.Format)
+	job.ChapterCount = stats.Chapters
+	job.MissingCount = len(stats.Missing)
+	if err := models.CompleteExportJob(&job); err != nil {
+		logger.Error().Err(err).Int("export_id", job.ID).Msg("Failed to complete export job")
+		return
+	}
+	logger.Info().
+		Int("export_id", job.ID).
+		Int("novel_id", job.NovelID).
+		Int("chapters", stats.Chapters).
+		Int("missing", len(stats.Missing)).
+		Int64("size", job.FileSize).
+		Msg("Built export")
+}
+
+// writeExportFile writes the export of a job to a temporary file that is only renamed
+// to its final path once complete, so partial files are never downloaded
+func writeExportFile(job *models.ExportJob, novel models.Novel) (*export.Stats, error) {
+	tmp, err := os.CreateTemp(exportDir, fmt.Sprintf("export-%d-*.tmp", job.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	rng := models.ChapterRange{FromChapter: job.FromChapter, ToChapter: job.ToChapter, WithRemoved: job.WithRemoved}
+	stats, err := export.Write(tmp, job.Format, newExportBook(novel, rng))
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	job.FileSize = info.Size()
+
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), exportPath(*job)); err != nil {
+		return nil, fmt.Errorf("failed to store export file: %w", err)
+	}
+	return stats, nil
+}
+
+// exportPath returns the path of the file built by an export job
+func exportPath(job models.ExportJob) string {
+	return filepath.Join(exportDir, strconv.Itoa(job.ID)+"."+job.Format)
+}
+
+// newExportBook returns the book exporting the chapters of novel in rng, with its cover when stored
+func newExportBook(novel models.Novel, rng models.ChapterRange) *export.Book {
+	return &export.Book{
+		Novel: novel,
+		Cover: loadExportCover(novel.ID),
+		Chapters: func(fn func(models.Chapter) error) error {
+			return models.EachNovelChapter(novel.ID, rng, fn)
+		},
+	}
+}
+
+// loadExportCover reads the stored cover of a novel, exports are built without a cover
+// when it can't be read
+func loadExportCover(novelID int) *export.Cover {
+	if blobStore == nil {
+		return nil
+	}
+
+	cover, err := models.GetNovelCover(novelID)
+	if err != nil || cover.Hash == "" {
+		return nil
+	}
+
+	f, err := blobStore.Open(cover.Hash)
+	if err != nil {
+		logger.Warn().Err(err).Int("novel_id", novelID).Msg("Failed to open cover for export")
+		return nil
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		logger.Warn().Err(err).Int("novel_id", novelID).Msg("Failed to read cover for export")
+		return nil
+	}
+	return &export.Cover{ContentType: cover.ContentType, Data: data}
+}
+
+// parseChapterRange parses the from and to query parameters, positions in the source chapter list
+func parseChapterRange(from, to string) (models.ChapterRange, error) {
+	var rng models.ChapterRange
+	var err error
+
+	if from != "" {
+		if rng.FromChapter, err = strconv.Atoi(from); err != nil || rng.FromChapter < 0 {
+			return rng, errors.New("Invalid from chapter")
+		}
+	}
+	if to != "" {
+		if rng.ToChapter, err = strconv.Atoi(to); err != nil || rng.ToChapter < 0 {
+			return rng, errors.New("Invalid to chapter")
+		}
+	}
+	if rng.FromChapter > 0 && rng.ToChapter > 0 && rng.FromChapter > rng.ToChapter {
+		return rng, errors.New("from chapter is after to chapter")
+	}
+	return rng, nil
+}
+
+// attachment returns a Content-Disposition header downloading a file named name
+func attachment(name string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": name})
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"cct/models"
+)
+
+// List page sizes
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// parseListOptions parses the limit, cursor, sort and order query parameters of a list.
+// order is asc or desc, defaultDesc applies when it is not given.
+func parseListOptions(query url.Values, defaultDesc bool) (models.ListOptions, error) {
+	opts := models.ListOptions{
+		Limit:  defaultListLimit,
+		Cursor: query.Get("cursor"),
+		Sort:   query.Get("sort"),
+		Desc:   defaultDesc,
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return opts, errors.New("Invalid limit")
+		}
+		opts.Limit = min(limit, maxListLimit)
+	}
+
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		opts.Desc = false
+	case "desc":
+		opts.Desc = true
+	default:
+		return opts, errors.New("Invalid order, expected asc or desc")
+	}
+
+	return opts, nil
+}
+
+// parseBoolParam parses an optional true or false query parameter, nil when it is not given
+func parseBoolParam(query url.Values, name string) (*bool, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s, expected true or false", name)
+	}
+	return &b, nil
+}
+
+// parseTimeParam parses an optional RFC 3339 time or YYYY-MM-DD date query parameter,
+// the zero time when it is not given
+func parseTimeParam(query url.Values, name string) (time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid %s, expected a date (YYYY-MM-DD) or an RFC 3339 time", name)
+}
+
+// parseTimeRange parses the {prefix}_after and {prefix}_before query parameters
+func parseTimeRange(query url.Values, prefix string) (after, before time.Time, err error) {
+	if after, err = parseTimeParam(query, prefix+"_after"); err != nil {
+		return
+	}
+	before, err = parseTimeParam(query, prefix+"_before")
+	return
+}
+
+// parseFields parses the fields query parameter, the JSON fields kept in each item of a list.
+// It returns nil when every field is kept.
+func parseFields[T any](query url.Values) ([]string, error) {
+	value := query.Get("fields")
+	if value == "" {
+		return nil, nil
+	}
+
+	known := jsonFieldNames(reflect.TypeFor[T]())
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !known[field] {
+			return nil, fmt.Errorf("Unknown field: %s", field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// hasField reports whether fields keeps name, nil fields keep every field
+func hasField(fields []string, name string) bool {
+	if fields == nil {
+		return true
+	}
+	for _, field := range fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonFieldNames returns the JSON names of the fields of a struct type
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// writePage writes a page of a list as JSON, keeping only fields in each item when given
+func writePage[T any](w http.ResponseWriter, page *models.Page[T], fields []string) {
+	w.Header().Set("Content-Type", "application/json")
+	if fields == nil {
+		json.NewEncoder(w).Encode(page)
+		return
+	}
+
+	projected := models.Page[map[string]json.RawMessage]{
+		Data:       make([]map[string]json.RawMessage, 0, len(page.Data)),
+		Total:      page.Total,
+		NextCursor: page.NextCursor,
+	}
+	for _, item := range page.Data {
+		data, err := json.Marshal(item)
+		if err != nil {
+			http.Error(w, "Failed to encode item: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(data, &all); err != nil {
+			http.Error(w, "Failed to encode item: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		kept := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if value, ok := all[field]; ok {
+				kept[field] = value
+			}
+		}
+		projected.Data = append(projected.Data, kept)
+	}
+	json.NewEncoder(w).Encode(projected)
+}
+
+// listError writes the error of a list query, a bad request for invalid sorts and cursors
+func listError(w http.ResponseWriter, what string, err error) {
+	if errors.Is(err, models.ErrInvalidSort) || errors.Is(err, models.ErrInvalidCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, "Failed to get "+what+": "+err.Error(), http.StatusInternalServerError)
+}
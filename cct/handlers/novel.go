@@ -6,37 +6,67 @@ import (
 	"strconv"
 
 	"cct/models"
+	"cct/pkg/events"
 )
 
-// GetNovels handles GET /novels
+// GetNovels handles GET /novels?website_id={id}&status={status}&author={author}&genre={genre}&tag={tag}.
+// Other filters: crawled, created_after, created_before, crawled_after, crawled_before.
+// Sorts: id (default), title, created_at, last_crawled_at.
 func GetNovels(w http.ResponseWriter, r *http.Request) {
-	// Check if website_id query parameter is provided
-	websiteIDStr := r.URL.Query().Get("website_id")
+	query := r.URL.Query()
+	filter := models.NovelFilter{
+		Status: query.Get("status"),
+		Author: query.Get("author"),
+		Genre:  query.Get("genre"),
+		Tag:    query.Get("tag"),
+	}
 
-	var novels []models.Novel
-	var err error
+	opts, err := parseListOptions(query, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields, err := parseFields[models.Novel](query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if websiteIDStr != "" {
-		// Get novels for a specific website
+	// Check if website_id query parameter is provided
+	if websiteIDStr := query.Get("website_id"); websiteIDStr != "" {
 		websiteID, err := strconv.Atoi(websiteIDStr)
 		if err != nil {
 			http.Error(w, "Invalid website ID", http.StatusBadRequest)
 			return
 		}
+		filter.WebsiteID = websiteID
+	}
+
+	if filter.Status != "" && filter.Status != models.NovelStatusOngoing && filter.Status != models.NovelStatusCompleted {
+		http.Error(w, "Invalid status: "+filter.Status, http.StatusBadRequest)
+		return
+	}
 
-		novels, err = models.GetNovelsByWebsite(websiteID)
-	} else {
-		// Get all novels
-		novels, err = models.GetNovels()
+	if filter.Crawled, err = parseBoolParam(query, "crawled"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.CreatedAfter, filter.CreatedBefore, err = parseTimeRange(query, "created"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.CrawledAfter, filter.CrawledBefore, err = parseTimeRange(query, "crawled"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
+	page, err := models.ListNovels(filter, opts)
 	if err != nil {
-		http.Error(w, "Failed to get novels: "+err.Error(), http.StatusInternalServerError)
+		listError(w, "novels", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(novels)
+	writePage(w, page, fields)
 }
 
 // GetNovel handles GET /novels/{id}
@@ -67,10 +97,23 @@ func CreateNovel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sourceURL, err := canonicalURL(novel.SourceURL)
+	if err != nil {
+		http.Error(w, "Failed to canonicalize source URL: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	novel.SourceURL = sourceURL
+
 	if err := models.CreateNovel(&novel); err != nil {
 		http.Error(w, "Failed to create novel: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	events.Publish(events.Event{
+		Type:      events.TypeNovelCreated,
+		NovelID:   novel.ID,
+		WebsiteID: novel.WebsiteID,
+		Data:      events.NovelCreated{Title: novel.Title, SourceURL: novel.SourceURL},
+	})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -96,6 +139,11 @@ func UpdateNovel(w http.ResponseWriter, r *http.Request) {
 	// Ensure ID in URL matches ID in body
 	novel.ID = id
 
+	if novel.SourceURL, err = canonicalURL(novel.SourceURL); err != nil {
+		http.Error(w, "Failed to canonicalize source URL: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	if err := models.UpdateNovel(&novel); err != nil {
 		http.Error(w, "Failed to update novel: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"cct/config"
+	"cct/models"
+	"cct/pkg/textdiff"
+)
+
+// revisionRetention is the number of previous versions kept per chapter, 0 keeps all
+var revisionRetention int
+
+// diffContext is the number of unchanged lines shown around each change of a diff
+const diffContext = 3
+
+// InitChapterRevisions initializes the chapter revision settings
+func InitChapterRevisions(cfg *config.Config) {
+	revisionRetention = cfg.Chapters.RevisionRetention
+}
+
+// RevisionDiff is the line diff between two versions of a chapter
+type RevisionDiff struct {
+	ChapterID int    `json:"chapter_id"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Added     int    `json:"added"`
+	Removed   int    `json:"removed"`
+	Diff      string `json:"diff"`
+}
+
+// GetChapterRevisions handles GET /chapters/{id}/revisions
+func GetChapterRevisions(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid chapter ID", http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := models.GetChapterRevisions(id)
+	if err != nil {
+		http.Error(w, "Failed to get chapter revisions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// GetChapterRevision handles GET /chapters/{id}/revisions/{revision_id}
+func GetChapterRevision(w http.ResponseWriter, r *http.Request) {
+	// Extract IDs from URL path
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid chapter ID", http.StatusBadRequest)
+		return
+	}
+	revisionID, err := strconv.Atoi(r.PathValue("revision_id"))
+	if err != nil {
+		http.Error(w, "Invalid revision ID", http.StatusBadRequest)
+		return
+	}
+
+	revision, err := models.GetChapterRevision(id, revisionID)
+	if err != nil {
+		http.Error(w, "Failed to get chapter revision: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revision)
+}
+
+// DiffChapterRevisions handles GET /chapters/{id}/revisions/diff?from={revision_id}&to={revision_id}.
+// to defaults to the current content of the chapter.
+func DiffChapterRevisions(w http.ResponseWriter, r *http.Request) {
+	// Extract ID from URL path
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid chapter ID", http.StatusBadRequest)
+		return
+	}
+
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	if from == "" {
+		http.Error(w, "from is required", http.StatusBadRequest)
+		return
+	}
+	if to == "" {
+		to = "current"
+	}
+
+	fromContent, err := revisionContent(id, from)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	toContent, err := revisionContent(id, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	result, err := textdiff.Lines(fromContent, toContent)
+	if err != nil {
+		if errors.Is(err, textdiff.ErrTooLarge) {
+			http.Error(w, "Failed to diff chapter revisions: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, "Failed to diff chapter revisions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RevisionDiff{
+		ChapterID: id,
+		From:      from,
+		To:        to,
+		Added:     result.Added,
+		Removed:   result.Removed,
+		Diff:      result.Unified("revision "+from, "revision "+to, diffContext),
+	})
+}
+
+// revisionContent returns the content of a chapter revision, or of the chapter itself for "current"
+func revisionContent(chapterID int, revision string) (string, error) {
+	if revision == "current" {
+		chapter, err := models.GetChapter(chapterID)
+		if err != nil {
+			return "", err
+		}
+		return chapter.Content, nil
+	}
+
+	revisionID, err := strconv.Atoi(revision)
+	if err != nil {
+		return "", errors.New("invalid revision ID: " + revision)
+	}
+	rev, err := models.GetChapterRevision(chapterID, revisionID)
+	if err != nil {
+		return "", err
+	}
+	return rev.Content, nil
+}
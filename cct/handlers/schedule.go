@@ -5,39 +5,61 @@ import (
 	"net/http"
 	"strconv"
 
+	"cct/config"
 	"cct/models"
+	"cct/pkg/events"
+	"cct/pkg/logger"
 )
 
-// GetSchedules handles GET /schedules
+// completedInterval is the interval of the schedules of completed novels with every
+// chapter, 0 disables them
+var completedInterval int
+
+// InitSchedules initializes the schedule settings
+func InitSchedules(cfg *config.Config) {
+	completedInterval = cfg.Scheduler.CompletedInterval
+}
+
+// GetSchedules handles GET /schedules?novel_id={id}&enabled={true|false}.
+// Other filters: next_run_after, next_run_before. Sorts: id (default), last_run_at, next_run_at.
 func GetSchedules(w http.ResponseWriter, r *http.Request) {
-	// Check if novel_id query parameter is provided
-	novelIDStr := r.URL.Query().Get("novel_id")
+	query := r.URL.Query()
 
-	var schedules []models.NovelSchedule
-	var err error
+	opts, err := parseListOptions(query, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields, err := parseFields[models.NovelSchedule](query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if novelIDStr != "" {
-		// Get schedules for a specific novel
-		var novelID int
-		novelID, err = strconv.Atoi(novelIDStr)
-		if err != nil {
+	var filter models.ScheduleFilter
+	// Check if novel_id query parameter is provided
+	if novelIDStr := query.Get("novel_id"); novelIDStr != "" {
+		if filter.NovelID, err = strconv.Atoi(novelIDStr); err != nil {
 			http.Error(w, "Invalid novel ID", http.StatusBadRequest)
 			return
 		}
-
-		schedules, err = models.GetSchedulesByNovel(novelID)
-	} else {
-		// Get all schedules
-		schedules, err = models.GetSchedules()
+	}
+	if filter.Enabled, err = parseBoolParam(query, "enabled"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.NextRunAfter, filter.NextRunBefore, err = parseTimeRange(query, "next_run"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
+	page, err := models.ListSchedules(filter, opts)
 	if err != nil {
-		http.Error(w, "Failed to get schedules: "+err.Error(), http.StatusInternalServerError)
+		listError(w, "schedules", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(schedules)
+	writePage(w, page, fields)
 }
 
 // GetSchedule handles GET /schedules/{id}
@@ -65,6 +87,7 @@ type CreateScheduleRequest struct {
 	NovelID         int  `json:"novel_id"`
 	Enabled         bool `json:"enabled"`
 	IntervalSeconds int  `json:"interval_seconds"`
+	KeepActive      bool `json:"keep_active"`
 }
 
 // CreateSchedule handles POST /schedules
@@ -97,6 +120,7 @@ func CreateSchedule(w http.ResponseWriter, r *http.Request) {
 		NovelID:         req.NovelID,
 		Enabled:         req.Enabled,
 		IntervalSeconds: req.IntervalSeconds,
+		KeepActive:      req.KeepActive,
 	}
 
 	if err := models.CreateSchedule(schedule); err != nil {
@@ -113,6 +137,9 @@ func CreateSchedule(w http.ResponseWriter, r *http.Request) {
 type UpdateScheduleRequest struct {
 	Enabled         *bool `json:"enabled,omitempty"`
 	IntervalSeconds *int  `json:"interval_seconds,omitempty"`
+	// KeepActive keeps the schedule running when its novel is completed, setting it
+	// restores a schedule that was already wound down
+	KeepActive *bool `json:"keep_active,omitempty"`
 }
 
 // UpdateSchedule handles PUT /schedules/{id}
@@ -138,6 +165,16 @@ func UpdateSchedule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.KeepActive != nil {
+		if *req.KeepActive && schedule.WoundDownAt.Valid {
+			if schedule, err = models.ReviveSchedule(id); err != nil {
+				http.Error(w, "Failed to revive schedule: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		schedule.KeepActive = *req.KeepActive
+	}
+
 	// Update fields if provided
 	if req.Enabled != nil {
 		schedule.Enabled = *req.Enabled
@@ -231,7 +268,54 @@ func GetDueSchedules(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetChapterCrawlLogs handles GET /chapters/{id}/logs
+// windDownCompletedNovel winds down the schedules of a novel once it is completed and every
+// chapter has content
+func windDownCompletedNovel(novelID int) {
+	schedules, err := models.WindDownCompletedSchedules(novelID, completedInterval)
+	if err != nil {
+		logger.Error().Err(err).Int("novel_id", novelID).Msg("Failed to wind down schedules of completed novel")
+		return
+	}
+	if len(schedules) == 0 {
+		return
+	}
+
+	data := events.NovelCompleted{IntervalSeconds: completedInterval}
+	for _, s := range schedules {
+		data.ScheduleIDs = append(data.ScheduleIDs, s.ID)
+	}
+	logger.Info().
+		Int("novel_id", novelID).
+		Ints("schedule_ids", data.ScheduleIDs).
+		Int("interval_seconds", completedInterval).
+		Msg("Novel completed, wound down its schedules")
+
+	events.Publish(events.Event{
+		Type:    events.TypeNovelCompleted,
+		NovelID: novelID,
+		Data:    data,
+	})
+}
+
+// reviveOngoingNovel restores the schedules of a novel wound down while it was completed,
+// once a source reports it ongoing again
+func reviveOngoingNovel(novelID int) {
+	schedules, err := models.ReviveNovelSchedules(novelID)
+	if err != nil {
+		logger.Error().Err(err).Int("novel_id", novelID).Msg("Failed to revive schedules of ongoing novel")
+		return
+	}
+	for _, s := range schedules {
+		logger.Info().
+			Int("novel_id", novelID).
+			Int("schedule_id", s.ID).
+			Int("interval_seconds", s.IntervalSeconds).
+			Msg("Novel is ongoing again, revived its schedule")
+	}
+}
+
+// GetChapterCrawlLogs handles GET /chapters/{id}/logs?status={status}&created_after={date}&created_before={date}.
+// Sorts: created_at (default, newest first), id.
 func GetChapterCrawlLogs(w http.ResponseWriter, r *http.Request) {
 	// Extract ID from URL path
 	idStr := r.PathValue("id")
@@ -241,12 +325,29 @@ func GetChapterCrawlLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	logs, err := models.GetChapterCrawlLogs(chapterID)
+	query := r.URL.Query()
+	opts, err := parseListOptions(query, query.Get("sort") == "")
 	if err != nil {
-		http.Error(w, "Failed to get chapter crawl logs: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields, err := parseFields[models.ChapterCrawlLog](query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(logs)
+	filter := models.CrawlLogFilter{Status: query.Get("status")}
+	if filter.CreatedAfter, filter.CreatedBefore, err = parseTimeRange(query, "created"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := models.ListChapterCrawlLogs(chapterID, filter, opts)
+	if err != nil {
+		listError(w, "chapter crawl logs", err)
+		return
+	}
+
+	writePage(w, page, fields)
 }
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cct/models"
+)
+
+// Search result page sizes
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchResponse is the response of GET /search
+type SearchResponse struct {
+	Query  string             `json:"query"`
+	Limit  int                `json:"limit"`
+	Offset int                `json:"offset"`
+	Hits   []models.SearchHit `json:"hits"`
+}
+
+// SearchChapters handles GET /search?q=
+func SearchChapters(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.SearchFilter{
+		Query: strings.TrimSpace(query.Get("q")),
+		Limit: defaultSearchLimit,
+	}
+	if filter.Query == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	intParams := []struct {
+		name   string
+		target *int
+	}{
+		{"website_id", &filter.WebsiteID},
+		{"novel_id", &filter.NovelID},
+		{"from_chapter", &filter.FromChapter},
+		{"to_chapter", &filter.ToChapter},
+		{"limit", &filter.Limit},
+		{"offset", &filter.Offset},
+	}
+	for _, p := range intParams {
+		value := query.Get(p.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid "+p.name, http.StatusBadRequest)
+			return
+		}
+		*p.target = n
+	}
+
+	if filter.Limit <= 0 || filter.Limit > maxSearchLimit {
+		http.Error(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+	if filter.ToChapter > 0 && filter.FromChapter > filter.ToChapter {
+		http.Error(w, "from_chapter is after to_chapter", http.StatusBadRequest)
+		return
+	}
+
+	hits, err := models.SearchChapters(filter)
+	if err != nil {
+		http.Error(w, "Failed to search chapters: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SearchResponse{
+		Query:  filter.Query,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+		Hits:   hits,
+	})
+}
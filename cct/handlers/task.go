@@ -1,36 +1,80 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"cct/config"
+	"cct/middleware"
 	"cct/models"
+	"cct/pkg/events"
+	"cct/pkg/ingest"
 	"cct/pkg/logger"
 	"cct/pkg/rabbitmq"
+
+	"github.com/google/uuid"
+	"github.com/zrik/protocol"
 )
 
 var agentService *rabbitmq.AgentService
 
-type Book struct {
-	BookUrl      string
-	BookId       string
-	BookName     string
-	BookImageUrl string
-	AuthorName   string
-	Chapters     []Chapter
-	BookHost     string
-}
+// Task result settings, see InitTaskResults
+var (
+	processedTaskTTL     = 24 * time.Hour
+	processedTaskTimeout = 10 * time.Minute
+	resultReadTimeout    = 2 * time.Minute
+	maxResultUploadSize  = int64(256 << 20)
+	resultUploadTTL      = time.Hour
+
+	websiteUnhealthyAfter = 5
+)
+
+// websiteFailures counts the consecutive failed tasks per website ID, see recordWebsiteHealth
+var (
+	websiteFailuresMu sync.Mutex
+	websiteFailures   = map[int]int{}
+)
+
+// taskResultPruneInterval is how often expired processed tasks and uploads are deleted
+const taskResultPruneInterval = time.Hour
+
+// ErrTaskInProgress is returned for a result whose task is still being processed by an
+// earlier request
+var ErrTaskInProgress = errors.New("task result is already being processed")
+
+// InitTaskResults initializes the task result settings and starts deleting expired
+// processed tasks and unfinished uploads in the background
+func InitTaskResults(cfg *config.Config) {
+	processedTaskTTL = cfg.Results.ProcessedTTL
+	processedTaskTimeout = cfg.Results.ProcessingTimeout
+	resultReadTimeout = cfg.Results.ReadTimeout
+	maxResultUploadSize = cfg.Results.MaxUploadSize
+	resultUploadTTL = cfg.Results.UploadTTL
+	websiteUnhealthyAfter = cfg.Results.UnhealthyAfter
+
+	go func() {
+		for range time.Tick(taskResultPruneInterval) {
+			if n, err := models.DeleteExpiredProcessedTasks(); err != nil {
+				logger.Error().Err(err).Msg("Failed to delete expired processed tasks")
+			} else if n > 0 {
+				logger.Debug().Int64("tasks", n).Msg("Deleted expired processed tasks")
+			}
 
-type Chapter struct {
-	ChapterId     string
-	ChapterName   string
-	ChapterUrl    string
-	ChapterNumber int
+			if n, err := models.DeleteExpiredResultUploads(resultUploadTTL); err != nil {
+				logger.Error().Err(err).Msg("Failed to delete expired result uploads")
+			} else if n > 0 {
+				logger.Info().Int64("chunks", n).Msg("Deleted the chunks of unfinished result uploads")
+			}
+		}
+	}()
 }
 
 // InitRabbitMQService initializes the RabbitMQ service
@@ -67,26 +111,6 @@ type PublishTaskRequest struct {
 	TimeoutSec int    `json:"timeout_sec,omitempty"`
 }
 
-type TaskResultStatus string
-
-const (
-	// TaskResultStatusSuccess indicates a successful task
-	TaskResultStatusSuccess TaskResultStatus = "success"
-	// TaskResultStatusError indicates a failed task
-	TaskResultStatusError TaskResultStatus = "error"
-)
-
-type TaskResult struct {
-	TaskID      string              `json:"task_id"`
-	TaskType    rabbitmq.TaskType   `json:"task_type"`
-	Source      rabbitmq.SourceType `json:"source"`
-	Status      TaskResultStatus    `json:"status"`
-	Message     string              `json:"message"`
-	Data        json.RawMessage     `json:"data,omitempty"`
-	URL         string              `json:"url"`
-	CompletedAt time.Time           `json:"completed_at"`
-}
-
 // PublishTask handles POST /tasks/publish
 func PublishTask(w http.ResponseWriter, r *http.Request) {
 	if agentService == nil {
@@ -116,19 +140,26 @@ func PublishTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Convert source to SourceType
-	var source rabbitmq.SourceType
+	var source protocol.SourceType
 	switch req.Source {
 	case "sangtacviet":
-		source = rabbitmq.SourceTypeSangTacViet
+		source = protocol.SourceTypeSangTacViet
 	case "wikidich":
-		source = rabbitmq.SourceTypeWikiDich
+		source = protocol.SourceTypeWikiDich
 	case "metruyenchu":
-		source = rabbitmq.SourceTypeMetruyenchu
+		source = protocol.SourceTypeMetruyenchu
 	default:
 		http.Error(w, "Invalid source: "+req.Source, http.StatusBadRequest)
 		return
 	}
 
+	taskURL, err := canonicalURL(req.URL)
+	if err != nil {
+		http.Error(w, "Failed to canonicalize URL: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	req.URL = taskURL
+
 	// Set default timeout if not provided
 	if req.TimeoutSec <= 0 {
 		req.TimeoutSec = 30
@@ -139,7 +170,6 @@ func PublishTask(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	// Publish task based on task type
-	var err error
 	switch req.TaskType {
 	case "book":
 		err = agentService.PublishBookTask(ctx, source, req.URL)
@@ -166,215 +196,421 @@ func PublishTask(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// ResultTask handles POST /tasks/result
+// ResultTask handles POST /tasks/result. The body may be sent with Content-Encoding: gzip.
 func ResultTask(w http.ResponseWriter, r *http.Request) {
-	if agentService == nil {
-		http.Error(w, "RabbitMQ service not initialized", http.StatusInternalServerError)
+	extendResultReadDeadline(w)
+	writeTaskResult(w, r, r.Body)
+}
+
+// StartTask handles POST /tasks/started, sent by an agent when it starts a task
+func StartTask(w http.ResponseWriter, r *http.Request) {
+	started, err := protocol.DecodeTaskStarted(r.Body)
+	if err != nil {
+		if errors.Is(err, protocol.ErrUnsupportedVersion) {
+			http.Error(w, "Unsupported task: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Parse request body
-	var req TaskResult
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := ProcessTaskStarted(started); err != nil {
+		http.Error(w, "Failed to process started task: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Task start received successfully",
+	})
+}
+
+// ProcessTaskStarted announces a task an agent started
+func ProcessTaskStarted(started *protocol.TaskStarted) error {
+	url, err := canonicalURL(started.URL)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug().
+		Str("task_id", started.TaskID).
+		Str("task_type", string(started.TaskType)).
+		Str("agent_id", started.AgentID).
+		Str("url", url).
+		Msg("Agent started a task")
+
+	publishTaskEvent(events.TypeTaskStarted, started.AgentID, 0, 0, events.Task{
+		TaskID:   started.TaskID,
+		TaskType: string(started.TaskType),
+		Source:   string(started.Source),
+		URL:      url,
+	})
+	return nil
+}
+
+// UploadResultChunk handles PUT /tasks/result/uploads/{id}/chunks/{index}?final={true|false}.
+// A result body too large for one request is sent in chunks under an upload ID chosen by the
+// agent, indexed from 0. When the final chunk arrives the chunks are joined and the result is
+// processed like POST /tasks/result, every chunk before it must have been stored.
+func UploadResultChunk(w http.ResponseWriter, r *http.Request) {
+	extendResultReadDeadline(w)
+
+	uploadID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid upload ID", http.StatusBadRequest)
+		return
+	}
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 0 {
+		http.Error(w, "Invalid chunk index", http.StatusBadRequest)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		if middleware.IsBodyTooLarge(err) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Validate request
-	if req.TaskID == "" {
-		http.Error(w, "Task ID is required", http.StatusBadRequest)
+	size, err := models.SaveResultUploadChunk(uploadID, index, data)
+	if err != nil {
+		http.Error(w, "Failed to store chunk: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if req.TaskType == "" {
-		http.Error(w, "Task type is required", http.StatusBadRequest)
+	if size > maxResultUploadSize {
+		deleteResultUpload(uploadID)
+		http.Error(w, fmt.Sprintf("Upload too large: %d bytes, the limit is %d", size, maxResultUploadSize), http.StatusRequestEntityTooLarge)
 		return
 	}
 
-	websites, err := models.GetWebsites()
-	if len(websites) == 0 || err != nil {
-		http.Error(w, "No websites found", http.StatusInternalServerError)
+	if r.URL.Query().Get("final") != "true" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]any{
+			"upload_id": uploadID,
+			"chunk":     index,
+			"size":      size,
+		})
 		return
 	}
 
-	// Get website by SourceType
-	var website models.Website
-	for _, w := range websites {
-		if rabbitmq.SourceType(w.Name) == req.Source {
-			website = w
-			break
+	body, err := models.JoinResultUpload(uploadID, index+1)
+	if err != nil {
+		if errors.Is(err, models.ErrIncompleteUpload) {
+			http.Error(w, "Failed to join upload: "+err.Error(), http.StatusBadRequest)
+			return
 		}
+		http.Error(w, "Failed to join upload: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	logger.Debug().Interface("result", req).Msg("Received task result")
-	// Now you can use the data variable which holds the unmarshaled Book or Chapter struct
-	switch req.TaskType {
-	case rabbitmq.TaskTypeBook:
-		var book Book
-		if err := json.Unmarshal(req.Data, &book); err != nil {
+	// Chunks are kept when processing failed so the final chunk can be sent again
+	if writeTaskResult(w, r, bytes.NewReader(body)) {
+		deleteResultUpload(uploadID)
+	}
+}
+
+// writeTaskResult decodes, processes and answers a task result read from body. It reports
+// whether the result is done with, it was processed or can never be.
+func writeTaskResult(w http.ResponseWriter, r *http.Request, body io.Reader) bool {
+	// Parse and validate the result against the shared schema
+	req, err := protocol.DecodeResult(body)
+	if err != nil {
+		if middleware.IsBodyTooLarge(err) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return true
+		}
+		if errors.Is(err, protocol.ErrUnsupportedVersion) {
+			logger.Warn().Err(err).Str("remote_addr", r.RemoteAddr).Msg("Rejected task result of an unsupported schema version")
+			http.Error(w, "Unsupported task result: "+err.Error(), http.StatusBadRequest)
+			return true
+		}
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return true
+	}
+
+	outcome, err := ProcessTaskResult(req)
+	if err != nil {
+		if errors.Is(err, protocol.ErrInvalidResult) {
 			http.Error(w, "Invalid data: "+err.Error(), http.StatusBadRequest)
-			return
+			return true
 		}
-		logger.Info().Interface("book with chapters", len(book.Chapters)).Msg("Book data received")
+		if errors.Is(err, ErrTaskInProgress) {
+			http.Error(w, "Failed to process task result: "+err.Error(), http.StatusConflict)
+			return false
+		}
+		http.Error(w, "Failed to process task result: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	// Return success response
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]any{
+		"status":  "success",
+		"message": "Task result received successfully",
+	}
+	if outcome.Duplicate {
+		response["duplicate"] = true
+	}
+	if outcome.Book != nil {
+		response["novel_id"] = outcome.Book.NovelID
+		response["created"] = outcome.Book.Created
+		response["chapters"] = outcome.Book.Chapters
+	}
+	json.NewEncoder(w).Encode(response)
+	return true
+}
+
+// extendResultReadDeadline gives large results longer than the server read timeout to arrive
+func extendResultReadDeadline(w http.ResponseWriter) {
+	if err := http.NewResponseController(w).SetReadDeadline(time.Now().Add(resultReadTimeout)); err != nil {
+		logger.Debug().Err(err).Msg("Failed to extend result read deadline")
+	}
+}
+
+// deleteResultUpload deletes the chunks of an upload, failures are only logged as
+// unfinished uploads expire
+func deleteResultUpload(uploadID uuid.UUID) {
+	if err := models.DeleteResultUpload(uploadID); err != nil {
+		logger.Error().Err(err).Str("upload_id", uploadID.String()).Msg("Failed to delete result upload")
+	}
+}
+
+// TaskResultOutcome is what processing a task result did
+type TaskResultOutcome struct {
+	// Book is what a book result changed
+	Book *models.BookIngest `json:"book,omitempty"`
+	// Duplicate is set when the task was already processed and the stored outcome returned
+	Duplicate bool `json:"-"`
+}
+
+// ProcessTaskResult applies a validated task result reported by an agent, over REST or gRPC.
+// Results are applied once per task ID: a result repeated while its task is kept, see
+// InitTaskResults, returns the stored outcome and ErrTaskInProgress while the first one is
+// still being applied. Data that doesn't match the schema, or a result of a source and URL
+// matching no website, is a protocol.ErrInvalidResult.
+func ProcessTaskResult(req *protocol.TaskResult) (*TaskResultOutcome, error) {
+	if agentService == nil {
+		return nil, errors.New("RabbitMQ service not initialized")
+	}
+
+	previous, err := models.ClaimProcessedTask(req.TaskID, string(req.TaskType), processedTaskTTL, processedTaskTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		if previous.Status == models.ProcessedTaskStatusProcessing {
+			return nil, ErrTaskInProgress
+		}
+
+		var outcome TaskResultOutcome
+		if err := json.Unmarshal(previous.Outcome, &outcome); err != nil {
+			return nil, fmt.Errorf("failed to decode task outcome: %w", err)
+		}
+		outcome.Duplicate = true
+
+		logger.Info().
+			Str("task_id", req.TaskID).
+			Time("processed_at", previous.ProcessedAt.Time).
+			Msg("Task result already processed, returning its outcome")
+		return &outcome, nil
+	}
+
+	outcome, err := applyTaskResult(req)
+	if err != nil {
+		// Let the agent send the result again
+		if err := models.ReleaseProcessedTask(req.TaskID); err != nil {
+			logger.Error().Err(err).Str("task_id", req.TaskID).Msg("Failed to release task result")
+		}
+		return nil, err
+	}
 
-		// Get novel
-		existedNovel, err := models.GetNovelByUrl(book.BookUrl)
+	if err := models.CompleteProcessedTask(req.TaskID, outcome, processedTaskTTL); err != nil {
+		logger.Error().Err(err).Str("task_id", req.TaskID).Msg("Failed to store task outcome")
+	}
+	return outcome, nil
+}
+
+// applyTaskResult applies the side effects of a task result
+func applyTaskResult(req *protocol.TaskResult) (*TaskResultOutcome, error) {
+	domains, err := loadDomains()
+	if err != nil {
+		return nil, err
+	}
+
+	// URLs on an alias are stored on the primary domain of their website
+	url := domains.Canonicalize(req.URL)
+	websiteID, ok := domains.WebsiteID(url)
+	if !ok {
+		websiteID, err = websiteIDBySource(req.Source)
 		if err != nil {
-			logger.Error().Err(err).Str("url", book.BookUrl).Msg("Failed to get novel")
+			return nil, err
 		}
-		logger.Info().Interface("novel", existedNovel).Msg("Novel data received")
-		if existedNovel.ID != 0 {
-			logger.Info().Interface("novel", existedNovel).Msg("Novel already exists")
-			logger.Info().Msg("Updating novel")
-
-			// Update novel
-			// =========================================================================================================================
-			novel := mappingUpdateBookToNovel(book, existedNovel.ID)
-			if err := models.UpdateNovel(novel); err != nil {
-				http.Error(w, "Failed to update novel: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
+	}
 
-			// Update chapters
-			var updatedChapters []models.Chapter
-			for _, c := range book.Chapters {
-				chapter := mappingUpdateChapterToChapter(c, existedNovel.ID)
-				if err := models.InsertOrUpdateChapter(chapter); err != nil {
-					http.Error(w, "Failed to insert or update chapter: "+err.Error(), http.StatusInternalServerError)
-					return
-				}
-				// Convert to models.Chapter for scheduler processing
-				modelChapter := models.Chapter{
-					ID:            chapter.ID,
-					NovelID:       chapter.NovelID,
-					ExternalID:    c.ChapterId,
-					Title:         c.ChapterName,
-					URL:           c.ChapterUrl,
-					ChapterNumber: c.ChapterNumber,
-					Content:       chapter.Content,
-					CrawledAt:     chapter.CrawledAt,
-					Error:         chapter.Error,
-				}
-				updatedChapters = append(updatedChapters, modelChapter)
-			}
+	task := events.Task{
+		TaskID:   req.TaskID,
+		TaskType: string(req.TaskType),
+		Source:   string(req.Source),
+		URL:      url,
+	}
 
-			// Process book crawl result for scheduler (create chapter crawl jobs)
-			processBookCrawlForScheduler(existedNovel.ID, updatedChapters)
-			return
-		} else {
-			logger.Info().Msg("Creating novel")
-			// Create novel
-			// =========================================================================================================================
-			novel := models.Novel{
-				WebsiteID:  website.ID,
-				ExternalID: book.BookId,
-				Title:      book.BookName,
-				SourceURL:  book.BookUrl,
-				LastCrawledAt: sql.NullTime{
-					Time:  time.Now(),
-					Valid: true,
-				},
-			}
-			if err := models.CreateNovel(&novel); err != nil {
-				http.Error(w, "Failed to create novel: "+err.Error(), http.StatusInternalServerError)
-				return
+	logger.Debug().Interface("result", req).Msg("Received task result")
+	switch {
+	case req.Status == protocol.ResultStatusError:
+		logger.Warn().
+			Str("task_id", req.TaskID).
+			Str("task_type", string(req.TaskType)).
+			Str("url", url).
+			Str("error", req.Message).
+			Msg("Agent reported a failed task")
+		var novelID int
+		if req.TaskType == protocol.TaskTypeChapter {
+			if chapter, err := models.GetChapterByUrl(url); err == nil {
+				logChapterCrawlResult(chapter.ID, false, req.Message)
+				novelID = chapter.NovelID
 			}
+		}
+		task.Error = req.Message
+		publishTaskEvent(events.TypeTaskFailed, "", novelID, websiteID, task)
+		recordWebsiteHealth(websiteID, req.Message)
+		return &TaskResultOutcome{}, nil
 
-			// Create chapters
-			var createdChapters []models.Chapter
-			for _, c := range book.Chapters {
-				chapter := models.Chapter{
-					NovelID:       novel.ID,
-					ExternalID:    c.ChapterId,
-					Title:         c.ChapterName,
-					URL:           c.ChapterUrl,
-					ChapterNumber: c.ChapterNumber,
-					CrawledAt: sql.NullTime{
-						Time:  time.Now(),
-						Valid: true,
-					},
-				}
-				if err := models.CreateChapter(&chapter); err != nil {
-					http.Error(w, "Failed to create chapter: "+err.Error(), http.StatusInternalServerError)
-					return
-				}
-				createdChapters = append(createdChapters, chapter)
-			}
+	case req.TaskType == protocol.TaskTypeBook:
+		book, err := req.Book()
+		if err != nil {
+			return nil, err
+		}
+		logger.Info().Interface("book with chapters", len(book.Chapters)).Msg("Book data received")
 
-			// Process book crawl result for scheduler (create chapter crawl jobs)
-			processBookCrawlForScheduler(novel.ID, createdChapters)
+		// Store the novel and reconcile its chapters with the source list
+		result, err := ingest.Store(websiteID, *book, domains)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store book: %w", err)
 		}
 
-	case rabbitmq.TaskTypeChapter:
-		var chapterContent string
-		if err := json.Unmarshal(req.Data, &chapterContent); err != nil {
-			http.Error(w, "Invalid data: "+err.Error(), http.StatusBadRequest)
-			return
+		recordWebsiteHealth(websiteID, "")
+		publishBookChanges(websiteID, result)
+		publishTaskEvent(events.TypeTaskFinished, "", result.Summary.NovelID, websiteID, task)
+
+		novelID := result.Summary.NovelID
+		refreshNovelCover(novelID, *book)
+		if !result.Summary.Created && result.Novel.Status == models.NovelStatusOngoing {
+			reviveOngoingNovel(novelID)
+		}
+
+		// Process book crawl result for scheduler (create chapter crawl jobs)
+		processBookCrawlForScheduler(novelID)
+		windDownCompletedNovel(novelID)
+		return &TaskResultOutcome{Book: &result.Summary}, nil
+
+	case req.TaskType == protocol.TaskTypeChapter:
+		chapterContent, err := req.ChapterContent()
+		if err != nil {
+			return nil, err
+		}
+		logger.Info().Interface("chapter", url).Msg("Chapter data received")
+
+		chapter := &models.Chapter{
+			Content:       chapterContent.Content,
+			RichContent:   chapterContent.RichContent,
+			ContentFormat: chapterContent.ContentFormat,
+			PageCount:     chapterContent.Pages,
+		}
+		if err := sanitizeRichContent(chapter); err != nil {
+			return nil, err
 		}
-		logger.Info().Interface("chapter", req.URL).Msg("Chapter data received")
 
-		updateErr := models.UpdateChapterByUrl(req.URL, chapterContent)
+		changes, updateErr := models.UpdateChapterByUrl(url, chapter, revisionRetention)
 		if updateErr != nil {
 			// Log chapter crawl failure
-			if chapter, err := models.GetChapterByUrl(req.URL); err == nil {
+			if chapter, err := models.GetChapterByUrl(url); err == nil {
 				logChapterCrawlResult(chapter.ID, false, updateErr.Error())
 			}
-			http.Error(w, "Failed to update chapter content: "+updateErr.Error(), http.StatusInternalServerError)
-			return
+			return nil, fmt.Errorf("failed to update chapter content: %w", updateErr)
 		}
 
-		// Log successful chapter crawl
-		if chapter, err := models.GetChapterByUrl(req.URL); err == nil {
-			logChapterCrawlResult(chapter.ID, true, "")
+		recordWebsiteHealth(websiteID, "")
+
+		// Log the crawl outcome and announce chapters whose content was replaced
+		novelIDs := map[int]bool{}
+		var novelID int
+		for _, change := range changes {
+			novelIDs[change.NovelID] = true
+			novelID = change.NovelID
+			switch {
+			case change.Unchanged:
+				logChapterCrawlStatus(change.ChapterID, models.CrawlLogStatusUnchanged, "")
+			case change.Replaced:
+				logChapterCrawlStatus(change.ChapterID, models.CrawlLogStatusUpdated, "")
+				publishChapterUpdated(url, change)
+			default:
+				logChapterCrawlStatus(change.ChapterID, models.CrawlLogStatusSuccess, "")
+			}
 		}
-	}
 
-	// Return success response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "success",
-		"message": "Task result received successfully",
-	})
-}
+		publishTaskEvent(events.TypeTaskFinished, "", novelID, websiteID, task)
 
-func mappingUpdateBookToNovel(book Book, novelID int) *models.Novel {
-	novel := &models.Novel{
-		ID: novelID,
-		LastCrawledAt: sql.NullTime{
-			Time:  time.Now(),
-			Valid: true,
-		},
-	}
+		// The last missing chapter of a completed novel ends its final sweep
+		for novelID := range novelIDs {
+			if novelID != 0 {
+				windDownCompletedNovel(novelID)
+			}
+		}
 
-	if book.BookName != "" {
-		novel.Title = book.BookName
-	}
-	if book.BookUrl != "" {
-		novel.SourceURL = book.BookUrl
+	default:
+		publishTaskEvent(events.TypeTaskFinished, "", 0, websiteID, task)
 	}
 
-	return novel
+	return &TaskResultOutcome{}, nil
 }
 
-func mappingUpdateChapterToChapter(c Chapter, novelID int) *models.Chapter {
-	chapter := &models.Chapter{
-		NovelID: novelID,
-		CrawledAt: sql.NullTime{
-			Time:  time.Now(),
-			Valid: true,
-		},
+// publishTaskEvent announces an event of a task. The novel of the task URL is looked up
+// when novelID is 0, websiteID is then taken from the novel when it is 0 too.
+func publishTaskEvent(eventType, agentID string, novelID, websiteID int, task events.Task) {
+	if novelID == 0 {
+		id, website, err := models.GetURLNovel(task.URL)
+		if err != nil {
+			logger.Warn().Err(err).Str("url", task.URL).Msg("Failed to look up the novel of a task")
+		}
+		novelID = id
+		if websiteID == 0 {
+			websiteID = website
+		}
 	}
 
-	if c.ChapterName != "" {
-		chapter.Title = c.ChapterName
+	events.Publish(events.Event{
+		Type:      eventType,
+		NovelID:   novelID,
+		WebsiteID: websiteID,
+		AgentID:   agentID,
+		Data:      task,
+	})
+}
+
+// websiteIDBySource returns the ID of the website named after a source, for results whose
+// URL is on none of the website domains. A source without website is a protocol.ErrInvalidResult.
+func websiteIDBySource(source protocol.SourceType) (int, error) {
+	websites, err := models.GetWebsites()
+	if len(websites) == 0 || err != nil {
+		return 0, errors.New("no websites found")
 	}
-	if c.ChapterUrl != "" {
-		chapter.URL = c.ChapterUrl
+
+	for _, w := range websites {
+		if protocol.SourceType(w.Name) == source {
+			return w.ID, nil
+		}
 	}
-	return chapter
+	return 0, fmt.Errorf("%w: no website for source %q", protocol.ErrInvalidResult, source)
 }
 
 // processBookCrawlForScheduler processes book crawl results for scheduler
-func processBookCrawlForScheduler(novelID int, chapters []models.Chapter) {
+func processBookCrawlForScheduler(novelID int) {
 	// Update novel's last_crawled_at
 	err := models.UpdateNovelLastCrawledAt(novelID)
 	if err != nil {
@@ -405,47 +641,59 @@ func processBookCrawlForScheduler(novelID int, chapters []models.Chapter) {
 		return
 	}
 
-	sourceType := rabbitmq.SourceType(website.Name)
+	sourceType := protocol.SourceType(website.Name)
+
+	// Only chapters without content or whose last crawl failed are crawled, the
+	// chapters of the book listing have no content
+	chapters, err := models.GetChaptersToCrawl(novelID)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Int("novel_id", novelID).
+			Msg("Failed to get chapters to crawl")
+		return
+	}
 
 	// Create chapter crawl tasks for chapters that need content
 	for _, chapter := range chapters {
-		// Check if chapter needs crawling (no content or failed status)
-		if chapter.Content == "" || chapter.Error != "" {
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-
-			err = agentService.PublishChapterTask(ctx, sourceType, chapter.URL)
-			if err != nil {
-				logger.Error().
-					Err(err).
-					Int("chapter_id", chapter.ID).
-					Str("chapter_url", chapter.URL).
-					Msg("Failed to publish chapter task")
-				cancel()
-				continue
-			}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 
-			cancel()
-
-			logger.Info().
+		err = agentService.PublishChapterTask(ctx, sourceType, chapter.URL)
+		if err != nil {
+			logger.Error().
+				Err(err).
 				Int("chapter_id", chapter.ID).
 				Str("chapter_url", chapter.URL).
-				Msg("Published chapter crawl task")
+				Msg("Failed to publish chapter task")
+			cancel()
+			continue
 		}
+
+		cancel()
+
+		logger.Info().
+			Int("chapter_id", chapter.ID).
+			Str("chapter_url", chapter.URL).
+			Msg("Published chapter crawl task")
 	}
 
 	logger.Info().
 		Int("novel_id", novelID).
-		Int("total_chapters", len(chapters)).
+		Int("queued_chapters", len(chapters)).
 		Msg("Processed book crawl result for scheduler")
 }
 
 // logChapterCrawlResult logs the result of a chapter crawl
 func logChapterCrawlResult(chapterID int, success bool, errorMsg string) {
-	status := "success"
+	status := models.CrawlLogStatusSuccess
 	if !success {
-		status = "failed"
+		status = models.CrawlLogStatusFailed
 	}
+	logChapterCrawlStatus(chapterID, status, errorMsg)
+}
 
+// logChapterCrawlStatus logs a chapter crawl with the given status
+func logChapterCrawlStatus(chapterID int, status, errorMsg string) {
 	log := &models.ChapterCrawlLog{
 		ChapterID: chapterID,
 		Status:    status,
@@ -467,3 +715,99 @@ func logChapterCrawlResult(chapterID int, success bool, errorMsg string) {
 		Str("status", status).
 		Msg("Logged chapter crawl result")
 }
+
+// publishBookChanges announces the novel a book crawl created and the chapters it added
+func publishBookChanges(websiteID int, result *ingest.Result) {
+	novelID := result.Summary.NovelID
+	if result.Summary.Created {
+		events.Publish(events.Event{
+			Type:      events.TypeNovelCreated,
+			NovelID:   novelID,
+			WebsiteID: websiteID,
+			Data:      events.NovelCreated{Title: result.Novel.Title, SourceURL: result.Novel.SourceURL},
+		})
+	}
+
+	if len(result.Summary.Chapters.AddedIDs) == 0 {
+		return
+	}
+	added := make(map[int]bool, len(result.Summary.Chapters.AddedIDs))
+	for _, id := range result.Summary.Chapters.AddedIDs {
+		added[id] = true
+	}
+	var data events.ChapterAdded
+	for _, c := range result.Chapters {
+		if added[c.ID] {
+			data.Chapters = append(data.Chapters, events.AddedChapter{
+				ChapterID: c.ID,
+				Title:     c.Title,
+				URL:       c.URL,
+				Sequence:  c.Sequence,
+			})
+		}
+	}
+	events.Publish(events.Event{
+		Type:      events.TypeChapterAdded,
+		NovelID:   novelID,
+		WebsiteID: websiteID,
+		Data:      data,
+	})
+}
+
+// recordWebsiteHealth counts the consecutive failed tasks of a website, failure is the
+// error of a failed task and empty for a successful one. The website is announced unhealthy
+// once its failures reach websiteUnhealthyAfter.
+func recordWebsiteHealth(websiteID int, failure string) {
+	if websiteID == 0 || websiteUnhealthyAfter <= 0 {
+		return
+	}
+
+	websiteFailuresMu.Lock()
+	if failure == "" {
+		delete(websiteFailures, websiteID)
+		websiteFailuresMu.Unlock()
+		return
+	}
+	websiteFailures[websiteID]++
+	failures := websiteFailures[websiteID]
+	websiteFailuresMu.Unlock()
+
+	if failures != websiteUnhealthyAfter {
+		return
+	}
+	logger.Warn().
+		Int("website_id", websiteID).
+		Int("failures", failures).
+		Str("error", failure).
+		Msg("Website tasks keep failing")
+
+	events.Publish(events.Event{
+		Type:      events.TypeWebsiteUnhealthy,
+		WebsiteID: websiteID,
+		Data:      events.WebsiteUnhealthy{ConsecutiveFailures: failures, LastError: failure},
+	})
+}
+
+// publishChapterUpdated announces that a recrawl replaced the content of a chapter
+func publishChapterUpdated(url string, change models.ContentChange) {
+	logger.Info().
+		Int("chapter_id", change.ChapterID).
+		Int("website_id", change.WebsiteID).
+		Int("length_delta", change.NewLength-change.OldLength).
+		Msg("Chapter content changed on recrawl")
+
+	events.Publish(events.Event{
+		Type:      events.TypeChapterUpdated,
+		NovelID:   change.NovelID,
+		WebsiteID: change.WebsiteID,
+		Data: events.ChapterUpdated{
+			ChapterID:   change.ChapterID,
+			URL:         url,
+			OldHash:     change.OldHash,
+			NewHash:     change.NewHash,
+			OldLength:   change.OldLength,
+			NewLength:   change.NewLength,
+			LengthDelta: change.NewLength - change.OldLength,
+		},
+	})
+}
@@ -8,16 +8,39 @@ import (
 	"cct/models"
 )
 
-// GetUsers handles GET /users
+// GetUsers handles GET /users?active={true|false}&created_after={date}&created_before={date}.
+// Sorts: id (default), email, created_at.
 func GetUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := models.GetUsers()
+	query := r.URL.Query()
+
+	opts, err := parseListOptions(query, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields, err := parseFields[models.User](query)
 	if err != nil {
-		http.Error(w, "Failed to get users: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	var filter models.UserFilter
+	if filter.Active, err = parseBoolParam(query, "active"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.CreatedAfter, filter.CreatedBefore, err = parseTimeRange(query, "created"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := models.ListUsers(filter, opts)
+	if err != nil {
+		listError(w, "users", err)
+		return
+	}
+
+	writePage(w, page, fields)
 }
 
 // GetUser handles GET /users/{id}
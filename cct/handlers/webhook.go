@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"cct/models"
+	"cct/pkg/webhooks"
+)
+
+// defaultDeliveryLimit is the number of delivery attempts listed when no limit is given
+const defaultDeliveryLimit = 50
+
+// validateWebhook checks the URL and event types of a webhook. Webhooks are decoded enabled,
+// unless enabled is false.
+func validateWebhook(h *models.Webhook) error {
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an http or https URL")
+	}
+	for _, t := range h.EventTypes {
+		if !webhooks.IsEventType(t) {
+			return fmt.Errorf("unknown event type %q, expected one of %v", t, webhooks.EventTypes)
+		}
+	}
+
+	// Empty filters match everything
+	if h.EventTypes == nil {
+		h.EventTypes = []string{}
+	}
+	if h.NovelIDs == nil {
+		h.NovelIDs = []int64{}
+	}
+	return nil
+}
+
+// GetWebhooks handles GET /webhooks. Secrets are not returned.
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := models.GetWebhooks()
+	if err != nil {
+		http.Error(w, "Failed to get webhooks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hooks)
+}
+
+// GetWebhook handles GET /webhooks/{id}. The secret is not returned.
+func GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := models.GetWebhook(id)
+	if err != nil {
+		http.Error(w, "Failed to get webhook: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	hook.Secret = ""
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hook)
+}
+
+// CreateWebhook handles POST /webhooks. A secret is generated when none is given, it is
+// only returned here.
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	hook := models.Webhook{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateWebhook(&hook); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := models.CreateWebhook(&hook); err != nil {
+		http.Error(w, "Failed to create webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hook)
+}
+
+// UpdateWebhook handles PUT /webhooks/{id}. The secret is kept when none is given.
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	hook := models.Webhook{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateWebhook(&hook); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Ensure ID in URL matches ID in body
+	hook.ID = id
+
+	if err := models.UpdateWebhook(&hook); err != nil {
+		http.Error(w, "Failed to update webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hook.Secret = ""
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hook)
+}
+
+// DeleteWebhook handles DELETE /webhooks/{id}
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteWebhook(id); err != nil {
+		http.Error(w, "Failed to delete webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries handles GET /webhooks/{id}/deliveries?limit={n}, the last delivery
+// attempts of a webhook, newest first
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(limit, maxListLimit)
+	}
+
+	deliveries, err := models.GetWebhookDeliveries(id, limit)
+	if err != nil {
+		http.Error(w, "Failed to get webhook deliveries: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
@@ -6,18 +6,42 @@ import (
 	"strconv"
 
 	"cct/models"
+	"cct/pkg/events"
 )
 
-// GetWebsites handles GET /websites
+// GetWebsites handles GET /websites?enabled={true|false}&created_after={date}&created_before={date}.
+// Sorts: id (default), name, created_at.
 func GetWebsites(w http.ResponseWriter, r *http.Request) {
-	websites, err := models.GetWebsites()
+	query := r.URL.Query()
+
+	opts, err := parseListOptions(query, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields, err := parseFields[models.Website](query)
 	if err != nil {
-		http.Error(w, "Failed to get websites: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(websites)
+	var filter models.WebsiteFilter
+	if filter.Enabled, err = parseBoolParam(query, "enabled"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.CreatedAfter, filter.CreatedBefore, err = parseTimeRange(query, "created"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := models.ListWebsites(filter, opts)
+	if err != nil {
+		listError(w, "websites", err)
+		return
+	}
+
+	writePage(w, page, fields)
 }
 
 // GetWebsite handles GET /websites/{id}
@@ -52,6 +76,8 @@ func CreateWebsite(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to create website: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	addPrimaryDomain(&website)
+	events.Publish(events.Event{Type: events.TypeWebsiteUpdated, WebsiteID: website.ID})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -81,6 +107,7 @@ func UpdateWebsite(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to update website: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	events.Publish(events.Event{Type: events.TypeWebsiteUpdated, WebsiteID: website.ID})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(website)
@@ -100,6 +127,7 @@ func DeleteWebsite(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to delete website: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	events.Publish(events.Event{Type: events.TypeWebsiteUpdated, WebsiteID: id})
 
 	w.WriteHeader(http.StatusNoContent)
 }
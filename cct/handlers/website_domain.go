@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"cct/models"
+	"cct/pkg/events"
+	"cct/pkg/logger"
+)
+
+// WebsiteDomainRequest is the body of POST /websites/{id}/domains
+type WebsiteDomainRequest struct {
+	Domain    string `json:"domain"`
+	IsPrimary bool   `json:"is_primary"`
+}
+
+// DomainMoveRequest is the body of POST /websites/{id}/domains/move
+type DomainMoveRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// domainCacheTTL is how long the website domains are cached, domain changes made by
+// another cct instance apply after it
+const domainCacheTTL = time.Minute
+
+// domainCache holds the website domains, dropped whenever a website changes
+var domainCache struct {
+	sync.Mutex
+	domains  *models.Domains
+	loadedAt time.Time
+}
+
+// InitDomainCache drops the cached website domains on every website.updated event
+func InitDomainCache() {
+	ch, _ := events.Subscribe(0)
+	go func() {
+		for e := range ch {
+			if e.Type == events.TypeWebsiteUpdated {
+				domainCache.Lock()
+				domainCache.domains = nil
+				domainCache.Unlock()
+			}
+		}
+	}()
+}
+
+// loadDomains returns the cached website domains, loading them when they are missing
+// or expired
+func loadDomains() (*models.Domains, error) {
+	domainCache.Lock()
+	defer domainCache.Unlock()
+
+	if domainCache.domains != nil && time.Since(domainCache.loadedAt) < domainCacheTTL {
+		return domainCache.domains, nil
+	}
+	domains, err := models.LoadDomains()
+	if err != nil {
+		return nil, err
+	}
+	domainCache.domains = domains
+	domainCache.loadedAt = time.Now()
+	return domains, nil
+}
+
+// canonicalURL returns a URL on a website domain on the primary domain of the website
+func canonicalURL(rawURL string) (string, error) {
+	if rawURL == "" {
+		return rawURL, nil
+	}
+	domains, err := loadDomains()
+	if err != nil {
+		return "", err
+	}
+	return domains.Canonicalize(rawURL), nil
+}
+
+// addPrimaryDomain makes the host of the base URL of a new website its primary domain
+func addPrimaryDomain(website *models.Website) {
+	domain, err := models.NormalizeDomain(website.BaseURL)
+	if err != nil {
+		logger.Warn().Err(err).Int("website_id", website.ID).Msg("Website has no primary domain")
+		return
+	}
+	d := models.WebsiteDomain{WebsiteID: website.ID, Domain: domain, IsPrimary: true}
+	if err := models.AddWebsiteDomain(&d); err != nil {
+		logger.Warn().Err(err).Int("website_id", website.ID).Str("domain", domain).Msg("Failed to add primary domain")
+	}
+}
+
+// domainError writes the response for a failed domain change
+func domainError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, models.ErrDomainNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, models.ErrDomainTaken), errors.Is(err, models.ErrPrimaryDomain):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Failed to "+action+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GetWebsiteDomains handles GET /websites/{id}/domains
+func GetWebsiteDomains(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid website ID", http.StatusBadRequest)
+		return
+	}
+
+	domains, err := models.GetWebsiteDomains(id)
+	if err != nil {
+		http.Error(w, "Failed to get website domains: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(domains)
+}
+
+// AddWebsiteDomain handles POST /websites/{id}/domains. The domain is added as an alias,
+// a new primary domain goes through MoveWebsiteDomain so stored URLs are rewritten.
+func AddWebsiteDomain(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid website ID", http.StatusBadRequest)
+		return
+	}
+
+	var req WebsiteDomainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.IsPrimary {
+		http.Error(w, "A primary domain can't be added, move the website to it with POST /api/websites/{id}/domains/move", http.StatusBadRequest)
+		return
+	}
+	domain, err := models.NormalizeDomain(req.Domain)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := models.GetWebsite(id); err != nil {
+		http.Error(w, "Failed to get website: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	d := models.WebsiteDomain{WebsiteID: id, Domain: domain}
+	if err := models.AddWebsiteDomain(&d); err != nil {
+		domainError(w, "add website domain", err)
+		return
+	}
+	events.Publish(events.Event{Type: events.TypeWebsiteUpdated, WebsiteID: id})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(d)
+}
+
+// DeleteWebsiteDomain handles DELETE /websites/{id}/domains/{domain}. The primary domain
+// can't be removed.
+func DeleteWebsiteDomain(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid website ID", http.StatusBadRequest)
+		return
+	}
+	domain, err := models.NormalizeDomain(r.PathValue("domain"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteWebsiteDomain(id, domain); err != nil {
+		domainError(w, "delete website domain", err)
+		return
+	}
+	events.Publish(events.Event{Type: events.TypeWebsiteUpdated, WebsiteID: id})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MoveWebsiteDomain handles POST /websites/{id}/domains/move. The website moves to the new
+// domain as its primary domain and the stored novel and chapter URLs on the old domain are
+// rewritten to it. The old domain is kept as an alias.
+func MoveWebsiteDomain(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid website ID", http.StatusBadRequest)
+		return
+	}
+
+	var req DomainMoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := models.NormalizeDomain(req.From)
+	if err != nil {
+		http.Error(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := models.NormalizeDomain(req.To)
+	if err != nil {
+		http.Error(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from == to {
+		http.Error(w, "The domains must differ", http.StatusBadRequest)
+		return
+	}
+
+	move, err := models.MoveWebsiteDomain(id, from, to)
+	if err != nil {
+		domainError(w, "move website domain", err)
+		return
+	}
+	events.Publish(events.Event{Type: events.TypeWebsiteUpdated, WebsiteID: id})
+
+	logger.Info().
+		Int("website_id", id).
+		Str("from", from).
+		Str("to", to).
+		Int64("novels", move.Novels).
+		Int64("chapters", move.Chapters).
+		Msg("Moved website domain")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(move)
+}
@@ -8,10 +8,13 @@ import (
 	"time"
 
 	"cct/config"
+	"cct/control"
 	"cct/handlers"
 	"cct/middleware"
+	"cct/models"
 	"cct/pkg/logger"
 	"cct/pkg/scheduler"
+	"cct/pkg/webhooks"
 	"cct/utils"
 )
 
@@ -34,6 +37,33 @@ func main() {
 	}
 	defer utils.CloseDB()
 
+	// Initialize blob store
+	if err := handlers.InitBlobStore(cfg); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to initialize blob store")
+	}
+
+	// Initialize chapter revision settings
+	handlers.InitChapterRevisions(cfg)
+
+	// Initialize schedule settings
+	handlers.InitSchedules(cfg)
+
+	// Initialize task result settings
+	handlers.InitTaskResults(cfg)
+
+	// Cache the website domains until a website changes
+	handlers.InitDomainCache()
+
+	// Initialize chapter content storage
+	if err := models.SetContentStorage(cfg.Chapters.ContentStorage); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to initialize chapter content storage")
+	}
+
+	// Initialize novel exports
+	if err := handlers.InitExports(cfg); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to initialize exports")
+	}
+
 	// Initialize RabbitMQ service
 	if err := handlers.InitRabbitMQService(cfg); err != nil {
 		logger.Fatal().Err(err).Msg("Failed to initialize RabbitMQ service")
@@ -56,6 +86,25 @@ func main() {
 		logger.Info().Msg("Scheduler started successfully")
 	}
 
+	// Start the gRPC control API for agents if enabled
+	if cfg.GRPC.Enabled {
+		controlServer, err := control.NewServer(cfg)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to create gRPC control API")
+		}
+		if err := controlServer.Start(); err != nil {
+			logger.Fatal().Err(err).Msg("Failed to start gRPC control API")
+		}
+		defer controlServer.Stop()
+	}
+
+	// Deliver events to the webhooks if enabled
+	if cfg.Webhooks.Enabled {
+		dispatcher := webhooks.NewDispatcher(cfg)
+		dispatcher.Start()
+		defer dispatcher.Stop()
+	}
+
 	// Create a new router
 	mux := http.NewServeMux()
 
@@ -66,6 +115,10 @@ func main() {
 	mux.HandleFunc("POST /api/websites", handlers.CreateWebsite)
 	mux.HandleFunc("PUT /api/websites/{id}", handlers.UpdateWebsite)
 	mux.HandleFunc("DELETE /api/websites/{id}", handlers.DeleteWebsite)
+	mux.HandleFunc("GET /api/websites/{id}/domains", handlers.GetWebsiteDomains)
+	mux.HandleFunc("POST /api/websites/{id}/domains", handlers.AddWebsiteDomain)
+	mux.HandleFunc("POST /api/websites/{id}/domains/move", handlers.MoveWebsiteDomain)
+	mux.HandleFunc("DELETE /api/websites/{id}/domains/{domain}", handlers.DeleteWebsiteDomain)
 
 	// Novels
 	mux.HandleFunc("GET /api/novels", handlers.GetNovels)
@@ -73,6 +126,15 @@ func main() {
 	mux.HandleFunc("POST /api/novels", handlers.CreateNovel)
 	mux.HandleFunc("PUT /api/novels/{id}", handlers.UpdateNovel)
 	mux.HandleFunc("DELETE /api/novels/{id}", handlers.DeleteNovel)
+	mux.HandleFunc("GET /api/novels/{id}/cover", handlers.GetNovelCover)
+	mux.HandleFunc("PUT /api/novels/{id}/cover", handlers.UploadNovelCover)
+	mux.HandleFunc("GET /api/novels/{id}/export", handlers.ExportNovel)
+	mux.HandleFunc("GET /api/novels/{id}/exports", handlers.GetNovelExports)
+	mux.HandleFunc("POST /api/novels/{id}/chapters/renumber", handlers.RenumberChapters)
+
+	// Exports
+	mux.HandleFunc("GET /api/exports/{id}", handlers.GetExportJob)
+	mux.HandleFunc("GET /api/exports/{id}/download", handlers.DownloadExport)
 
 	// Chapters
 	mux.HandleFunc("GET /api/chapters", handlers.GetChapters)
@@ -110,9 +172,30 @@ func main() {
 	// Chapter crawl logs
 	mux.HandleFunc("GET /api/chapters/{id}/logs", handlers.GetChapterCrawlLogs)
 
+	// Chapter revisions
+	mux.HandleFunc("GET /api/chapters/{id}/revisions", handlers.GetChapterRevisions)
+	mux.HandleFunc("GET /api/chapters/{id}/revisions/diff", handlers.DiffChapterRevisions)
+	mux.HandleFunc("GET /api/chapters/{id}/revisions/{revision_id}", handlers.GetChapterRevision)
+
+	// Search
+	mux.HandleFunc("GET /api/search", handlers.SearchChapters)
+
 	// RabbitMQ Tasks
 	mux.HandleFunc("POST /api/tasks/publish", handlers.PublishTask)
+	mux.HandleFunc("POST /api/tasks/started", handlers.StartTask)
 	mux.HandleFunc("POST /api/tasks/result", handlers.ResultTask)
+	mux.HandleFunc("PUT /api/tasks/result/uploads/{id}/chunks/{index}", handlers.UploadResultChunk)
+
+	// Webhooks
+	mux.HandleFunc("GET /api/webhooks", handlers.GetWebhooks)
+	mux.HandleFunc("GET /api/webhooks/{id}", handlers.GetWebhook)
+	mux.HandleFunc("POST /api/webhooks", handlers.CreateWebhook)
+	mux.HandleFunc("PUT /api/webhooks/{id}", handlers.UpdateWebhook)
+	mux.HandleFunc("DELETE /api/webhooks/{id}", handlers.DeleteWebhook)
+	mux.HandleFunc("GET /api/webhooks/{id}/deliveries", handlers.GetWebhookDeliveries)
+
+	// Live events
+	mux.HandleFunc("GET /api/events", handlers.StreamEvents)
 
 	// Authentication
 	mux.HandleFunc("POST /api/auth/login", handlers.Login)
@@ -121,6 +204,9 @@ func main() {
 	// Apply middleware
 	var handler http.Handler = mux
 
+	// Limit request bodies and decode gzip bodies
+	handler = middleware.BodyMiddleware(cfg.Server.MaxBodySize, cfg.Server.MaxDecodedBodySize)(handler)
+
 	// Enable authentication if configured
 	if cfg.Auth.Enabled {
 		handler = middleware.AuthMiddleware(handler)
@@ -185,3 +271,8 @@ func (lw *loggingResponseWriter) WriteHeader(code int) {
 	lw.statusCode = code
 	lw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap returns the wrapped response writer, for http.ResponseController
+func (lw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"net/http"
 	"time"
 
@@ -62,48 +63,20 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		}
 
 		// Validate the token
-		var userID int
-		var expiresAt sql.NullTime
-		err := utils.DB.QueryRow(`
-			SELECT user_id, expires_at
-			FROM api_tokens
-			WHERE token = $1
-		`, token).Scan(&userID, &expiresAt)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
-				logger.Debug().Str("path", r.URL.Path).Msg("Invalid token")
-				return
-			}
-			http.Error(w, "Failed to validate token: "+err.Error(), http.StatusInternalServerError)
-			logger.Error().Err(err).Str("path", r.URL.Path).Msg("Failed to validate token")
+		userID, err := ValidateToken(token)
+		switch {
+		case errors.Is(err, ErrInvalidToken):
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			logger.Debug().Str("path", r.URL.Path).Msg("Invalid token")
 			return
-		}
-
-		// Check if the token has expired
-		if expiresAt.Valid && expiresAt.Time.Before(time.Now()) {
+		case errors.Is(err, ErrTokenExpired):
 			http.Error(w, "Token has expired", http.StatusUnauthorized)
-			logger.Debug().
-				Str("path", r.URL.Path).
-				Time("expires_at", expiresAt.Time).
-				Msg("Token expired")
+			logger.Debug().Str("path", r.URL.Path).Msg("Token expired")
+			return
+		case err != nil:
+			http.Error(w, "Failed to validate token: "+err.Error(), http.StatusInternalServerError)
+			logger.Error().Err(err).Str("path", r.URL.Path).Msg("Failed to validate token")
 			return
-		}
-
-		// Update last_used_at
-		_, err = utils.DB.Exec(`
-			UPDATE api_tokens
-			SET last_used_at = NOW()
-			WHERE token = $1
-		`, token)
-		if err != nil {
-			// Log the error but don't fail the request
-			// This is not critical for the request to succeed
-			logger.Warn().
-				Err(err).
-				Str("path", r.URL.Path).
-				Int("user_id", userID).
-				Msg("Failed to update token last_used_at")
 		}
 
 		// Set the user ID in the request context
@@ -113,3 +86,49 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// Errors returned by ValidateToken
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token has expired")
+)
+
+// ValidateToken checks an API token and returns the ID of its user. The last use of the
+// token is recorded.
+func ValidateToken(token string) (int, error) {
+	var userID int
+	var expiresAt sql.NullTime
+	err := utils.DB.QueryRow(`
+		SELECT user_id, expires_at
+		FROM api_tokens
+		WHERE token = $1
+	`, token).Scan(&userID, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrInvalidToken
+		}
+		return 0, err
+	}
+
+	// Check if the token has expired
+	if expiresAt.Valid && expiresAt.Time.Before(time.Now()) {
+		return 0, ErrTokenExpired
+	}
+
+	// Update last_used_at
+	_, err = utils.DB.Exec(`
+		UPDATE api_tokens
+		SET last_used_at = NOW()
+		WHERE token = $1
+	`, token)
+	if err != nil {
+		// Log the error but don't fail the request
+		// This is not critical for the request to succeed
+		logger.Warn().
+			Err(err).
+			Int("user_id", userID).
+			Msg("Failed to update token last_used_at")
+	}
+
+	return userID, nil
+}
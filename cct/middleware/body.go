@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"strings"
+
+	"cct/pkg/logger"
+)
+
+// BodyMiddleware limits request bodies to maxBody bytes as sent and decodes bodies sent with
+// Content-Encoding: gzip, limited to maxDecoded bytes once decoded. Reading past a limit
+// fails with an *http.MaxBytesError, see IsBodyTooLarge.
+func BodyMiddleware(maxBody, maxDecoded int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBody {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+
+			switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+			case "", "identity":
+			case "gzip":
+				zr, err := gzip.NewReader(r.Body)
+				if IsBodyTooLarge(err) {
+					http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				if err != nil {
+					http.Error(w, "Invalid gzip request body: "+err.Error(), http.StatusBadRequest)
+					logger.Debug().Err(err).Str("path", r.URL.Path).Msg("Invalid gzip request body")
+					return
+				}
+				defer zr.Close()
+
+				// Handlers read the decoded body
+				r.Body = http.MaxBytesReader(w, zr, maxDecoded)
+				r.Header.Del("Content-Encoding")
+				r.Header.Del("Content-Length")
+				r.ContentLength = -1
+			default:
+				http.Error(w, "Unsupported Content-Encoding: "+encoding, http.StatusUnsupportedMediaType)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IsBodyTooLarge reports whether err comes from reading a request body past its limit
+func IsBodyTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
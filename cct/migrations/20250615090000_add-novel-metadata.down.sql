@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS public.novels_status_idx;
+DROP INDEX IF EXISTS public.novels_tags_idx;
+DROP INDEX IF EXISTS public.novels_genres_idx;
+
+ALTER TABLE public.novels DROP COLUMN IF EXISTS tags;
+ALTER TABLE public.novels DROP COLUMN IF EXISTS genres;
+ALTER TABLE public.novels DROP COLUMN IF EXISTS description;
+ALTER TABLE public.novels DROP COLUMN IF EXISTS cover_url;
+ALTER TABLE public.novels DROP COLUMN IF EXISTS author;
@@ -0,0 +1,11 @@
+-- Restore the novel metadata columns dropped by add-schedule-feature
+ALTER TABLE public.novels ADD COLUMN IF NOT EXISTS author TEXT;
+ALTER TABLE public.novels ADD COLUMN IF NOT EXISTS cover_url TEXT;
+ALTER TABLE public.novels ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE public.novels ADD COLUMN IF NOT EXISTS genres TEXT[];
+ALTER TABLE public.novels ADD COLUMN IF NOT EXISTS tags TEXT[];
+
+-- Indexes for metadata filters
+CREATE INDEX IF NOT EXISTS novels_genres_idx ON public.novels USING GIN (genres);
+CREATE INDEX IF NOT EXISTS novels_tags_idx ON public.novels USING GIN (tags);
+CREATE INDEX IF NOT EXISTS novels_status_idx ON public.novels (status);
@@ -0,0 +1,4 @@
+ALTER TABLE public.novels DROP COLUMN IF EXISTS cover_updated_at;
+ALTER TABLE public.novels DROP COLUMN IF EXISTS cover_source_url;
+ALTER TABLE public.novels DROP COLUMN IF EXISTS cover_content_type;
+ALTER TABLE public.novels DROP COLUMN IF EXISTS cover_hash;
@@ -0,0 +1,6 @@
+-- Locally stored cover image, cover_hash is the blob store key
+ALTER TABLE public.novels ADD COLUMN IF NOT EXISTS cover_hash TEXT;
+ALTER TABLE public.novels ADD COLUMN IF NOT EXISTS cover_content_type TEXT;
+-- Upstream URL the stored cover was downloaded from, compared with cover_url to refresh it
+ALTER TABLE public.novels ADD COLUMN IF NOT EXISTS cover_source_url TEXT;
+ALTER TABLE public.novels ADD COLUMN IF NOT EXISTS cover_updated_at TIMESTAMP;
@@ -0,0 +1,2 @@
+ALTER TABLE public.chapters DROP COLUMN IF EXISTS content_format;
+ALTER TABLE public.chapters DROP COLUMN IF EXISTS rich_content;
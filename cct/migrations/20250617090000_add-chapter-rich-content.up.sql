@@ -0,0 +1,4 @@
+-- Sanitized chapter markup kept next to the plain text content
+ALTER TABLE public.chapters ADD COLUMN IF NOT EXISTS rich_content TEXT;
+ALTER TABLE public.chapters ADD COLUMN IF NOT EXISTS content_format TEXT NOT NULL DEFAULT 'text'
+  CONSTRAINT chapters_content_format_check CHECK (content_format IN ('text', 'html', 'markdown'));
@@ -0,0 +1 @@
+ALTER TABLE public.chapters DROP COLUMN IF EXISTS page_count;
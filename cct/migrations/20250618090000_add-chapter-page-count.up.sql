@@ -0,0 +1,2 @@
+-- Number of source pages a chapter was merged from
+ALTER TABLE public.chapters ADD COLUMN IF NOT EXISTS page_count INTEGER NOT NULL DEFAULT 1;
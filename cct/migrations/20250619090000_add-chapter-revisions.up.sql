@@ -0,0 +1,14 @@
+-- Previous versions of a chapter, saved when its content is replaced
+CREATE TABLE IF NOT EXISTS chapter_revisions (
+  id SERIAL PRIMARY KEY,
+  chapter_id INTEGER NOT NULL REFERENCES chapters(id) ON DELETE CASCADE,
+  content TEXT NOT NULL,
+  rich_content TEXT,
+  content_format TEXT NOT NULL DEFAULT 'text',
+  content_hash TEXT NOT NULL,
+  content_length INTEGER NOT NULL,
+  crawled_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_chapter_revisions_chapter_id ON chapter_revisions (chapter_id, id DESC);
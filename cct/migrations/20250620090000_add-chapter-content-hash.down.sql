@@ -0,0 +1,7 @@
+UPDATE public.chapter_crawl_logs SET status = 'success' WHERE status IN ('unchanged', 'updated');
+ALTER TABLE public.chapter_crawl_logs DROP CONSTRAINT IF EXISTS chapter_crawl_logs_status_check;
+ALTER TABLE public.chapter_crawl_logs ADD CONSTRAINT chapter_crawl_logs_status_check
+  CHECK (status IN ('success', 'failed'));
+
+ALTER TABLE public.chapters DROP COLUMN IF EXISTS content_length;
+ALTER TABLE public.chapters DROP COLUMN IF EXISTS content_hash;
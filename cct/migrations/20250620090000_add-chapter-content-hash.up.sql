@@ -0,0 +1,9 @@
+-- Hash of the normalized content, a recrawl with the same hash is not written
+ALTER TABLE public.chapters ADD COLUMN IF NOT EXISTS content_hash TEXT;
+ALTER TABLE public.chapters ADD COLUMN IF NOT EXISTS content_length INTEGER;
+
+-- unchanged: the recrawled content matched the stored hash
+-- updated: the recrawl replaced different content
+ALTER TABLE public.chapter_crawl_logs DROP CONSTRAINT IF EXISTS chapter_crawl_logs_status_check;
+ALTER TABLE public.chapter_crawl_logs ADD CONSTRAINT chapter_crawl_logs_status_check
+  CHECK (status IN ('success', 'failed', 'unchanged', 'updated'));
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_chapters_search_vector;
+DROP TRIGGER IF EXISTS chapters_search_vector_trigger ON public.chapters;
+DROP FUNCTION IF EXISTS chapters_search_vector_update();
+ALTER TABLE public.chapters DROP COLUMN IF EXISTS search_vector;
+DROP TEXT SEARCH CONFIGURATION IF EXISTS vn_unaccent;
@@ -0,0 +1,35 @@
+-- Full-text search over chapter titles and content. vn_unaccent is the simple
+-- configuration with diacritics removed, so "chuong" matches "chương".
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'vn_unaccent') THEN
+    CREATE TEXT SEARCH CONFIGURATION vn_unaccent (COPY = simple);
+    ALTER TEXT SEARCH CONFIGURATION vn_unaccent
+      ALTER MAPPING FOR hword, hword_part, word WITH unaccent, simple;
+  END IF;
+END
+$$;
+
+ALTER TABLE public.chapters ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION chapters_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('vn_unaccent', COALESCE(NEW.title, '')), 'A') ||
+    setweight(to_tsvector('vn_unaccent', COALESCE(NEW.content, '')), 'B');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS chapters_search_vector_trigger ON public.chapters;
+CREATE TRIGGER chapters_search_vector_trigger
+  BEFORE INSERT OR UPDATE OF title, content ON public.chapters
+  FOR EACH ROW EXECUTE FUNCTION chapters_search_vector_update();
+
+UPDATE public.chapters SET search_vector =
+  setweight(to_tsvector('vn_unaccent', COALESCE(title, '')), 'A') ||
+  setweight(to_tsvector('vn_unaccent', COALESCE(content, '')), 'B');
+
+CREATE INDEX IF NOT EXISTS idx_chapters_search_vector ON public.chapters USING GIN (search_vector);
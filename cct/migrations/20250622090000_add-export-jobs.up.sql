@@ -0,0 +1,18 @@
+-- Novel exports built in the background
+CREATE TABLE IF NOT EXISTS export_jobs (
+  id SERIAL PRIMARY KEY,
+  novel_id INTEGER NOT NULL REFERENCES novels(id) ON DELETE CASCADE,
+  format TEXT NOT NULL,
+  from_chapter INTEGER NOT NULL DEFAULT 0,
+  to_chapter INTEGER NOT NULL DEFAULT 0,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+  file_name TEXT,
+  file_size BIGINT NOT NULL DEFAULT 0,
+  chapter_count INTEGER NOT NULL DEFAULT 0,
+  missing_count INTEGER NOT NULL DEFAULT 0,
+  error TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_export_jobs_novel_id ON export_jobs (novel_id, id DESC);
@@ -0,0 +1,30 @@
+-- Content stored out of row would be lost, move it back first with
+-- cct content-migrate -to inline
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM public.chapters WHERE content_key IS NOT NULL OR rich_content_key IS NOT NULL) THEN
+    RAISE EXCEPTION 'chapters have compressed content, run content-migrate -to inline first';
+  END IF;
+END
+$$;
+
+CREATE OR REPLACE FUNCTION chapters_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('vn_unaccent', COALESCE(NEW.title, '')), 'A') ||
+    setweight(to_tsvector('vn_unaccent', COALESCE(NEW.content, '')), 'B');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS chapters_search_vector_trigger ON public.chapters;
+CREATE TRIGGER chapters_search_vector_trigger
+  BEFORE INSERT OR UPDATE OF title, content ON public.chapters
+  FOR EACH ROW EXECUTE FUNCTION chapters_search_vector_update();
+
+DROP INDEX IF EXISTS idx_chapters_rich_content_key;
+DROP INDEX IF EXISTS idx_chapters_content_key;
+ALTER TABLE public.chapters
+  DROP COLUMN IF EXISTS rich_content_key,
+  DROP COLUMN IF EXISTS content_key;
+DROP TABLE IF EXISTS public.chapter_contents;
@@ -0,0 +1,47 @@
+-- Compressed chapter content stored out of row, keyed by the sha256 of the uncompressed
+-- bytes so identical content is stored once. data is a zstd frame.
+CREATE TABLE IF NOT EXISTS public.chapter_contents (
+  hash TEXT PRIMARY KEY,
+  size INTEGER NOT NULL,
+  data BYTEA NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+ALTER TABLE public.chapters
+  ADD COLUMN IF NOT EXISTS content_key TEXT REFERENCES public.chapter_contents (hash),
+  ADD COLUMN IF NOT EXISTS rich_content_key TEXT REFERENCES public.chapter_contents (hash);
+
+CREATE INDEX IF NOT EXISTS idx_chapters_content_key ON public.chapters (content_key);
+CREATE INDEX IF NOT EXISTS idx_chapters_rich_content_key ON public.chapters (rich_content_key);
+
+-- The database can't read compressed content, writers storing it out of row set
+-- search_vector themselves. Otherwise the content lexemes of the row are kept.
+CREATE OR REPLACE FUNCTION chapters_search_vector_update() RETURNS trigger AS $$
+DECLARE
+  body tsvector;
+BEGIN
+  IF TG_OP = 'INSERT' AND NEW.search_vector IS NOT NULL THEN
+    RETURN NEW;
+  END IF;
+  IF TG_OP = 'UPDATE' AND NEW.search_vector IS DISTINCT FROM OLD.search_vector THEN
+    RETURN NEW;
+  END IF;
+
+  IF NEW.content IS NULL AND NEW.content_key IS NOT NULL THEN
+    body := ''::tsvector;
+    IF TG_OP = 'UPDATE' THEN
+      body := ts_filter(COALESCE(OLD.search_vector, ''::tsvector), '{b}');
+    END IF;
+  ELSE
+    body := setweight(to_tsvector('vn_unaccent', COALESCE(NEW.content, '')), 'B');
+  END IF;
+
+  NEW.search_vector := setweight(to_tsvector('vn_unaccent', COALESCE(NEW.title, '')), 'A') || body;
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS chapters_search_vector_trigger ON public.chapters;
+CREATE TRIGGER chapters_search_vector_trigger
+  BEFORE INSERT OR UPDATE OF title, content, content_key ON public.chapters
+  FOR EACH ROW EXECUTE FUNCTION chapters_search_vector_update();
@@ -0,0 +1,9 @@
+-- Restore the schedules that were wound down
+UPDATE public.novel_schedules
+SET enabled = true, interval_seconds = COALESCE(active_interval_seconds, interval_seconds), updated_at = now()
+WHERE wound_down_at IS NOT NULL;
+
+ALTER TABLE public.novel_schedules
+  DROP COLUMN IF EXISTS active_interval_seconds,
+  DROP COLUMN IF EXISTS wound_down_at,
+  DROP COLUMN IF EXISTS keep_active;
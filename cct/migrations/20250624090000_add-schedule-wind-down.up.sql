@@ -0,0 +1,7 @@
+-- Schedules of completed novels are wound down once every chapter has content.
+-- keep_active opts a schedule out, active_interval_seconds is the interval restored
+-- when the novel is reported ongoing again.
+ALTER TABLE public.novel_schedules
+  ADD COLUMN IF NOT EXISTS keep_active BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS wound_down_at TIMESTAMP,
+  ADD COLUMN IF NOT EXISTS active_interval_seconds INT;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_chapters_novel_sequence;
+ALTER TABLE public.chapters
+  DROP COLUMN IF EXISTS ordering_locked,
+  DROP COLUMN IF EXISTS part,
+  DROP COLUMN IF EXISTS volume,
+  DROP COLUMN IF EXISTS sequence;
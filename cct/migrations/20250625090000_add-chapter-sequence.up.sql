@@ -0,0 +1,18 @@
+-- Chapters are ordered by their position in the source chapter list instead of the
+-- number parsed from their title. volume and part optionally group them, 0 is none.
+-- ordering_locked keeps a manual renumbering from being overwritten by crawls.
+ALTER TABLE public.chapters
+  ADD COLUMN IF NOT EXISTS sequence INT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS volume INT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS part INT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS ordering_locked BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE public.chapters c
+SET sequence = o.sequence
+FROM (
+  SELECT id, ROW_NUMBER() OVER (PARTITION BY novel_id ORDER BY COALESCE(chapter_number, 0), id) AS sequence
+  FROM public.chapters
+) o
+WHERE o.id = c.id;
+
+CREATE INDEX IF NOT EXISTS idx_chapters_novel_sequence ON public.chapters (novel_id, sequence, id);
@@ -0,0 +1,3 @@
+ALTER TABLE public.export_jobs DROP COLUMN IF EXISTS with_removed;
+DROP INDEX IF EXISTS idx_chapters_novel_removed;
+ALTER TABLE public.chapters DROP COLUMN IF EXISTS removed_at;
@@ -0,0 +1,5 @@
+-- Chapters no longer listed by their source are marked removed instead of deleted
+ALTER TABLE public.chapters ADD COLUMN IF NOT EXISTS removed_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_chapters_novel_removed ON public.chapters (novel_id) WHERE removed_at IS NOT NULL;
+
+ALTER TABLE public.export_jobs ADD COLUMN IF NOT EXISTS with_removed BOOLEAN NOT NULL DEFAULT false;
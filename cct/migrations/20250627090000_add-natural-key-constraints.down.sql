@@ -0,0 +1,2 @@
+ALTER TABLE public.chapters DROP CONSTRAINT IF EXISTS chapters_novel_external_id_key;
+ALTER TABLE public.novels DROP CONSTRAINT IF EXISTS novels_website_external_id_key;
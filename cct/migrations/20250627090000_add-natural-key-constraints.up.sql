@@ -0,0 +1,63 @@
+-- Novels are unique per website by external ID and chapters per novel, so results
+-- delivered twice are upserted instead of creating duplicates. Empty IDs are NULL,
+-- which the constraints don't compare.
+UPDATE public.novels SET external_id = NULL WHERE external_id = '';
+UPDATE public.chapters SET external_id = NULL WHERE external_id = '';
+
+-- Duplicate novels are merged into the oldest one
+CREATE TEMP TABLE novel_duplicates AS
+SELECT id, keep_id
+FROM (
+  SELECT id, MIN(id) OVER (PARTITION BY website_id, external_id) AS keep_id
+  FROM public.novels
+  WHERE external_id IS NOT NULL
+) n
+WHERE id <> keep_id;
+
+UPDATE public.chapters t SET novel_id = d.keep_id FROM novel_duplicates d WHERE t.novel_id = d.id;
+UPDATE public.novel_schedules t SET novel_id = d.keep_id FROM novel_duplicates d WHERE t.novel_id = d.id;
+UPDATE public.crawl_jobs t SET novel_id = d.keep_id FROM novel_duplicates d WHERE t.novel_id = d.id;
+UPDATE public.export_jobs t SET novel_id = d.keep_id FROM novel_duplicates d WHERE t.novel_id = d.id;
+DELETE FROM public.novels WHERE id IN (SELECT id FROM novel_duplicates);
+
+-- A merged novel keeps one schedule, an enabled one due first, so it isn't crawled once
+-- per schedule of its duplicates
+DELETE FROM public.novel_schedules
+WHERE id IN (
+  SELECT id
+  FROM (
+    SELECT id, ROW_NUMBER() OVER (
+      PARTITION BY novel_id
+      ORDER BY enabled IS NOT FALSE DESC, next_run_at NULLS LAST, id
+    ) AS rank
+    FROM public.novel_schedules
+    WHERE novel_id IN (SELECT keep_id FROM novel_duplicates)
+  ) s
+  WHERE rank > 1
+);
+DROP TABLE novel_duplicates;
+
+-- Of duplicate chapters the one with content is kept, then the last crawled
+DELETE FROM public.chapters
+WHERE id IN (
+  SELECT id
+  FROM (
+    SELECT id, ROW_NUMBER() OVER (
+      PARTITION BY novel_id, external_id
+      ORDER BY (content_key IS NOT NULL OR COALESCE(content, '') <> '') DESC,
+               crawled_at DESC NULLS LAST, id
+    ) AS rank
+    FROM public.chapters
+    WHERE external_id IS NOT NULL
+  ) c
+  WHERE rank > 1
+);
+
+DELETE FROM public.chapter_contents cc
+WHERE NOT EXISTS (SELECT 1 FROM public.chapters c WHERE c.content_key = cc.hash)
+  AND NOT EXISTS (SELECT 1 FROM public.chapters c WHERE c.rich_content_key = cc.hash);
+
+ALTER TABLE public.novels
+  ADD CONSTRAINT novels_website_external_id_key UNIQUE (website_id, external_id);
+ALTER TABLE public.chapters
+  ADD CONSTRAINT chapters_novel_external_id_key UNIQUE (novel_id, external_id);
@@ -0,0 +1,12 @@
+-- Task results already applied, a repeated result returns the stored outcome
+CREATE TABLE IF NOT EXISTS processed_tasks (
+  task_id TEXT PRIMARY KEY,
+  task_type TEXT NOT NULL,
+  status TEXT NOT NULL CHECK (status IN ('processing', 'processed')),
+  outcome JSONB,
+  claimed_at TIMESTAMP NOT NULL DEFAULT now(),
+  processed_at TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_processed_tasks_expires_at ON processed_tasks (expires_at);
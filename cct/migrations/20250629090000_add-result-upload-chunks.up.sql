@@ -0,0 +1,10 @@
+-- Chunks of task results uploaded in parts, joined when the final chunk arrives
+CREATE TABLE IF NOT EXISTS result_upload_chunks (
+  upload_id UUID NOT NULL,
+  chunk_index INTEGER NOT NULL CHECK (chunk_index >= 0),
+  data BYTEA NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT now(),
+  PRIMARY KEY (upload_id, chunk_index)
+);
+
+CREATE INDEX IF NOT EXISTS idx_result_upload_chunks_created_at ON result_upload_chunks (created_at);
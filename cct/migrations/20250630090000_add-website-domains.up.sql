@@ -0,0 +1,18 @@
+-- Domains a website is served on. URLs on an alias are stored on the primary domain.
+CREATE TABLE IF NOT EXISTS website_domains (
+  id SERIAL PRIMARY KEY,
+  website_id INTEGER NOT NULL REFERENCES websites(id) ON DELETE CASCADE,
+  domain TEXT NOT NULL UNIQUE,
+  is_primary BOOLEAN NOT NULL DEFAULT false,
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_website_domains_website_id ON website_domains (website_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_website_domains_primary ON website_domains (website_id) WHERE is_primary;
+
+-- The host of the base URL is the primary domain of existing websites
+INSERT INTO website_domains (website_id, domain, is_primary)
+SELECT id, substring(lower(base_url) from '^[a-z][a-z0-9+.-]*://([^/:?#]+)'), true
+FROM websites
+WHERE lower(base_url) ~ '^[a-z][a-z0-9+.-]*://[^/:?#]+'
+ON CONFLICT (domain) DO NOTHING;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_chapters_url;
+DROP INDEX IF EXISTS idx_novels_source_url;
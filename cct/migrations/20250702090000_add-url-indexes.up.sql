@@ -0,0 +1,3 @@
+-- Task events look up the novel of a task by its URL
+CREATE INDEX IF NOT EXISTS idx_novels_source_url ON public.novels (source_url);
+CREATE INDEX IF NOT EXISTS idx_chapters_url ON public.chapters (url);
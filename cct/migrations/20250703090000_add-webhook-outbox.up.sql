@@ -0,0 +1,14 @@
+-- Deliveries waiting to be sent, claimed by the webhook workers and deleted after the
+-- last attempt so pending deliveries survive restarts
+CREATE TABLE IF NOT EXISTS webhook_outbox (
+  id BIGSERIAL PRIMARY KEY,
+  webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+  delivery_id UUID NOT NULL,
+  event_type TEXT NOT NULL,
+  payload BYTEA NOT NULL,
+  attempt INTEGER NOT NULL DEFAULT 1,
+  next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+  created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_outbox_next_attempt_at ON webhook_outbox (next_attempt_at);
@@ -31,6 +31,50 @@ func GetAgent(id uuid.UUID) (Agent, error) {
 	return a, nil
 }
 
+// agentList lists agents, newest first when sorted by the default created_at descending
+var agentList = listSpec{
+	Name:    "agents",
+	From:    "agents",
+	Columns: "id, name, ip_address, last_heartbeat, is_active, created_at",
+	ID:      "id",
+	IDType:  "uuid",
+	Sorts: map[string]sortField{
+		"name":           {Expr: "name", Type: "text"},
+		"created_at":     {Expr: "COALESCE(created_at, '-infinity')", Type: "timestamp"},
+		"last_heartbeat": {Expr: "COALESCE(last_heartbeat, '-infinity')", Type: "timestamp"},
+	},
+	DefaultSort: "created_at",
+}
+
+// scanAgent scans a row selected with the agentList columns
+func scanAgent(row rowScanner) (Agent, error) {
+	var a Agent
+	err := row.Scan(&a.ID, &a.Name, &a.IPAddress, &a.LastHeartbeat, &a.IsActive, &a.CreatedAt)
+	return a, err
+}
+
+// ListAgents retrieves a page of the agents matching the filter
+func ListAgents(filter AgentFilter, opts ListOptions) (*Page[Agent], error) {
+	params := []interface{}{}
+	conditions := []string{}
+
+	if filter.ActiveOnly {
+		params = append(params, true)
+		conditions = append(conditions, fmt.Sprintf("is_active = $%d", len(params)))
+	}
+	if filter.IPAddress != "" {
+		params = append(params, filter.IPAddress)
+		conditions = append(conditions, fmt.Sprintf("ip_address = $%d", len(params)))
+	}
+	if filter.Name != "" {
+		params = append(params, filter.Name)
+		conditions = append(conditions, fmt.Sprintf("name = $%d", len(params)))
+	}
+	conditions, params = addTimeRange(conditions, params, "last_heartbeat", filter.HeartbeatAfter, filter.HeartbeatBefore)
+
+	return listPage(agentList, conditions, params, opts, scanAgent)
+}
+
 // GetAgents retrieves all agents
 func GetAgents(isActive bool, ipAddress, name string) ([]Agent, error) {
 	query := `
@@ -144,25 +188,35 @@ func UpdateAgentHeartbeat(id uuid.UUID) error {
 	return nil
 }
 
-// DeactivateInactiveAgents marks agents as inactive if they haven't sent a heartbeat in the specified duration
-func DeactivateInactiveAgents(inactiveDuration time.Duration) (int64, error) {
+// DeactivateInactiveAgents marks agents as inactive if they haven't sent a heartbeat in the specified duration,
+// returning the IDs of the deactivated agents
+func DeactivateInactiveAgents(inactiveDuration time.Duration) ([]uuid.UUID, error) {
 	cutoffTime := time.Now().Add(-inactiveDuration)
 
-	result, err := utils.DB.Exec(`
+	rows, err := utils.DB.Query(`
 		UPDATE agents
 		SET is_active = false
 		WHERE is_active = true AND (last_heartbeat IS NULL OR last_heartbeat < $1)
+		RETURNING id
 	`, cutoffTime)
 	if err != nil {
-		return 0, fmt.Errorf("failed to deactivate inactive agents: %w", err)
+		return nil, fmt.Errorf("failed to deactivate inactive agents: %w", err)
 	}
+	defer rows.Close()
 
-	count, err := result.RowsAffected()
-	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan agent ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deactivated agents: %w", err)
 	}
 
-	return count, nil
+	return ids, nil
 }
 
 // DeleteAgent deletes an agent by ID
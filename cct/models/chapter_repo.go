@@ -2,55 +2,109 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"unicode/utf8"
 
 	"cct/utils"
+
+	"github.com/lib/pq"
 )
 
-// GetChapters retrieves all chapters from the database
-func GetChapters() ([]Chapter, error) {
-	rows, err := utils.DB.Query(`
-		SELECT id, novel_id, external_id, title, chapter_number, url, 
-		       content, crawled_at, error
-		FROM chapters
-		ORDER BY novel_id, chapter_number
-	`)
+// chapterColumns is the column list scanned by scanChapter. Content stored out of row is
+// selected compressed after the other columns.
+const chapterColumns = `id, novel_id, COALESCE(external_id, ''), title, chapter_number, url,
+	COALESCE(content, ''), COALESCE(rich_content, ''), content_format, page_count,
+	COALESCE(content_hash, ''), crawled_at, COALESCE(error, ''),
+	sequence, volume, part, ordering_locked, removed_at,
+	(SELECT data FROM chapter_contents WHERE hash = content_key),
+	(SELECT data FROM chapter_contents WHERE hash = rich_content_key)`
+
+// chapterColumnsWithoutContent selects the same columns as chapterColumns with an empty content
+const chapterColumnsWithoutContent = `id, novel_id, COALESCE(external_id, ''), title, chapter_number, url,
+	'', '', content_format, page_count,
+	COALESCE(content_hash, ''), crawled_at, COALESCE(error, ''),
+	sequence, volume, part, ordering_locked, removed_at,
+	NULL::bytea, NULL::bytea`
+
+// chapterList lists chapters, in source order by default
+var chapterList = listSpec{
+	Name:    "chapters",
+	From:    "chapters",
+	Columns: chapterColumns,
+	ID:      "id",
+	IDType:  "integer",
+	Sorts: map[string]sortField{
+		"id":             {Expr: "id", Type: "integer"},
+		"sequence":       {Expr: "sequence", Type: "integer"},
+		"chapter_number": {Expr: "COALESCE(chapter_number, 0)", Type: "integer"},
+		"title":          {Expr: "COALESCE(title, '')", Type: "text"},
+		"crawled_at":     {Expr: "COALESCE(crawled_at, '-infinity')", Type: "timestamp"},
+	},
+	DefaultSort: "sequence",
+}
+
+// scanChapter scans a row selected with chapterColumns, decompressing content stored out of row
+func scanChapter(row rowScanner) (Chapter, error) {
+	var c Chapter
+	var content, richContent []byte
+	err := row.Scan(
+		&c.ID, &c.NovelID, &c.ExternalID, &c.Title, &c.ChapterNumber, &c.URL,
+		&c.Content, &c.RichContent, &c.ContentFormat, &c.PageCount, &c.ContentHash, &c.CrawledAt, &c.Error,
+		&c.Sequence, &c.Volume, &c.Part, &c.OrderLocked, &c.RemovedAt, &content, &richContent,
+	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query chapters: %w", err)
+		return c, err
 	}
-	defer rows.Close()
 
-	var chapters []Chapter
-	for rows.Next() {
-		var c Chapter
-		if err := rows.Scan(
-			&c.ID, &c.NovelID, &c.ExternalID, &c.Title, &c.ChapterNumber, &c.URL,
-			&c.Content, &c.CrawledAt, &c.Error,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan chapter row: %w", err)
+	if content != nil {
+		if c.Content, err = decodeContent(content); err != nil {
+			return c, err
 		}
-		chapters = append(chapters, c)
 	}
+	if richContent != nil {
+		if c.RichContent, err = decodeContent(richContent); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating chapter rows: %w", err)
+// ListChapters retrieves a page of the chapters matching the filter
+func ListChapters(filter ChapterFilter, opts ListOptions) (*Page[Chapter], error) {
+	params := []interface{}{}
+	conditions := []string{}
+
+	if filter.NovelID != 0 {
+		params = append(params, filter.NovelID)
+		conditions = append(conditions, fmt.Sprintf("novel_id = $%d", len(params)))
+	}
+	conditions, params = sequenceRange("sequence", filter.FromChapter, filter.ToChapter, conditions, params)
+	if filter.Crawled != nil {
+		conditions = append(conditions, nullCondition("crawled_at", *filter.Crawled))
+	}
+	if filter.HasError != nil {
+		if *filter.HasError {
+			conditions = append(conditions, "COALESCE(error, '') <> ''")
+		} else {
+			conditions = append(conditions, "COALESCE(error, '') = ''")
+		}
 	}
+	if filter.Removed != nil {
+		conditions = append(conditions, nullCondition("removed_at", *filter.Removed))
+	}
+	conditions, params = addTimeRange(conditions, params, "crawled_at", filter.CrawledAfter, filter.CrawledBefore)
 
-	return chapters, nil
+	spec := chapterList
+	if filter.WithoutContent {
+		spec.Columns = chapterColumnsWithoutContent
+	}
+	return listPage(spec, conditions, params, opts, scanChapter)
 }
 
 // GetChapter retrieves a chapter by ID
 func GetChapter(id int) (Chapter, error) {
-	var c Chapter
-	err := utils.DB.QueryRow(`
-		SELECT id, novel_id, external_id, title, chapter_number, url,
-		       content, crawled_at, error
-		FROM chapters
-		WHERE id = $1
-	`, id).Scan(
-		&c.ID, &c.NovelID, &c.ExternalID, &c.Title, &c.ChapterNumber, &c.URL,
-		&c.Content, &c.CrawledAt, &c.Error,
-	)
+	c, err := scanChapter(utils.DB.QueryRow("SELECT "+chapterColumns+" FROM chapters WHERE id = $1", id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return Chapter{}, fmt.Errorf("chapter with ID %d not found", id)
@@ -63,12 +117,7 @@ func GetChapter(id int) (Chapter, error) {
 
 // GetChapterByUrl retrieves a chapter by URL
 func GetChapterByUrl(url string) (Chapter, error) {
-	var c Chapter
-	err := utils.DB.QueryRow(`
-		SELECT id, novel_id, external_id, title, chapter_number, url, content, crawled_at, error
-		FROM chapters
-		WHERE url = $1
-	`, url).Scan(&c.ID, &c.NovelID, &c.ExternalID, &c.Title, &c.ChapterNumber, &c.URL, &c.Content, &c.CrawledAt, &c.Error)
+	c, err := scanChapter(utils.DB.QueryRow("SELECT "+chapterColumns+" FROM chapters WHERE url = $1", url))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return Chapter{}, fmt.Errorf("chapter with URL %s not found", url)
@@ -79,32 +128,29 @@ func GetChapterByUrl(url string) (Chapter, error) {
 	return c, nil
 }
 
-// GetChaptersByNovel retrieves all chapters for a specific novel
-func GetChaptersByNovel(novelID int) ([]Chapter, error) {
+// GetChaptersToCrawl retrieves the listed chapters of a novel that have no content or whose
+// last crawl failed, in source order. Their content is not selected.
+func GetChaptersToCrawl(novelID int) ([]Chapter, error) {
 	rows, err := utils.DB.Query(`
-		SELECT id, novel_id, external_id, title, chapter_number, url, 
-		       content, crawled_at, error
+		SELECT `+chapterColumnsWithoutContent+`
 		FROM chapters
-		WHERE novel_id = $1
-		ORDER BY chapter_number
+		WHERE novel_id = $1 AND removed_at IS NULL
+		  AND ((content_key IS NULL AND COALESCE(content, '') = '') OR COALESCE(error, '') <> '')
+		ORDER BY sequence, id
 	`, novelID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query chapters by novel: %w", err)
+		return nil, fmt.Errorf("failed to query chapters to crawl: %w", err)
 	}
 	defer rows.Close()
 
-	var chapters []Chapter
+	chapters := []Chapter{}
 	for rows.Next() {
-		var c Chapter
-		if err := rows.Scan(
-			&c.ID, &c.NovelID, &c.ExternalID, &c.Title, &c.ChapterNumber, &c.URL,
-			&c.Content, &c.CrawledAt, &c.Error,
-		); err != nil {
+		c, err := scanChapter(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan chapter row: %w", err)
 		}
 		chapters = append(chapters, c)
 	}
-
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating chapter rows: %w", err)
 	}
@@ -114,61 +160,569 @@ func GetChaptersByNovel(novelID int) ([]Chapter, error) {
 
 // CreateChapter creates a new chapter in the database
 func CreateChapter(c *Chapter) error {
-	err := utils.DB.QueryRow(`
-		INSERT INTO chapters (novel_id, external_id, title, chapter_number, url, 
-		                     content)
-		VALUES ($1, $2, $3, $4, $5, $6)
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := createChapter(tx, c); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// createChapter inserts a chapter in tx and sets its ID
+func createChapter(tx *sql.Tx, c *Chapter) error {
+	stored, err := storeContent(tx, c.Content, c.RichContent)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO chapters (novel_id, external_id, title, chapter_number, url,
+		                     content, rich_content, content_format, content_hash, content_length,
+		                     content_key, rich_content_key, search_vector, sequence, volume, part)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12, `+searchVectorSQL("$3", "$13")+`,
+		        $14, $15, $16)
 		RETURNING id
 	`, c.NovelID, c.ExternalID, c.Title, c.ChapterNumber, c.URL,
-		c.Content).Scan(&c.ID)
+		stored.Content, stored.RichContent, contentFormat(c), optionalContentHash(c.Content),
+		utf8.RuneCountInString(c.Content), stored.ContentKey, stored.RichContentKey, stored.SearchText,
+		c.Sequence, c.Volume, c.Part).Scan(&c.ID)
 	if err != nil {
 		return fmt.Errorf("failed to create chapter: %w", err)
 	}
-
 	return nil
 }
 
-// UpdateChapter updates an existing chapter
-func UpdateChapter(c *Chapter) error {
-	_, err := utils.DB.Exec(`
+// UpdateChapter updates an existing chapter. Replaced content is kept as a revision,
+// up to keepRevisions revisions per chapter (0 keeps all).
+func UpdateChapter(c *Chapter, keepRevisions int) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockChapterContent(tx, "c.id = $1", c.ID)
+	if err != nil {
+		return err
+	}
+	hash := ContentHash(c.Content)
+	for _, old := range current {
+		if old.ContentHash != hash {
+			if err := archiveChapterContent(tx, old, keepRevisions); err != nil {
+				return err
+			}
+		}
+	}
+
+	stored, err := storeContent(tx, c.Content, c.RichContent)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
 		UPDATE chapters
-		SET novel_id = $1, external_id = $2, title = $3, chapter_number = $4, 
-		    url = $5, content = $6, crawled_at = $7, error = $8
-		WHERE id = $9
+		SET novel_id = $1, external_id = NULLIF($2, ''), title = $3, chapter_number = $4,
+		    url = $5, content = $6, rich_content = $7, content_format = $8,
+		    page_count = GREATEST($9, 1), crawled_at = $10, error = $11,
+		    content_hash = $12, content_length = $13, content_key = $15, rich_content_key = $16,
+		    search_vector = COALESCE(`+searchVectorSQL("$3", "$17")+`, search_vector),
+		    sequence = $18, volume = $19, part = $20, ordering_locked = $21
+		WHERE id = $14
 	`, c.NovelID, c.ExternalID, c.Title, c.ChapterNumber, c.URL,
-		c.Content, c.CrawledAt, c.Error, c.ID)
+		stored.Content, stored.RichContent, contentFormat(c), c.PageCount, c.CrawledAt, c.Error,
+		hash, utf8.RuneCountInString(c.Content), c.ID, stored.ContentKey, stored.RichContentKey, stored.SearchText,
+		c.Sequence, c.Volume, c.Part, c.OrderLocked)
 	if err != nil {
 		return fmt.Errorf("failed to update chapter: %w", err)
 	}
 
-	return nil
+	for _, old := range current {
+		if err := pruneContentBlobs(tx, old.ContentKey, old.RichContentKey); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-func UpdateChapterByUrl(url string, content string) error {
-	_, err := utils.DB.Exec("UPDATE chapters SET content = $1, crawled_at = now() WHERE url = $2", content, url)
-	return err
+// ContentChange is the outcome of storing crawled content for one chapter
+type ContentChange struct {
+	ChapterID int
+	NovelID   int
+	WebsiteID int
+	// Unchanged is set when the content matches the stored content hash, it was not written again
+	Unchanged bool
+	// Replaced is set when the chapter already had a different content
+	Replaced  bool
+	OldHash   string
+	NewHash   string
+	OldLength int
+	NewLength int
 }
 
-func InsertOrUpdateChapter(c *Chapter) error {
-	var existingChapter Chapter
-	err := utils.DB.QueryRow("SELECT * FROM chapter WHERE novel_id = $1 AND external_id = $2", c.NovelID, c.ExternalID).Scan(&existingChapter)
+// UpdateChapterByUrl stores the crawled content of the chapters with the given URL and
+// clears their error. Content that matches the stored content hash is not written again,
+// only the crawl time and the rich content and page count when they differ. Replaced
+// content is kept as a revision, up to keepRevisions revisions per chapter (0 keeps all).
+func UpdateChapterByUrl(url string, c *Chapter, keepRevisions int) ([]ContentChange, error) {
+	tx, err := utils.DB.Begin()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			_, err = utils.DB.Exec("INSERT INTO chapter (novel_id, external_id, chapter_number, title, url, content, crawled_at) VALUES ($1, $2, $3, $4, $5, $6, $7)", c.NovelID, c.ExternalID, c.ChapterNumber, c.Title, c.URL, c.Content, c.CrawledAt)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockChapterContent(tx, "c.url = $1", url)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := ContentHash(c.Content)
+	length := utf8.RuneCountInString(c.Content)
+	var stored *storedContent
+	changes := make([]ContentChange, 0, len(current))
+	for _, old := range current {
+		change := ContentChange{
+			ChapterID: old.ID,
+			NovelID:   old.NovelID,
+			WebsiteID: old.WebsiteID,
+			Unchanged: old.Content != "" && old.ContentHash == hash,
+			Replaced:  old.Content != "" && old.ContentHash != hash,
+			OldHash:   old.ContentHash,
+			NewHash:   hash,
+			OldLength: utf8.RuneCountInString(old.Content),
+			NewLength: length,
+		}
+		changes = append(changes, change)
+
+		if change.Unchanged {
+			if err := touchUnchangedChapter(tx, old, c); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := archiveChapterContent(tx, old, keepRevisions); err != nil {
+			return nil, err
+		}
+
+		if stored == nil {
+			s, err := storeContent(tx, c.Content, c.RichContent)
+			if err != nil {
+				return nil, err
+			}
+			stored = &s
+		}
+
+		_, err = tx.Exec(`
+			UPDATE chapters
+			SET content = $1, rich_content = $2, content_format = $3,
+			    page_count = GREATEST($4, 1), content_hash = $5, content_length = $6, crawled_at = now(),
+			    error = NULL, content_key = $8, rich_content_key = $9,
+			    search_vector = COALESCE(`+searchVectorSQL("title", "$10")+`, search_vector)
+			WHERE id = $7
+		`, stored.Content, stored.RichContent, contentFormat(c), c.PageCount, hash, length, old.ID,
+			stored.ContentKey, stored.RichContentKey, stored.SearchText)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update chapter content: %w", err)
+		}
+
+		if err := pruneContentBlobs(tx, old.ContentKey, old.RichContentKey); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit chapter content: %w", err)
+	}
+	return changes, nil
+}
+
+// touchUnchangedChapter records a recrawl of a chapter whose content matches the stored
+// content hash. The content and search vector are left as is, the crawl time is updated,
+// the error cleared, and the rich content and page count are written when they differ.
+func touchUnchangedChapter(tx *sql.Tx, old chapterContentRow, c *Chapter) error {
+	pageCount := max(c.PageCount, 1)
+	if old.RichContent == c.RichContent && old.ContentFormat == contentFormat(c) {
+		_, err := tx.Exec(`
+			UPDATE chapters
+			SET crawled_at = now(), error = NULL,
+			    page_count = $2
+			WHERE id = $1
+		`, old.ID, pageCount)
+		if err != nil {
+			return fmt.Errorf("failed to update chapter crawl time: %w", err)
+		}
+		return nil
+	}
+
+	richContent := sql.NullString{String: c.RichContent, Valid: c.RichContent != ""}
+	var richContentKey sql.NullString
+	if contentStorage == ContentStorageCompressed && c.RichContent != "" {
+		key, err := putContentBlob(tx, c.RichContent)
+		if err != nil {
 			return err
 		}
-		return err
+		richContent, richContentKey = sql.NullString{}, sql.NullString{String: key, Valid: true}
+	}
+
+	_, err := tx.Exec(`
+		UPDATE chapters
+		SET crawled_at = now(), error = NULL,
+		    page_count = $2,
+		    rich_content = $3, rich_content_key = $4, content_format = $5
+		WHERE id = $1
+	`, old.ID, pageCount, richContent, richContentKey, contentFormat(c))
+	if err != nil {
+		return fmt.Errorf("failed to update chapter rich content: %w", err)
 	}
+	return pruneContentBlobs(tx, old.RichContentKey)
+}
+
+// optionalContentHash returns the hash of content, or an empty string for empty content
+func optionalContentHash(content string) string {
+	if content == "" {
+		return ""
+	}
+	return ContentHash(content)
+}
+
+// contentFormat returns the format stored for the rich content of c
+func contentFormat(c *Chapter) string {
+	if c.RichContent == "" || c.ContentFormat == "" {
+		return ContentFormatText
+	}
+	return c.ContentFormat
+}
 
-	_, err = utils.DB.Exec("UPDATE chapter SET title = $1, url = $2, content = $3, crawled_at = $4 WHERE novel_id = $5 AND chapter_number = $6", c.Title, c.URL, c.Content, c.CrawledAt, c.NovelID, c.ChapterNumber)
-	return err
+// ChapterSync counts the changes made to the chapters of a novel by a book crawl
+type ChapterSync struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"`
+	// Restored counts the removed chapters listed again, they are counted as updated too
+	Restored int `json:"restored"`
+	// AddedIDs are the IDs of the added chapters
+	AddedIDs []int `json:"-"`
 }
 
-// DeleteChapter deletes a chapter by ID
+// listedChapter is the stored listing of a chapter compared by syncNovelChapters
+type listedChapter struct {
+	ID            int
+	Title         string
+	URL           string
+	ChapterNumber int
+	Sequence      int
+	Volume        int
+	Part          int
+	OrderLocked   bool
+	Removed       bool
+}
+
+// syncNovelChapters reconciles the chapters of a novel with the chapter list of a book crawl
+// in tx, matched by external ID, or by URL for chapters without one. Listed chapters are created without content or have their
+// title, URL and ordering updated, their content is left as is and so is the ordering of
+// chapters renumbered by hand. Stored chapters missing from the list are marked removed,
+// not deleted, and are restored when listed again. An empty list removes nothing, as it
+// more likely means the crawl failed. The IDs of chapters are set.
+func syncNovelChapters(tx *sql.Tx, novelID int, chapters []Chapter) (ChapterSync, error) {
+	var sync ChapterSync
+
+	rows, err := tx.Query(`
+		SELECT id, COALESCE(external_id, ''), COALESCE(title, ''), url, COALESCE(chapter_number, 0),
+		       sequence, volume, part, ordering_locked, removed_at IS NOT NULL
+		FROM chapters
+		WHERE novel_id = $1
+		FOR UPDATE
+	`, novelID)
+	if err != nil {
+		return sync, fmt.Errorf("failed to query novel chapters: %w", err)
+	}
+	stored := map[string]listedChapter{}
+	for rows.Next() {
+		var externalID string
+		var l listedChapter
+		if err := rows.Scan(
+			&l.ID, &externalID, &l.Title, &l.URL, &l.ChapterNumber,
+			&l.Sequence, &l.Volume, &l.Part, &l.OrderLocked, &l.Removed,
+		); err != nil {
+			rows.Close()
+			return sync, fmt.Errorf("failed to scan novel chapter: %w", err)
+		}
+		stored[listingKey(externalID, l.URL)] = l
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return sync, fmt.Errorf("error iterating novel chapter rows: %w", err)
+	}
+
+	var added, updated []*Chapter
+	listedIDs, listedURLs := []string{}, []string{}
+	listed := make(map[string]bool, len(chapters))
+	for i := range chapters {
+		c := &chapters[i]
+		c.NovelID = novelID
+		key := listingKey(c.ExternalID, c.URL)
+		if listed[key] {
+			continue
+		}
+		listed[key] = true
+		if c.ExternalID != "" {
+			listedIDs = append(listedIDs, c.ExternalID)
+		} else {
+			listedURLs = append(listedURLs, c.URL)
+		}
+
+		old, ok := stored[key]
+		if !ok {
+			added = append(added, c)
+			continue
+		}
+
+		c.ID = old.ID
+		if old.OrderLocked {
+			c.ChapterNumber, c.Sequence, c.Volume, c.Part = old.ChapterNumber, old.Sequence, old.Volume, old.Part
+		}
+		if old.Title == c.Title && old.URL == c.URL && old.ChapterNumber == c.ChapterNumber &&
+			old.Sequence == c.Sequence && old.Volume == c.Volume && old.Part == c.Part && !old.Removed {
+			sync.Unchanged++
+			continue
+		}
+		updated = append(updated, c)
+		if old.Removed {
+			sync.Restored++
+		}
+	}
+
+	if err := insertListedChapters(tx, novelID, added); err != nil {
+		return sync, err
+	}
+	sync.Added = len(added)
+	for _, c := range added {
+		sync.AddedIDs = append(sync.AddedIDs, c.ID)
+	}
+	if err := updateListedChapters(tx, updated); err != nil {
+		return sync, err
+	}
+	sync.Updated = len(updated)
+
+	if len(listed) > 0 {
+		result, err := tx.Exec(`
+			UPDATE chapters
+			SET removed_at = now()
+			WHERE novel_id = $1 AND removed_at IS NULL
+			  AND CASE WHEN COALESCE(external_id, '') <> '' THEN NOT (external_id = ANY($2))
+			           ELSE NOT (url = ANY($3)) END
+		`, novelID, pq.Array(listedIDs), pq.Array(listedURLs))
+		if err != nil {
+			return sync, fmt.Errorf("failed to mark removed chapters: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		sync.Removed = int(n)
+	}
+
+	return sync, nil
+}
+
+// listingKey identifies a chapter of a novel in its chapter list, by external ID or by URL
+// when the source gives none
+func listingKey(externalID, url string) string {
+	if externalID != "" {
+		return "id:" + externalID
+	}
+	return "url:" + url
+}
+
+// insertListedChapters inserts chapters listed by a book crawl, without content, in one
+// statement. Their IDs are taken from the sequence first so they can be set. A chapter
+// inserted meanwhile with the same external ID is updated instead and keeps its ID.
+func insertListedChapters(tx *sql.Tx, novelID int, chapters []*Chapter) error {
+	if len(chapters) == 0 {
+		return nil
+	}
+
+	var ids pq.Int64Array
+	err := tx.QueryRow(`
+		SELECT array_agg(nextval(pg_get_serial_sequence('chapters', 'id')))
+		FROM generate_series(1, $1)
+	`, len(chapters)).Scan(&ids)
+	if err != nil {
+		return fmt.Errorf("failed to allocate chapter IDs: %w", err)
+	}
+
+	n := len(chapters)
+	externalIDs, titles, urls := make([]string, n), make([]string, n), make([]string, n)
+	numbers, sequences, volumes, parts := make([]int64, n), make([]int64, n), make([]int64, n), make([]int64, n)
+	for i, c := range chapters {
+		c.ID = int(ids[i])
+		externalIDs[i], titles[i], urls[i] = c.ExternalID, c.Title, c.URL
+		numbers[i], sequences[i], volumes[i], parts[i] = int64(c.ChapterNumber), int64(c.Sequence), int64(c.Volume), int64(c.Part)
+	}
+
+	rows, err := tx.Query(`
+		INSERT INTO chapters (id, novel_id, external_id, title, chapter_number, url,
+		                      content, content_format, content_length, sequence, volume, part)
+		SELECT u.id, $1, NULLIF(u.external_id, ''), u.title, u.chapter_number, u.url,
+		       '', $10, 0, u.sequence, u.volume, u.part
+		FROM unnest($2::int[], $3::text[], $4::text[], $5::int[], $6::text[], $7::int[], $8::int[], $9::int[])
+		     AS u(id, external_id, title, chapter_number, url, sequence, volume, part)
+		ON CONFLICT (novel_id, external_id) DO UPDATE
+		SET title = EXCLUDED.title, url = EXCLUDED.url, chapter_number = EXCLUDED.chapter_number,
+		    sequence = EXCLUDED.sequence, volume = EXCLUDED.volume, part = EXCLUDED.part, removed_at = NULL
+		RETURNING id, COALESCE(external_id, '')
+	`, novelID, ids, pq.Array(externalIDs), pq.Array(titles), pq.Array(numbers), pq.Array(urls),
+		pq.Array(sequences), pq.Array(volumes), pq.Array(parts), ContentFormatText)
+	if err != nil {
+		return fmt.Errorf("failed to create chapters: %w", err)
+	}
+	defer rows.Close()
+
+	stored := map[string]int{}
+	for rows.Next() {
+		var id int
+		var externalID string
+		if err := rows.Scan(&id, &externalID); err != nil {
+			return fmt.Errorf("failed to scan created chapter: %w", err)
+		}
+		if externalID != "" {
+			stored[externalID] = id
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to create chapters: %w", err)
+	}
+
+	for _, c := range chapters {
+		if id, ok := stored[c.ExternalID]; ok {
+			c.ID = id
+		}
+	}
+	return nil
+}
+
+// updateListedChapters updates the title, URL and ordering of chapters listed by a book crawl
+// in one statement and clears their removed mark
+func updateListedChapters(tx *sql.Tx, chapters []*Chapter) error {
+	if len(chapters) == 0 {
+		return nil
+	}
+
+	n := len(chapters)
+	ids, numbers, sequences, volumes, parts := make([]int64, n), make([]int64, n), make([]int64, n), make([]int64, n), make([]int64, n)
+	titles, urls := make([]string, n), make([]string, n)
+	for i, c := range chapters {
+		ids[i], titles[i], urls[i] = int64(c.ID), c.Title, c.URL
+		numbers[i], sequences[i], volumes[i], parts[i] = int64(c.ChapterNumber), int64(c.Sequence), int64(c.Volume), int64(c.Part)
+	}
+
+	_, err := tx.Exec(`
+		UPDATE chapters c
+		SET title = u.title, url = u.url, chapter_number = u.chapter_number,
+		    sequence = u.sequence, volume = u.volume, part = u.part, removed_at = NULL
+		FROM unnest($1::int[], $2::text[], $3::text[], $4::int[], $5::int[], $6::int[], $7::int[])
+		     AS u(id, title, url, chapter_number, sequence, volume, part)
+		WHERE c.id = u.id
+	`, pq.Array(ids), pq.Array(titles), pq.Array(urls), pq.Array(numbers),
+		pq.Array(sequences), pq.Array(volumes), pq.Array(parts))
+	if err != nil {
+		return fmt.Errorf("failed to update chapters: %w", err)
+	}
+	return nil
+}
+
+// ErrChapterNotInNovel is returned when a renumbered chapter is not a chapter of the novel
+var ErrChapterNotInNovel = errors.New("chapter not in novel")
+
+// ChapterOrder is a manual change to the ordering of a chapter, nil fields are kept
+type ChapterOrder struct {
+	ID            int  `json:"id"`
+	Sequence      *int `json:"sequence,omitempty"`
+	ChapterNumber *int `json:"chapter_number,omitempty"`
+	Volume        *int `json:"volume,omitempty"`
+	Part          *int `json:"part,omitempty"`
+	// Locked keeps the ordering from being overwritten by crawls, true when not given
+	Locked *bool `json:"locked,omitempty"`
+}
+
+// RenumberChapters applies manual ordering changes to the chapters of a novel. With compact,
+// the sequences of every chapter of the novel are then rewritten to 1..n in their order.
+// It returns the number of chapters changed.
+func RenumberChapters(novelID int, orders []ChapterOrder, compact bool) (int, error) {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	changed := 0
+	for _, o := range orders {
+		locked := true
+		if o.Locked != nil {
+			locked = *o.Locked
+		}
+
+		result, err := tx.Exec(`
+			UPDATE chapters
+			SET sequence = COALESCE($3, sequence), chapter_number = COALESCE($4, chapter_number),
+			    volume = COALESCE($5, volume), part = COALESCE($6, part), ordering_locked = $7
+			WHERE id = $1 AND novel_id = $2
+		`, o.ID, novelID, o.Sequence, o.ChapterNumber, o.Volume, o.Part, locked)
+		if err != nil {
+			return 0, fmt.Errorf("failed to renumber chapter: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return 0, fmt.Errorf("%w: chapter %d, novel %d", ErrChapterNotInNovel, o.ID, novelID)
+		}
+		changed++
+	}
+
+	if compact {
+		result, err := tx.Exec(`
+			UPDATE chapters c
+			SET sequence = o.sequence
+			FROM (
+				SELECT id, ROW_NUMBER() OVER (ORDER BY sequence, COALESCE(chapter_number, 0), id) AS sequence
+				FROM chapters
+				WHERE novel_id = $1
+			) o
+			WHERE o.id = c.id AND c.sequence <> o.sequence
+		`, novelID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to compact chapter sequences: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		changed += int(n)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit chapter ordering: %w", err)
+	}
+	return changed, nil
+}
+
+// DeleteChapter deletes a chapter by ID with its content stored out of row
 func DeleteChapter(id int) error {
-	_, err := utils.DB.Exec("DELETE FROM chapters WHERE id = $1", id)
+	tx, err := utils.DB.Begin()
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var key, richKey string
+	err = tx.QueryRow(`
+		DELETE FROM chapters WHERE id = $1
+		RETURNING COALESCE(content_key, ''), COALESCE(rich_content_key, '')
+	`, id).Scan(&key, &richKey)
+	if err != nil && err != sql.ErrNoRows {
 		return fmt.Errorf("failed to delete chapter: %w", err)
 	}
-	return nil
+
+	if err := pruneContentBlobs(tx, key, richKey); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
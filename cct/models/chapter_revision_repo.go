@@ -0,0 +1,194 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"cct/utils"
+)
+
+// ContentHash returns the hash of normalized chapter content. Content is normalized to NFC
+// with whitespace collapsed and blank lines dropped, so re-encoded or re-wrapped text of an
+// unchanged chapter keeps the same hash.
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(NormalizeContent(content)))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeContent returns the normalized form of chapter content hashed by ContentHash
+func NormalizeContent(content string) string {
+	content = norm.NFC.String(content)
+
+	var b strings.Builder
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(strings.Join(fields, " "))
+	}
+	return b.String()
+}
+
+// chapterContentRow is the stored content of a chapter, locked for update
+type chapterContentRow struct {
+	ID            int
+	NovelID       int
+	WebsiteID     int
+	URL           string
+	Content       string
+	RichContent   string
+	ContentFormat string
+	ContentHash   string
+	PageCount     int
+	CrawledAt     sql.NullTime
+	// ContentKey and RichContentKey are the keys of content stored out of row
+	ContentKey     string
+	RichContentKey string
+}
+
+// lockChapterContent selects the stored content of the chapters matching where and locks
+// them until tx ends. The hash of content stored before hashes were kept is computed.
+func lockChapterContent(tx *sql.Tx, where string, args ...any) ([]chapterContentRow, error) {
+	rows, err := tx.Query(`
+		SELECT c.id, COALESCE(c.novel_id, 0), COALESCE(n.website_id, 0), c.url, COALESCE(c.content, ''),
+		       COALESCE(c.rich_content, ''), c.content_format, COALESCE(c.content_hash, ''), c.page_count, c.crawled_at,
+		       COALESCE(c.content_key, ''), COALESCE(c.rich_content_key, ''),
+		       (SELECT data FROM chapter_contents WHERE hash = c.content_key),
+		       (SELECT data FROM chapter_contents WHERE hash = c.rich_content_key)
+		FROM chapters c
+		LEFT JOIN novels n ON n.id = c.novel_id
+		WHERE `+where+`
+		FOR UPDATE OF c
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chapter content: %w", err)
+	}
+	defer rows.Close()
+
+	var result []chapterContentRow
+	for rows.Next() {
+		var r chapterContentRow
+		var content, richContent []byte
+		if err := rows.Scan(
+			&r.ID, &r.NovelID, &r.WebsiteID, &r.URL, &r.Content,
+			&r.RichContent, &r.ContentFormat, &r.ContentHash, &r.PageCount, &r.CrawledAt,
+			&r.ContentKey, &r.RichContentKey, &content, &richContent,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan chapter content: %w", err)
+		}
+		if content != nil {
+			if r.Content, err = decodeContent(content); err != nil {
+				return nil, err
+			}
+		}
+		if richContent != nil {
+			if r.RichContent, err = decodeContent(richContent); err != nil {
+				return nil, err
+			}
+		}
+		if r.ContentHash == "" && r.Content != "" {
+			r.ContentHash = ContentHash(r.Content)
+		}
+		result = append(result, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating chapter content rows: %w", err)
+	}
+	return result, nil
+}
+
+// archiveChapterContent saves the stored content of a chapter as a revision, then drops
+// the oldest revisions beyond keep (0 keeps all). Chapters without content are skipped.
+func archiveChapterContent(tx *sql.Tx, old chapterContentRow, keep int) error {
+	if old.Content == "" {
+		return nil
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO chapter_revisions (chapter_id, content, rich_content, content_format,
+		                               content_hash, content_length, crawled_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7)
+	`, old.ID, old.Content, old.RichContent, old.ContentFormat, old.ContentHash,
+		utf8.RuneCountInString(old.Content), old.CrawledAt)
+	if err != nil {
+		return fmt.Errorf("failed to create chapter revision: %w", err)
+	}
+
+	if keep > 0 {
+		_, err = tx.Exec(`
+			DELETE FROM chapter_revisions
+			WHERE chapter_id = $1 AND id NOT IN (
+				SELECT id FROM chapter_revisions WHERE chapter_id = $1 ORDER BY id DESC LIMIT $2
+			)
+		`, old.ID, keep)
+		if err != nil {
+			return fmt.Errorf("failed to prune chapter revisions: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetChapterRevisions retrieves the revisions of a chapter, newest first, without their content
+func GetChapterRevisions(chapterID int) ([]ChapterRevision, error) {
+	rows, err := utils.DB.Query(`
+		SELECT id, chapter_id, content_format, content_hash, content_length, crawled_at, created_at
+		FROM chapter_revisions
+		WHERE chapter_id = $1
+		ORDER BY id DESC
+	`, chapterID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chapter revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []ChapterRevision{}
+	for rows.Next() {
+		var r ChapterRevision
+		if err := rows.Scan(
+			&r.ID, &r.ChapterID, &r.ContentFormat, &r.ContentHash, &r.ContentLength, &r.CrawledAt, &r.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan chapter revision row: %w", err)
+		}
+		revisions = append(revisions, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating chapter revision rows: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// GetChapterRevision retrieves a revision of a chapter with its content
+func GetChapterRevision(chapterID, revisionID int) (ChapterRevision, error) {
+	var r ChapterRevision
+	err := utils.DB.QueryRow(`
+		SELECT id, chapter_id, content, COALESCE(rich_content, ''), content_format,
+		       content_hash, content_length, crawled_at, created_at
+		FROM chapter_revisions
+		WHERE chapter_id = $1 AND id = $2
+	`, chapterID, revisionID).Scan(
+		&r.ID, &r.ChapterID, &r.Content, &r.RichContent, &r.ContentFormat,
+		&r.ContentHash, &r.ContentLength, &r.CrawledAt, &r.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ChapterRevision{}, fmt.Errorf("revision %d of chapter %d not found", revisionID, chapterID)
+		}
+		return ChapterRevision{}, fmt.Errorf("failed to query chapter revision: %w", err)
+	}
+
+	return r, nil
+}
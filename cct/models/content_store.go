@@ -0,0 +1,352 @@
+package models
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"cct/utils"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/lib/pq"
+)
+
+// Chapter content storage modes
+const (
+	// ContentStorageInline stores chapter content in the chapters table
+	ContentStorageInline = "inline"
+	// ContentStorageCompressed stores chapter content zstd compressed in chapter_contents,
+	// keyed by the hash of the content so identical content is stored once
+	ContentStorageCompressed = "compressed"
+)
+
+// contentStorage is where new chapter content is written, content is read from either
+var contentStorage = ContentStorageInline
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// SetContentStorage sets where new chapter content is written
+func SetContentStorage(mode string) error {
+	switch mode {
+	case ContentStorageInline, ContentStorageCompressed:
+		contentStorage = mode
+		return nil
+	}
+	return fmt.Errorf("unknown chapter content storage %q, expected %s or %s", mode, ContentStorageInline, ContentStorageCompressed)
+}
+
+// execQuerier runs queries on the database or in a transaction
+type execQuerier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// storedContent is how chapter content and rich content are written to the chapters table,
+// either inline or as keys of chapter_contents
+type storedContent struct {
+	Content        sql.NullString
+	RichContent    sql.NullString
+	ContentKey     sql.NullString
+	RichContentKey sql.NullString
+	// SearchText is the content indexed by search_vector when the database can't read it
+	SearchText sql.NullString
+}
+
+// storeContent prepares content and rich content for writing in the current storage mode,
+// storing the compressed blobs first
+func storeContent(q execQuerier, content, richContent string) (storedContent, error) {
+	var s storedContent
+	if contentStorage != ContentStorageCompressed {
+		s.Content = sql.NullString{String: content, Valid: true}
+		s.RichContent = sql.NullString{String: richContent, Valid: richContent != ""}
+		return s, nil
+	}
+
+	if content != "" {
+		key, err := putContentBlob(q, content)
+		if err != nil {
+			return s, err
+		}
+		s.ContentKey = sql.NullString{String: key, Valid: true}
+		s.SearchText = sql.NullString{String: content, Valid: true}
+	} else {
+		s.Content = sql.NullString{Valid: true}
+	}
+	if richContent != "" {
+		key, err := putContentBlob(q, richContent)
+		if err != nil {
+			return s, err
+		}
+		s.RichContentKey = sql.NullString{String: key, Valid: true}
+	}
+	return s, nil
+}
+
+// searchVectorSQL returns the expression of the search vector of a chapter whose content
+// is stored out of row, from the title and search text expressions. It is NULL when the
+// content is inline, so the search vector trigger indexes it.
+func searchVectorSQL(title, text string) string {
+	return fmt.Sprintf(`CASE WHEN %[2]s::text IS NULL THEN NULL ELSE
+		setweight(to_tsvector('%[3]s', COALESCE(%[1]s, '')), 'A') ||
+		setweight(to_tsvector('%[3]s', %[2]s::text), 'B') END`, title, text, searchConfig)
+}
+
+// contentKey returns the key of content in chapter_contents
+func contentKey(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// putContentBlob stores content compressed and returns its key. The blob row is locked
+// until the transaction ends so it can't be pruned before it is referenced.
+func putContentBlob(q execQuerier, content string) (string, error) {
+	key := contentKey(content)
+	data := zstdEncoder.EncodeAll([]byte(content), nil)
+
+	err := q.QueryRow(`
+		INSERT INTO chapter_contents (hash, size, data)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (hash) DO UPDATE SET hash = EXCLUDED.hash
+		RETURNING hash
+	`, key, len(content), data).Scan(&key)
+	if err != nil {
+		return "", fmt.Errorf("failed to store chapter content: %w", err)
+	}
+	return key, nil
+}
+
+// decodeContent decompresses a blob selected from chapter_contents, nil blobs are empty
+func decodeContent(data []byte) (string, error) {
+	if data == nil {
+		return "", nil
+	}
+	content, err := zstdDecoder.DecodeAll(data, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress chapter content: %w", err)
+	}
+	return string(content), nil
+}
+
+// decodeContentPrefix decompresses up to the first n bytes of a blob, without holding the
+// rest of the content in memory. The prefix may end inside a UTF-8 sequence.
+func decodeContentPrefix(data []byte, n int) (string, error) {
+	if data == nil {
+		return "", nil
+	}
+	dec, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress chapter content: %w", err)
+	}
+	defer dec.Close()
+
+	content, err := io.ReadAll(io.LimitReader(dec, int64(n)))
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress chapter content: %w", err)
+	}
+	return string(content), nil
+}
+
+// truncateText cuts text to at most n bytes without splitting a UTF-8 sequence
+func truncateText(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n]
+}
+
+// pruneContentBlobs deletes the blobs of keys that no chapter references anymore
+func pruneContentBlobs(q execQuerier, keys ...string) error {
+	var unused []string
+	for _, key := range keys {
+		if key != "" {
+			unused = append(unused, key)
+		}
+	}
+	if len(unused) == 0 {
+		return nil
+	}
+
+	_, err := q.Exec(`
+		DELETE FROM chapter_contents cc
+		WHERE cc.hash = ANY($1)
+		  AND NOT EXISTS (SELECT 1 FROM chapters c WHERE c.content_key = cc.hash)
+		  AND NOT EXISTS (SELECT 1 FROM chapters c WHERE c.rich_content_key = cc.hash)
+	`, pq.Array(unused))
+	if err != nil {
+		return fmt.Errorf("failed to prune chapter content: %w", err)
+	}
+	return nil
+}
+
+// MigrateChapterContent moves the content of up to batch chapters to the storage mode to,
+// returning the number of chapters moved. Call it until it returns 0. Chapters being
+// written are skipped and picked up by a later batch.
+func MigrateChapterContent(to string, batch int) (int, error) {
+	switch to {
+	case ContentStorageCompressed:
+		return compressChapterContent(batch)
+	case ContentStorageInline:
+		return inlineChapterContent(batch)
+	}
+	return 0, fmt.Errorf("unknown chapter content storage %q, expected %s or %s", to, ContentStorageInline, ContentStorageCompressed)
+}
+
+// compressChapterContent moves inline content of up to batch chapters to chapter_contents.
+// The search vector is left as is, the trigger keeps its content lexemes.
+func compressChapterContent(batch int) (int, error) {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, COALESCE(content, ''), COALESCE(rich_content, '')
+		FROM chapters
+		WHERE content <> '' OR rich_content IS NOT NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, batch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query inline chapter content: %w", err)
+	}
+
+	type inlineRow struct {
+		ID                   int
+		Content, RichContent string
+	}
+	var pending []inlineRow
+	for rows.Next() {
+		var r inlineRow
+		if err := rows.Scan(&r.ID, &r.Content, &r.RichContent); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan chapter content: %w", err)
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating chapter content rows: %w", err)
+	}
+
+	for _, r := range pending {
+		var key, richKey sql.NullString
+		if r.Content != "" {
+			if key.String, err = putContentBlob(tx, r.Content); err != nil {
+				return 0, err
+			}
+			key.Valid = true
+		}
+		if r.RichContent != "" {
+			if richKey.String, err = putContentBlob(tx, r.RichContent); err != nil {
+				return 0, err
+			}
+			richKey.Valid = true
+		}
+
+		_, err = tx.Exec(`
+			UPDATE chapters
+			SET content = CASE WHEN $2::text IS NULL THEN content END, rich_content = NULL,
+			    content_key = COALESCE($2, content_key), rich_content_key = COALESCE($3, rich_content_key)
+			WHERE id = $1
+		`, r.ID, key, richKey)
+		if err != nil {
+			return 0, fmt.Errorf("failed to compress chapter content: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit chapter content: %w", err)
+	}
+	return len(pending), nil
+}
+
+// inlineChapterContent moves compressed content of up to batch chapters back to the
+// chapters table and deletes the blobs no chapter references anymore
+func inlineChapterContent(batch int) (int, error) {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, COALESCE(content_key, ''), COALESCE(rich_content_key, ''),
+		       (SELECT data FROM chapter_contents WHERE hash = content_key),
+		       (SELECT data FROM chapter_contents WHERE hash = rich_content_key)
+		FROM chapters
+		WHERE content_key IS NOT NULL OR rich_content_key IS NOT NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, batch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query compressed chapter content: %w", err)
+	}
+
+	type compressedRow struct {
+		ID                   int
+		Key, RichKey         string
+		Content, RichContent []byte
+	}
+	var pending []compressedRow
+	for rows.Next() {
+		var r compressedRow
+		if err := rows.Scan(&r.ID, &r.Key, &r.RichKey, &r.Content, &r.RichContent); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan chapter content: %w", err)
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating chapter content rows: %w", err)
+	}
+
+	var keys []string
+	for _, r := range pending {
+		var content, richContent sql.NullString
+		if r.Key != "" {
+			if content.String, err = decodeContent(r.Content); err != nil {
+				return 0, err
+			}
+			content.Valid = true
+		}
+		if r.RichKey != "" {
+			if richContent.String, err = decodeContent(r.RichContent); err != nil {
+				return 0, err
+			}
+			richContent.Valid = true
+		}
+
+		_, err = tx.Exec(`
+			UPDATE chapters
+			SET content = COALESCE($2, content), rich_content = COALESCE($3, rich_content),
+			    content_key = NULL, rich_content_key = NULL
+			WHERE id = $1
+		`, r.ID, content, richContent)
+		if err != nil {
+			return 0, fmt.Errorf("failed to inline chapter content: %w", err)
+		}
+		keys = append(keys, r.Key, r.RichKey)
+	}
+
+	if err := pruneContentBlobs(tx, keys...); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit chapter content: %w", err)
+	}
+	return len(pending), nil
+}
@@ -0,0 +1,287 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"cct/utils"
+)
+
+func TestContentBlobRoundTrip(t *testing.T) {
+	for _, content := range []string{"", "Chương 1", strings.Repeat("Đây là đoạn một.\n", 5000)} {
+		data := zstdEncoder.EncodeAll([]byte(content), nil)
+		got, err := decodeContent(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != content {
+			t.Errorf("round trip of %d bytes returned %d bytes", len(content), len(got))
+		}
+
+		prefix, err := decodeContentPrefix(data, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := content[:min(len(content), 100)]; prefix != want {
+			t.Errorf("prefix %q, want %q", prefix, want)
+		}
+	}
+
+	if got, err := decodeContent(nil); err != nil || got != "" {
+		t.Errorf("decodeContent(nil) = %q, %v", got, err)
+	}
+	if _, err := decodeContent([]byte("not zstd")); err == nil {
+		t.Error("expected an error for data that isn't zstd")
+	}
+	if _, err := decodeContentPrefix([]byte("not zstd"), 10); err == nil {
+		t.Error("expected an error for a prefix of data that isn't zstd")
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		want string
+	}{
+		{"abc", 5, "abc"},
+		{"abc", 2, "ab"},
+		{"chương", 3, "ch"},
+		{"chương", 4, "chư"},
+		{"chương", 5, "chư"},
+		{"chương", 0, ""},
+	}
+	for _, tt := range tests {
+		got := truncateText(tt.text, tt.n)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestContentKey(t *testing.T) {
+	if contentKey("a") != contentKey("a") || contentKey("a") == contentKey("b") {
+		t.Error("content keys must be equal for equal content only")
+	}
+	if len(contentKey("a")) != 64 {
+		t.Errorf("content key %q is not a hex SHA-256", contentKey("a"))
+	}
+}
+
+func TestMigrateChapterContent(t *testing.T) {
+	db := newFakeContentDB(map[int]*fakeChapter{
+		1: {content: ptr("one"), richContent: ptr("<p>one</p>")},
+		2: {content: ptr("two")},
+		3: {content: ptr("one")}, // same content as chapter 1, stored once
+		4: {content: ptr("")},
+	})
+	old := utils.DB
+	utils.DB = openFakeDB(t, db)
+	t.Cleanup(func() { utils.DB = old })
+
+	// Inline to compressed, two batches
+	moved := 0
+	for {
+		n, err := MigrateChapterContent(ContentStorageCompressed, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+		moved += n
+	}
+	if moved != 3 {
+		t.Errorf("compressed %d chapters, want 3", moved)
+	}
+	if len(db.blobs) != 3 {
+		t.Errorf("%d blobs, want 3", len(db.blobs))
+	}
+	for id, want := range map[int][2]string{1: {"one", "<p>one</p>"}, 2: {"two", ""}, 3: {"one", ""}} {
+		c := db.chapters[id]
+		if c.content != nil || c.richContent != nil {
+			t.Errorf("chapter %d still has inline content", id)
+		}
+		if got := db.blobText(t, c.contentKey); got != want[0] {
+			t.Errorf("chapter %d content %q, want %q", id, got, want[0])
+		}
+		if got := db.blobText(t, c.richContentKey); got != want[1] {
+			t.Errorf("chapter %d rich content %q, want %q", id, got, want[1])
+		}
+	}
+	if c := db.chapters[4]; c.contentKey != nil || c.content == nil {
+		t.Error("chapter 4 without content was compressed")
+	}
+
+	// Compressed back to inline
+	n, err := MigrateChapterContent(ContentStorageInline, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("inlined %d chapters, want 3", n)
+	}
+	for id, want := range map[int][2]string{1: {"one", "<p>one</p>"}, 2: {"two", ""}, 3: {"one", ""}} {
+		c := db.chapters[id]
+		if c.contentKey != nil || c.richContentKey != nil {
+			t.Errorf("chapter %d still has content keys", id)
+		}
+		if deref(c.content) != want[0] || deref(c.richContent) != want[1] {
+			t.Errorf("chapter %d content %q %q, want %q %q", id, deref(c.content), deref(c.richContent), want[0], want[1])
+		}
+	}
+	if len(db.blobs) != 0 {
+		t.Errorf("%d blobs left after inlining every chapter", len(db.blobs))
+	}
+
+	if n, err := MigrateChapterContent(ContentStorageInline, 10); err != nil || n != 0 {
+		t.Errorf("second inline migration moved %d chapters, %v", n, err)
+	}
+	if _, err := MigrateChapterContent("zip", 10); err == nil {
+		t.Error("expected an error for an unknown storage")
+	}
+}
+
+func ptr(s string) *string { return &s }
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// fakeChapter is the content columns of a chapters row, nil for NULL
+type fakeChapter struct {
+	content, richContent, contentKey, richContentKey *string
+}
+
+// fakeContentDB is an in-memory database answering the queries of MigrateChapterContent
+type fakeContentDB struct {
+	chapters map[int]*fakeChapter
+	blobs    map[string][]byte
+}
+
+func newFakeContentDB(chapters map[int]*fakeChapter) *fakeContentDB {
+	return &fakeContentDB{chapters: chapters, blobs: map[string][]byte{}}
+}
+
+func (db *fakeContentDB) blobText(t *testing.T, key *string) string {
+	t.Helper()
+	if key == nil {
+		return ""
+	}
+	data, ok := db.blobs[*key]
+	if !ok {
+		t.Fatalf("no blob %s", *key)
+	}
+	text, err := decodeContent(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return text
+}
+
+// ids returns the IDs of the chapters matching keep, in order
+func (db *fakeContentDB) ids(keep func(c *fakeChapter) bool, limit int64) []int {
+	var ids []int
+	for id, c := range db.chapters {
+		if keep(c) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	if int64(len(ids)) > limit {
+		ids = ids[:limit]
+	}
+	return ids
+}
+
+func (db *fakeContentDB) referenced(key string) bool {
+	for _, c := range db.chapters {
+		if deref(c.contentKey) == key || deref(c.richContentKey) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (db *fakeContentDB) query(query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case strings.Contains(query, "SELECT id, COALESCE(content, ''), COALESCE(rich_content, '')"):
+		rows := &fakeRows{columns: []string{"id", "content", "rich_content"}}
+		for _, id := range db.ids(func(c *fakeChapter) bool {
+			return deref(c.content) != "" || c.richContent != nil
+		}, args[0].Value.(int64)) {
+			c := db.chapters[id]
+			rows.values = append(rows.values, []driver.Value{int64(id), deref(c.content), deref(c.richContent)})
+		}
+		return rows, nil
+
+	case strings.Contains(query, "SELECT id, COALESCE(content_key, ''), COALESCE(rich_content_key, '')"):
+		rows := &fakeRows{columns: []string{"id", "content_key", "rich_content_key", "data", "rich_data"}}
+		for _, id := range db.ids(func(c *fakeChapter) bool {
+			return c.contentKey != nil || c.richContentKey != nil
+		}, args[0].Value.(int64)) {
+			c := db.chapters[id]
+			rows.values = append(rows.values, []driver.Value{
+				int64(id), deref(c.contentKey), deref(c.richContentKey),
+				db.blobs[deref(c.contentKey)], db.blobs[deref(c.richContentKey)],
+			})
+		}
+		return rows, nil
+
+	case strings.Contains(query, "INSERT INTO chapter_contents"):
+		key := args[0].Value.(string)
+		if _, ok := db.blobs[key]; !ok {
+			db.blobs[key] = args[2].Value.([]byte)
+		}
+		return &fakeRows{columns: []string{"hash"}, values: [][]driver.Value{{key}}}, nil
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+func (db *fakeContentDB) exec(query string, args []driver.NamedValue) (driver.Result, error) {
+	switch {
+	case strings.Contains(query, "SET content = CASE WHEN $2::text IS NULL THEN content END"):
+		c := db.chapters[int(args[0].Value.(int64))]
+		key, richKey := nullString(args[1].Value), nullString(args[2].Value)
+		if key != nil {
+			c.content = nil
+		}
+		c.richContent = nil
+		if key != nil {
+			c.contentKey = key
+		}
+		if richKey != nil {
+			c.richContentKey = richKey
+		}
+		return driver.RowsAffected(1), nil
+
+	case strings.Contains(query, "SET content = COALESCE($2, content)"):
+		c := db.chapters[int(args[0].Value.(int64))]
+		if content := nullString(args[1].Value); content != nil {
+			c.content = content
+		}
+		if richContent := nullString(args[2].Value); richContent != nil {
+			c.richContent = richContent
+		}
+		c.contentKey, c.richContentKey = nil, nil
+		return driver.RowsAffected(1), nil
+
+	case strings.Contains(query, "DELETE FROM chapter_contents"):
+		keys := strings.Split(strings.Trim(*nullString(args[0].Value), "{}"), ",")
+		for _, key := range keys {
+			key = strings.Trim(key, `"`)
+			if !db.referenced(key) {
+				delete(db.blobs, key)
+			}
+		}
+		return driver.RowsAffected(1), nil
+	}
+	return nil, errors.New("unexpected statement: " + query)
+}
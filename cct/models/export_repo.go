@@ -0,0 +1,207 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"cct/utils"
+)
+
+// ChapterRange selects the chapters of a novel by their position in the source list
+// (sequence), zero bounds are open. Chapters the source stopped listing are left out
+// unless WithRemoved is set.
+type ChapterRange struct {
+	FromChapter int  `json:"from_chapter,omitempty"`
+	ToChapter   int  `json:"to_chapter,omitempty"`
+	WithRemoved bool `json:"with_removed,omitempty"`
+}
+
+// where returns the conditions selecting the chapters of novelID in the range
+func (r ChapterRange) where(novelID int) (string, []interface{}) {
+	params := []interface{}{novelID}
+	conditions := []string{"novel_id = $1"}
+
+	conditions, params = sequenceRange("sequence", r.FromChapter, r.ToChapter, conditions, params)
+	if !r.WithRemoved {
+		conditions = append(conditions, "removed_at IS NULL")
+	}
+
+	return strings.Join(conditions, " AND "), params
+}
+
+// sequenceRange appends the conditions selecting the chapters from..to in source order to
+// conditions and params, zero bounds are open. column names the sequence column in the query.
+// Exports, searches and chapter lists select ranges with it, so the same range always
+// selects the same chapters.
+func sequenceRange(column string, from, to int, conditions []string, params []interface{}) ([]string, []interface{}) {
+	if from > 0 {
+		params = append(params, from)
+		conditions = append(conditions, fmt.Sprintf("%s >= $%d", column, len(params)))
+	}
+	if to > 0 {
+		params = append(params, to)
+		conditions = append(conditions, fmt.Sprintf("%s <= $%d", column, len(params)))
+	}
+	return conditions, params
+}
+
+// CountNovelChapters counts the chapters of a novel in a range
+func CountNovelChapters(novelID int, r ChapterRange) (int, error) {
+	where, params := r.where(novelID)
+
+	var count int
+	if err := utils.DB.QueryRow("SELECT COUNT(*) FROM chapters WHERE "+where, params...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count chapters: %w", err)
+	}
+	return count, nil
+}
+
+// EachNovelChapter calls fn with every chapter of a novel in a range, in source order.
+// Rows are read one at a time so whole novels are never held in memory.
+// Iteration stops at the first error returned by fn.
+func EachNovelChapter(novelID int, r ChapterRange, fn func(Chapter) error) error {
+	where, params := r.where(novelID)
+
+	rows, err := utils.DB.Query(`
+		SELECT `+chapterColumns+`
+		FROM chapters
+		WHERE `+where+`
+		ORDER BY sequence, chapter_number, id
+	`, params...)
+	if err != nil {
+		return fmt.Errorf("failed to query chapters: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c, err := scanChapter(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan chapter row: %w", err)
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating chapter rows: %w", err)
+	}
+	return nil
+}
+
+// exportJobColumns is the column list scanned by scanExportJob
+const exportJobColumns = `id, novel_id, format, from_chapter, to_chapter, with_removed, status, COALESCE(file_name, ''),
+	file_size, chapter_count, missing_count, COALESCE(error, ''), created_at, completed_at`
+
+// scanExportJob scans a row selected with exportJobColumns
+func scanExportJob(row rowScanner) (ExportJob, error) {
+	var j ExportJob
+	err := row.Scan(
+		&j.ID, &j.NovelID, &j.Format, &j.FromChapter, &j.ToChapter, &j.WithRemoved, &j.Status, &j.FileName,
+		&j.FileSize, &j.ChapterCount, &j.MissingCount, &j.Error, &j.CreatedAt, &j.CompletedAt,
+	)
+	return j, err
+}
+
+// CreateExportJob creates a pending export job
+func CreateExportJob(j *ExportJob) error {
+	err := utils.DB.QueryRow(`
+		INSERT INTO export_jobs (novel_id, format, from_chapter, to_chapter, with_removed, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, j.NovelID, j.Format, j.FromChapter, j.ToChapter, j.WithRemoved, ExportJobStatusPending).Scan(&j.ID, &j.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create export job: %w", err)
+	}
+	j.Status = ExportJobStatusPending
+	return nil
+}
+
+// GetExportJob retrieves an export job by ID
+func GetExportJob(id int) (ExportJob, error) {
+	j, err := scanExportJob(utils.DB.QueryRow("SELECT "+exportJobColumns+" FROM export_jobs WHERE id = $1", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ExportJob{}, fmt.Errorf("export job with ID %d not found", id)
+		}
+		return ExportJob{}, fmt.Errorf("failed to query export job: %w", err)
+	}
+	return j, nil
+}
+
+// GetExportJobsByNovel retrieves the export jobs of a novel, newest first
+func GetExportJobsByNovel(novelID int) ([]ExportJob, error) {
+	rows, err := utils.DB.Query(`
+		SELECT `+exportJobColumns+`
+		FROM export_jobs
+		WHERE novel_id = $1
+		ORDER BY id DESC
+	`, novelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query export jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []ExportJob{}
+	for rows.Next() {
+		j, err := scanExportJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan export job row: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating export job rows: %w", err)
+	}
+	return jobs, nil
+}
+
+// StartExportJob marks an export job as running
+func StartExportJob(id int) error {
+	_, err := utils.DB.Exec("UPDATE export_jobs SET status = $1 WHERE id = $2", ExportJobStatusRunning, id)
+	if err != nil {
+		return fmt.Errorf("failed to start export job: %w", err)
+	}
+	return nil
+}
+
+// CompleteExportJob records the file built by an export job
+func CompleteExportJob(j *ExportJob) error {
+	err := utils.DB.QueryRow(`
+		UPDATE export_jobs
+		SET status = $1, file_name = $2, file_size = $3, chapter_count = $4, missing_count = $5,
+		    error = NULL, completed_at = NOW()
+		WHERE id = $6
+		RETURNING completed_at
+	`, ExportJobStatusCompleted, j.FileName, j.FileSize, j.ChapterCount, j.MissingCount, j.ID).Scan(&j.CompletedAt)
+	if err != nil {
+		return fmt.Errorf("failed to complete export job: %w", err)
+	}
+	j.Status = ExportJobStatusCompleted
+	return nil
+}
+
+// FailExportJob records the error that stopped an export job
+func FailExportJob(id int, errMsg string) error {
+	_, err := utils.DB.Exec(`
+		UPDATE export_jobs SET status = $1, error = $2, completed_at = NOW() WHERE id = $3
+	`, ExportJobStatusFailed, errMsg, id)
+	if err != nil {
+		return fmt.Errorf("failed to fail export job: %w", err)
+	}
+	return nil
+}
+
+// FailInterruptedExportJobs fails the jobs left pending or running by a previous process
+func FailInterruptedExportJobs() (int64, error) {
+	res, err := utils.DB.Exec(`
+		UPDATE export_jobs SET status = $1, error = 'interrupted by a restart', completed_at = NOW()
+		WHERE status IN ($2, $3)
+	`, ExportJobStatusFailed, ExportJobStatusPending, ExportJobStatusRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail interrupted export jobs: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChapterRangeWhere(t *testing.T) {
+	tests := []struct {
+		rng    ChapterRange
+		where  string
+		params []interface{}
+	}{
+		{ChapterRange{}, "novel_id = $1 AND removed_at IS NULL", []interface{}{7}},
+		{ChapterRange{WithRemoved: true}, "novel_id = $1", []interface{}{7}},
+		{ChapterRange{FromChapter: 10}, "novel_id = $1 AND sequence >= $2 AND removed_at IS NULL", []interface{}{7, 10}},
+		{
+			ChapterRange{FromChapter: 10, ToChapter: 20},
+			"novel_id = $1 AND sequence >= $2 AND sequence <= $3 AND removed_at IS NULL",
+			[]interface{}{7, 10, 20},
+		},
+		{ChapterRange{ToChapter: 20, WithRemoved: true}, "novel_id = $1 AND sequence <= $2", []interface{}{7, 20}},
+	}
+
+	for _, tt := range tests {
+		where, params := tt.rng.where(7)
+		if where != tt.where || !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("%+v.where(7) = %q %v, want %q %v", tt.rng, where, params, tt.where, tt.params)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+)
+
+// fakeBackend answers the queries of the code under test, matched by their text
+type fakeBackend interface {
+	query(query string, args []driver.NamedValue) (driver.Rows, error)
+	exec(query string, args []driver.NamedValue) (driver.Result, error)
+}
+
+var fakeDBs = map[string]fakeBackend{}
+
+func init() {
+	sql.Register("fake", fakeDriver{})
+}
+
+// openFakeDB opens a database answered by backend, closed when the test ends
+func openFakeDB(t *testing.T, backend fakeBackend) *sql.DB {
+	fakeDBs[t.Name()] = backend
+	conn, err := sql.Open("fake", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close(); delete(fakeDBs, t.Name()) })
+	return conn
+}
+
+// nullString returns a driver value as a nullable string
+func nullString(v driver.Value) *string {
+	switch v := v.(type) {
+	case string:
+		return &v
+	case []byte:
+		s := string(v)
+		return &s
+	}
+	return nil
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	db, ok := fakeDBs[name]
+	if !ok {
+		return nil, errors.New("no fake database " + name)
+	}
+	return &fakeConn{db: db}, nil
+}
+
+type fakeConn struct{ db fakeBackend }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.db.query(query, args)
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.db.exec(query, args)
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+
+	"cct/utils"
+
+	"github.com/lib/pq"
+)
+
+// BookIngest is what IngestBook changed
+type BookIngest struct {
+	NovelID int `json:"novel_id"`
+	// Created is set when the novel was not stored yet
+	Created  bool        `json:"created"`
+	Chapters ChapterSync `json:"chapters"`
+}
+
+// IngestBook stores the novel and chapter list of a book crawl in one transaction, nothing
+// is stored when it fails. The novel is matched by website and external ID, or by source URL
+// when it has none, created when missing and otherwise updated like UpdateNovel. Its chapters
+// are reconciled with the list in bulk statements, see syncNovelChapters. The IDs of the
+// novel and chapters are set.
+func IngestBook(n *Novel, chapters []Chapter) (BookIngest, error) {
+	var result BookIngest
+
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if n.ExternalID != "" {
+		result.Created, err = upsertNovel(tx, n)
+	} else {
+		result.Created, err = storeNovelByURL(tx, n)
+	}
+	if err != nil {
+		return result, err
+	}
+	result.NovelID = n.ID
+
+	if result.Chapters, err = syncNovelChapters(tx, n.ID, chapters); err != nil {
+		return result, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit book: %w", err)
+	}
+	return result, nil
+}
+
+// upsertNovel creates a novel or updates the one with the same website and external ID,
+// reporting whether it was created. Empty fields don't overwrite the stored ones. A novel
+// stored without external ID under the same source URL is adopted first, so it isn't
+// duplicated.
+func upsertNovel(tx *sql.Tx, n *Novel) (bool, error) {
+	_, err := tx.Exec(`
+		UPDATE novels
+		SET external_id = $3
+		WHERE id = (
+			SELECT id FROM novels
+			WHERE source_url = $1 AND website_id IS NOT DISTINCT FROM $2 AND external_id IS NULL
+			ORDER BY id
+			LIMIT 1
+		)
+		AND NOT EXISTS (SELECT 1 FROM novels WHERE website_id = $2 AND external_id = $3)
+	`, n.SourceURL, n.WebsiteID, n.ExternalID)
+	if err != nil {
+		return false, fmt.Errorf("failed to adopt novel: %w", err)
+	}
+
+	var created bool
+	err = tx.QueryRow(`
+		INSERT INTO novels (website_id, title, external_id, source_url, author, cover_url,
+		                    description, genres, tags, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE(NULLIF($10, ''), '`+NovelStatusOngoing+`'))
+		ON CONFLICT (website_id, external_id) DO UPDATE
+		SET title = COALESCE(NULLIF($2, ''), novels.title),
+		    source_url = COALESCE(NULLIF($4, ''), novels.source_url),
+		    author = COALESCE(NULLIF($5, ''), novels.author),
+		    cover_url = COALESCE(NULLIF($6, ''), novels.cover_url),
+		    description = COALESCE(NULLIF($7, ''), novels.description),
+		    genres = COALESCE($8, novels.genres),
+		    tags = COALESCE($9, novels.tags),
+		    status = COALESCE(NULLIF($10, ''), novels.status)
+		RETURNING id, created_at, xmax = 0
+	`, n.WebsiteID, n.Title, n.ExternalID, n.SourceURL, n.Author, n.CoverURL,
+		n.Description, pq.Array(n.Genres), pq.Array(n.Tags), n.Status).Scan(&n.ID, &n.CreatedAt, &created)
+	if err != nil {
+		return false, fmt.Errorf("failed to store novel: %w", err)
+	}
+	return created, nil
+}
+
+// storeNovelByURL creates a novel without external ID or updates the one with the same
+// source URL, reporting whether it was created
+func storeNovelByURL(tx *sql.Tx, n *Novel) (bool, error) {
+	err := tx.QueryRow("SELECT id FROM novels WHERE source_url = $1 FOR UPDATE", n.SourceURL).Scan(&n.ID)
+	switch {
+	case err == sql.ErrNoRows:
+		return true, createNovel(tx, n)
+	case err != nil:
+		return false, fmt.Errorf("failed to query novel: %w", err)
+	}
+	if err := updateNovel(tx, n); err != nil {
+		return false, fmt.Errorf("failed to update novel: %w", err)
+	}
+	return false, nil
+}
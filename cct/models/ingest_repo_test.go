@@ -0,0 +1,188 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"cct/utils"
+
+	"github.com/lib/pq"
+)
+
+func TestIngestBookTwice(t *testing.T) {
+	db := &fakeBookDB{chapters: map[int64]*fakeListedChapter{}}
+	old := utils.DB
+	utils.DB = openFakeDB(t, db)
+	t.Cleanup(func() { utils.DB = old })
+
+	book := func() []Chapter {
+		return []Chapter{
+			{ExternalID: "1", Title: "Chương 1", URL: "https://a/c/1", Sequence: 1},
+			{ExternalID: "2", Title: "Chương 2", URL: "https://a/c/2", Sequence: 2},
+			{Title: "Ngoại truyện 1", URL: "https://a/x/1", Sequence: 3},
+			{Title: "Ngoại truyện 2", URL: "https://a/x/2", Sequence: 4},
+		}
+	}
+	novel := func() *Novel {
+		return &Novel{WebsiteID: 1, ExternalID: "b1", Title: "Sách", SourceURL: "https://a/b1"}
+	}
+
+	first, err := IngestBook(novel(), book())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Created || first.Chapters.Added != 4 || len(db.chapters) != 4 {
+		t.Fatalf("first ingest %+v stored %d chapters, want 4 added", first, len(db.chapters))
+	}
+
+	second, err := IngestBook(novel(), book())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.chapters) != 4 {
+		t.Errorf("second ingest stored %d chapters, want 4", len(db.chapters))
+	}
+	if want := (ChapterSync{Unchanged: 4}); second.Created || !reflect.DeepEqual(second.Chapters, want) {
+		t.Errorf("second ingest %+v, want %+v", second, want)
+	}
+
+	// A chapter without external ID missing from the list is marked removed
+	third, err := IngestBook(novel(), book()[:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ChapterSync{Unchanged: 3, Removed: 1}); !reflect.DeepEqual(third.Chapters, want) {
+		t.Errorf("third ingest %+v, want %+v", third.Chapters, want)
+	}
+	for _, c := range db.chapters {
+		if removed := c.url == "https://a/x/2"; c.removed != removed {
+			t.Errorf("chapter %s removed %v, want %v", c.url, c.removed, removed)
+		}
+	}
+}
+
+// fakeListedChapter is the listing columns of a chapters row
+type fakeListedChapter struct {
+	externalID, title, url string
+	sequence               int64
+	removed                bool
+}
+
+// fakeBookDB is an in-memory database answering the queries of IngestBook for one novel
+type fakeBookDB struct {
+	novelStored bool
+	chapters    map[int64]*fakeListedChapter
+	nextID      int64
+}
+
+// arrayArg parses an array argument into dest, a pq array type
+func arrayArg(v driver.Value, dest interface{ Scan(any) error }) {
+	if err := dest.Scan(v); err != nil {
+		panic(err)
+	}
+}
+
+func (db *fakeBookDB) query(query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case strings.Contains(query, "INSERT INTO novels"):
+		created := !db.novelStored
+		db.novelStored = true
+		return &fakeRows{columns: []string{"id", "created_at", "created"}, values: [][]driver.Value{{int64(1), time.Now(), created}}}, nil
+
+	case strings.Contains(query, "ordering_locked, removed_at IS NOT NULL"):
+		rows := &fakeRows{columns: []string{"id", "external_id", "title", "url", "chapter_number",
+			"sequence", "volume", "part", "ordering_locked", "removed"}}
+		ids := make([]int64, 0, len(db.chapters))
+		for id := range db.chapters {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			c := db.chapters[id]
+			rows.values = append(rows.values, []driver.Value{id, c.externalID, c.title, c.url, int64(0),
+				c.sequence, int64(0), int64(0), false, c.removed})
+		}
+		return rows, nil
+
+	case strings.Contains(query, "nextval(pg_get_serial_sequence('chapters', 'id'))"):
+		n := args[0].Value.(int64)
+		ids := make(pq.Int64Array, n)
+		for i := range ids {
+			db.nextID++
+			ids[i] = db.nextID
+		}
+		v, _ := ids.Value()
+		return &fakeRows{columns: []string{"array_agg"}, values: [][]driver.Value{{v}}}, nil
+
+	case strings.Contains(query, "INSERT INTO chapters"):
+		var ids, sequences pq.Int64Array
+		var externalIDs, titles, urls pq.StringArray
+		arrayArg(args[1].Value, &ids)
+		arrayArg(args[2].Value, &externalIDs)
+		arrayArg(args[3].Value, &titles)
+		arrayArg(args[5].Value, &urls)
+		arrayArg(args[6].Value, &sequences)
+
+		rows := &fakeRows{columns: []string{"id", "external_id"}}
+		for i, id := range ids {
+			c := &fakeListedChapter{externalID: externalIDs[i], title: titles[i], url: urls[i], sequence: sequences[i]}
+			if c.externalID != "" {
+				for storedID, s := range db.chapters {
+					if s.externalID == c.externalID {
+						id = storedID
+					}
+				}
+			}
+			db.chapters[id] = c
+			rows.values = append(rows.values, []driver.Value{id, c.externalID})
+		}
+		return rows, nil
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+func (db *fakeBookDB) exec(query string, args []driver.NamedValue) (driver.Result, error) {
+	switch {
+	case strings.Contains(query, "SET external_id = $3"):
+		return driver.RowsAffected(0), nil
+
+	case strings.Contains(query, "SET title = u.title"):
+		var ids, sequences pq.Int64Array
+		var titles, urls pq.StringArray
+		arrayArg(args[0].Value, &ids)
+		arrayArg(args[1].Value, &titles)
+		arrayArg(args[2].Value, &urls)
+		arrayArg(args[4].Value, &sequences)
+		for i, id := range ids {
+			c := db.chapters[id]
+			c.title, c.url, c.sequence, c.removed = titles[i], urls[i], sequences[i], false
+		}
+		return driver.RowsAffected(len(ids)), nil
+
+	case strings.Contains(query, "SET removed_at = now()"):
+		var externalIDs, urls pq.StringArray
+		arrayArg(args[1].Value, &externalIDs)
+		arrayArg(args[2].Value, &urls)
+		listed := map[string]bool{}
+		for _, id := range externalIDs {
+			listed["id:"+id] = true
+		}
+		for _, url := range urls {
+			listed["url:"+url] = true
+		}
+		var n int64
+		for _, c := range db.chapters {
+			if !c.removed && !listed[listingKey(c.externalID, c.url)] {
+				c.removed = true
+				n++
+			}
+		}
+		return driver.RowsAffected(n), nil
+	}
+	return nil, errors.New("unexpected statement: " + query)
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cct/utils"
+)
+
+var (
+	// ErrInvalidCursor is returned when a list cursor can't be decoded or was made for another sort
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidSort is returned when a list is sorted by an unknown field
+	ErrInvalidSort = errors.New("invalid sort")
+)
+
+// ListOptions are the pagination and sort parameters of a list
+type ListOptions struct {
+	// Limit is the maximum number of items of the page
+	Limit int
+	// Cursor is the next_cursor of the previous page, empty for the first page
+	Cursor string
+	// Sort is the name of the sort field, empty for the default sort
+	Sort string
+	// Desc sorts in descending order
+	Desc bool
+}
+
+// Page is one page of a list with the total number of items matching the filters
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// sortField is an expression a list can be sorted by. Expr must never be NULL so rows
+// can be compared with a cursor, Type is its SQL type used to cast cursor values.
+type sortField struct {
+	Expr string
+	Type string
+}
+
+// listSpec describes how a table is listed
+type listSpec struct {
+	// Name names the listed rows in errors
+	Name string
+	// From is the FROM clause, Columns the selected columns read by the scan function
+	From    string
+	Columns string
+	// ID is the unique column ordering rows with the same sort value, IDType its SQL type
+	ID     string
+	IDType string
+	// Sorts are the sort fields by name, DefaultSort is used when none is requested
+	Sorts       map[string]sortField
+	DefaultSort string
+}
+
+// SortNames returns the names of the sort fields of a list
+func (s listSpec) SortNames() []string {
+	names := make([]string, 0, len(s.Sorts))
+	for name := range s.Sorts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// listCursor is the position after the last row of a page
+type listCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	ID    string `json:"i"`
+}
+
+// encodeCursor encodes a cursor for clients
+func encodeCursor(c listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor decodes a cursor given by a client
+func decodeCursor(s string) (listCursor, error) {
+	var c listCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// cursorScanner scans the sort value and ID selected after the columns of a row
+type cursorScanner struct {
+	row       rowScanner
+	value, id *string
+}
+
+// Scan implements rowScanner
+func (s cursorScanner) Scan(dest ...any) error {
+	return s.row.Scan(append(dest, s.value, s.id)...)
+}
+
+// listPage returns the page of the rows matching conditions selected by opts, using keyset
+// pagination on the sort field and the ID so pages stay stable while rows are added
+func listPage[T any](spec listSpec, conditions []string, params []interface{}, opts ListOptions, scan func(rowScanner) (T, error)) (*Page[T], error) {
+	sortName := opts.Sort
+	if sortName == "" {
+		sortName = spec.DefaultSort
+	}
+	field, ok := spec.Sorts[sortName]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, expected one of %s", ErrInvalidSort, opts.Sort, strings.Join(spec.SortNames(), ", "))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	page := &Page[T]{Data: []T{}}
+	if err := utils.DB.QueryRow("SELECT COUNT(*) FROM "+spec.From+where, params...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("failed to count %s: %w", spec.Name, err)
+	}
+
+	if opts.Cursor != "" {
+		cursor, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != sortName || cursor.Desc != opts.Desc {
+			return nil, ErrInvalidCursor
+		}
+
+		op := ">"
+		if opts.Desc {
+			op = "<"
+		}
+		params = append(params, cursor.Value, cursor.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, %s) %s ($%d::%s, $%d::%s)",
+			field.Expr, spec.ID, op, len(params)-1, field.Type, len(params), spec.IDType))
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	dir := "ASC"
+	if opts.Desc {
+		dir = "DESC"
+	}
+	params = append(params, opts.Limit+1)
+	query := fmt.Sprintf("SELECT %s, (%s)::text, (%s)::text FROM %s%s ORDER BY %s %s, %s %s LIMIT $%d",
+		spec.Columns, field.Expr, spec.ID, spec.From, where, field.Expr, dir, spec.ID, dir, len(params))
+
+	rows, err := utils.DB.Query(query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", spec.Name, err)
+	}
+	defer rows.Close()
+
+	var last listCursor
+	for rows.Next() {
+		if len(page.Data) == opts.Limit {
+			// One more row than the limit, there is a next page
+			page.NextCursor = encodeCursor(last)
+			break
+		}
+
+		var value, id string
+		item, err := scan(cursorScanner{row: rows, value: &value, id: &id})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s row: %w", spec.Name, err)
+		}
+		page.Data = append(page.Data, item)
+		last = listCursor{Sort: sortName, Desc: opts.Desc, Value: value, ID: id}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating %s rows: %w", spec.Name, err)
+	}
+	return page, nil
+}
+
+// addTimeRange appends the conditions keeping the rows whose column is at or after after
+// and before before, zero times are open bounds
+func addTimeRange(conditions []string, params []interface{}, column string, after, before time.Time) ([]string, []interface{}) {
+	if !after.IsZero() {
+		params = append(params, after)
+		conditions = append(conditions, fmt.Sprintf("%s >= $%d", column, len(params)))
+	}
+	if !before.IsZero() {
+		params = append(params, before)
+		conditions = append(conditions, fmt.Sprintf("%s < $%d", column, len(params)))
+	}
+	return conditions, params
+}
+
+// nullCondition returns the condition keeping the rows whose column is set when set is true,
+// or NULL when it is false
+func nullCondition(column string, set bool) string {
+	if set {
+		return column + " IS NOT NULL"
+	}
+	return column + " IS NULL"
+}
@@ -49,31 +49,178 @@ type Website struct {
 	CreatedAt     time.Time `json:"created_at"`
 }
 
+// WebsiteDomain is a domain a website is served on, its primary domain or an alias
+type WebsiteDomain struct {
+	ID        int       `json:"id"`
+	WebsiteID int       `json:"website_id"`
+	Domain    string    `json:"domain"`
+	IsPrimary bool      `json:"is_primary"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DomainMove is the outcome of moving a website to a new primary domain
+type DomainMove struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Novels   int64  `json:"novels"`
+	Chapters int64  `json:"chapters"`
+}
+
+// WebsiteFilter holds the optional filters for listing websites
+type WebsiteFilter struct {
+	Enabled       *bool
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
 // Novel represents a novel from a website
 type Novel struct {
 	ID            int          `json:"id"`
 	WebsiteID     int          `json:"website_id"`
 	ExternalID    string       `json:"external_id"`
 	Title         string       `json:"title"`
+	Author        string       `json:"author"`
+	CoverURL      string       `json:"cover_url"`
+	Description   string       `json:"description"`
+	Genres        []string     `json:"genres"`
+	Tags          []string     `json:"tags"`
 	Status        string       `json:"status"`
 	SourceURL     string       `json:"source_url"`
 	LastCrawledAt sql.NullTime `json:"last_crawled_at"`
 	CreatedAt     time.Time    `json:"created_at"`
 }
 
-// Chapter represents a chapter of a novel
+// NovelCover represents the locally stored cover image of a novel
+type NovelCover struct {
+	NovelID     int       `json:"novel_id"`
+	Hash        string    `json:"hash"`
+	ContentType string    `json:"content_type"`
+	SourceURL   string    `json:"source_url"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Novel statuses
+const (
+	NovelStatusOngoing   = "ongoing"
+	NovelStatusCompleted = "completed"
+)
+
+// NovelFilter holds the optional filters for listing novels
+type NovelFilter struct {
+	WebsiteID int
+	Status    string
+	Author    string
+	Genre     string
+	Tag       string
+	// Crawled selects novels that were crawled at least once or never
+	Crawled       *bool
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	CrawledAfter  time.Time
+	CrawledBefore time.Time
+}
+
+// ChapterFilter holds the optional filters for listing chapters. Nil booleans don't filter,
+// zero times are open bounds.
+type ChapterFilter struct {
+	NovelID int
+	// FromChapter and ToChapter bound the sequence of the chapters, see ChapterRange
+	FromChapter int
+	ToChapter   int
+	// Crawled selects chapters with or without a crawl time
+	Crawled *bool
+	// HasError selects chapters whose last crawl failed or not
+	HasError      *bool
+	Removed       *bool // chapters the source stopped listing or not
+	CrawledAfter  time.Time
+	CrawledBefore time.Time
+	// WithoutContent leaves content and rich_content empty, for listings that don't show them
+	WithoutContent bool
+}
+
+// Chapter represents a chapter of a novel. Chapters are ordered by Sequence, their position
+// in the source chapter list. Volume and Part optionally group them, 0 when the source has
+// no grouping. OrderLocked keeps a manual renumbering from being overwritten by crawls.
+// RemovedAt is set when the source stopped listing the chapter.
 type Chapter struct {
 	ID            int          `json:"id"`
 	NovelID       int          `json:"novel_id"`
 	ExternalID    string       `json:"external_id"`
 	Title         string       `json:"title"`
 	ChapterNumber int          `json:"chapter_number"`
+	Sequence      int          `json:"sequence"`
+	Volume        int          `json:"volume"`
+	Part          int          `json:"part"`
+	OrderLocked   bool         `json:"ordering_locked"`
 	URL           string       `json:"url"`
 	Content       string       `json:"content"`
+	RichContent   string       `json:"rich_content,omitempty"`
+	ContentFormat string       `json:"content_format"`
+	PageCount     int          `json:"page_count"`
+	ContentHash   string       `json:"content_hash,omitempty"`
 	CrawledAt     sql.NullTime `json:"crawled_at"`
 	Error         string       `json:"error"`
+	RemovedAt     sql.NullTime `json:"removed_at"`
 }
 
+// ChapterRevision is a previous version of a chapter's content
+type ChapterRevision struct {
+	ID            int          `json:"id"`
+	ChapterID     int          `json:"chapter_id"`
+	Content       string       `json:"content,omitempty"`
+	RichContent   string       `json:"rich_content,omitempty"`
+	ContentFormat string       `json:"content_format"`
+	ContentHash   string       `json:"content_hash"`
+	ContentLength int          `json:"content_length"`
+	CrawledAt     sql.NullTime `json:"crawled_at"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// Chapter content formats, ContentFormat describes RichContent.
+// Content is always plain text.
+const (
+	ContentFormatText     = "text"
+	ContentFormatHTML     = "html"
+	ContentFormatMarkdown = "markdown"
+)
+
+// Chapter crawl log statuses
+const (
+	// CrawlLogStatusSuccess is a crawl that stored new content
+	CrawlLogStatusSuccess = "success"
+	CrawlLogStatusFailed  = "failed"
+	// CrawlLogStatusUnchanged is a recrawl whose content matched the stored content
+	CrawlLogStatusUnchanged = "unchanged"
+	// CrawlLogStatusUpdated is a recrawl that replaced different content
+	CrawlLogStatusUpdated = "updated"
+)
+
+// ExportJob is a novel export built in the background
+type ExportJob struct {
+	ID           int          `json:"id"`
+	NovelID      int          `json:"novel_id"`
+	Format       string       `json:"format"`
+	FromChapter  int          `json:"from_chapter,omitempty"`
+	ToChapter    int          `json:"to_chapter,omitempty"`
+	WithRemoved  bool         `json:"with_removed,omitempty"`
+	Status       string       `json:"status"`
+	FileName     string       `json:"file_name,omitempty"`
+	FileSize     int64        `json:"file_size"`
+	ChapterCount int          `json:"chapter_count"`
+	MissingCount int          `json:"missing_count"`
+	Error        string       `json:"error,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	CompletedAt  sql.NullTime `json:"completed_at"`
+}
+
+// Export job statuses
+const (
+	ExportJobStatusPending   = "pending"
+	ExportJobStatusRunning   = "running"
+	ExportJobStatusCompleted = "completed"
+	ExportJobStatusFailed    = "failed"
+)
+
 // Agent represents a crawler agent
 type Agent struct {
 	ID            uuid.UUID    `json:"id"`
@@ -84,6 +231,15 @@ type Agent struct {
 	CreatedAt     time.Time    `json:"created_at"`
 }
 
+// AgentFilter holds the optional filters for listing agents
+type AgentFilter struct {
+	ActiveOnly      bool
+	IPAddress       string
+	Name            string
+	HeartbeatAfter  time.Time
+	HeartbeatBefore time.Time
+}
+
 // User represents a user for API access control
 type User struct {
 	ID           int       `json:"id"`
@@ -93,6 +249,13 @@ type User struct {
 	CreatedAt    time.Time `json:"created_at"`
 }
 
+// UserFilter holds the optional filters for listing users
+type UserFilter struct {
+	Active        *bool
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
 // APIToken represents an API token for authentication
 type APIToken struct {
 	ID          int       `json:"id"`
@@ -112,15 +275,73 @@ type NovelSchedule struct {
 	IntervalSeconds int          `json:"interval_seconds"`
 	LastRunAt       sql.NullTime `json:"last_run_at"`
 	NextRunAt       sql.NullTime `json:"next_run_at"`
-	CreatedAt       time.Time    `json:"created_at"`
-	UpdatedAt       time.Time    `json:"updated_at"`
+	// KeepActive keeps the schedule running when its novel is completed
+	KeepActive bool `json:"keep_active"`
+	// WoundDownAt is when the schedule was slowed down or disabled because its novel was completed
+	WoundDownAt sql.NullTime `json:"wound_down_at"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// ScheduleFilter holds the optional filters for listing schedules
+type ScheduleFilter struct {
+	NovelID       int
+	Enabled       *bool
+	NextRunAfter  time.Time
+	NextRunBefore time.Time
 }
 
 // ChapterCrawlLog represents a log entry for chapter crawling
 type ChapterCrawlLog struct {
 	ID        int       `json:"id"`
 	ChapterID int       `json:"chapter_id"`
-	Status    string    `json:"status"` // success or failed
+	Status    string    `json:"status"` // one of the CrawlLogStatus values
 	Error     string    `json:"error"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// CrawlLogFilter holds the optional filters for listing chapter crawl logs
+type CrawlLogFilter struct {
+	Status        string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// Webhook is a subscription to events delivered by HTTP POST. Empty event types and novel
+// IDs match every event.
+type Webhook struct {
+	ID         int       `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	NovelIDs   []int64   `json:"novel_ids"`
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is an attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	ID         int64     `json:"id"`
+	WebhookID  int       `json:"webhook_id"`
+	DeliveryID uuid.UUID `json:"delivery_id"`
+	EventType  string    `json:"event_type"`
+	Attempt    int       `json:"attempt"`
+	// StatusCode is nil when no response was received
+	StatusCode *int      `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int       `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookOutboxEntry is a delivery of an event to a webhook waiting for its next attempt
+type WebhookOutboxEntry struct {
+	ID         int64
+	WebhookID  int
+	URL        string
+	Secret     string
+	DeliveryID uuid.UUID
+	EventType  string
+	Payload    []byte
+	Attempt    int
+}
@@ -6,49 +6,87 @@ import (
 	"strconv"
 
 	"cct/utils"
+
+	"github.com/lib/pq"
 )
 
-// GetNovels retrieves all novels from the database
-func GetNovels() ([]Novel, error) {
-	rows, err := utils.DB.Query(`
-		SELECT id, website_id, external_id, title, author, genres, cover_url, 
-		       description, status, source_url, last_crawled_at, created_at
-		FROM novels
-		ORDER BY id
-	`)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query novels: %w", err)
+// novelColumns is the column list scanned by scanNovel
+const novelColumns = `id, website_id, COALESCE(external_id, ''), title, COALESCE(author, ''), COALESCE(cover_url, ''),
+	COALESCE(description, ''), genres, tags, status, source_url, last_crawled_at, created_at`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanNovel scans a row selected with novelColumns
+func scanNovel(row rowScanner) (Novel, error) {
+	var n Novel
+	err := row.Scan(
+		&n.ID, &n.WebsiteID, &n.ExternalID, &n.Title, &n.Author, &n.CoverURL,
+		&n.Description, pq.Array(&n.Genres), pq.Array(&n.Tags), &n.Status, &n.SourceURL, &n.LastCrawledAt, &n.CreatedAt,
+	)
+	return n, err
+}
+
+// novelList lists novels, by ID by default
+var novelList = listSpec{
+	Name:    "novels",
+	From:    "novels",
+	Columns: novelColumns,
+	ID:      "id",
+	IDType:  "integer",
+	Sorts: map[string]sortField{
+		"id":              {Expr: "id", Type: "integer"},
+		"title":           {Expr: "title", Type: "text"},
+		"created_at":      {Expr: "COALESCE(created_at, '-infinity')", Type: "timestamp"},
+		"last_crawled_at": {Expr: "COALESCE(last_crawled_at, '-infinity')", Type: "timestamp"},
+	},
+	DefaultSort: "id",
+}
+
+// ListNovels retrieves a page of the novels matching the filter
+func ListNovels(filter NovelFilter, opts ListOptions) (*Page[Novel], error) {
+	params := []interface{}{}
+	conditions := []string{}
+
+	if filter.WebsiteID != 0 {
+		params = append(params, filter.WebsiteID)
+		conditions = append(conditions, fmt.Sprintf("website_id = $%d", len(params)))
 	}
-	defer rows.Close()
 
-	var novels []Novel
-	for rows.Next() {
-		var n Novel
-		if err := rows.Scan(
-			&n.ID, &n.WebsiteID, &n.ExternalID, &n.Title, &n.Status, &n.SourceURL, &n.LastCrawledAt, &n.CreatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan novel row: %w", err)
-		}
-		novels = append(novels, n)
+	if filter.Status != "" {
+		params = append(params, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(params)))
+	}
+
+	if filter.Author != "" {
+		params = append(params, "%"+filter.Author+"%")
+		conditions = append(conditions, fmt.Sprintf("author ILIKE $%d", len(params)))
+	}
+
+	if filter.Genre != "" {
+		params = append(params, pq.Array([]string{filter.Genre}))
+		conditions = append(conditions, fmt.Sprintf("genres @> $%d", len(params)))
+	}
+
+	if filter.Tag != "" {
+		params = append(params, pq.Array([]string{filter.Tag}))
+		conditions = append(conditions, fmt.Sprintf("tags @> $%d", len(params)))
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating novel rows: %w", err)
+	if filter.Crawled != nil {
+		conditions = append(conditions, nullCondition("last_crawled_at", *filter.Crawled))
 	}
+	conditions, params = addTimeRange(conditions, params, "created_at", filter.CreatedAfter, filter.CreatedBefore)
+	conditions, params = addTimeRange(conditions, params, "last_crawled_at", filter.CrawledAfter, filter.CrawledBefore)
 
-	return novels, nil
+	return listPage(novelList, conditions, params, opts, scanNovel)
 }
 
 // GetNovel retrieves a novel by ID
 func GetNovel(id int) (Novel, error) {
-	var n Novel
-	err := utils.DB.QueryRow(`
-		SELECT id, website_id, external_id, title, status, source_url, last_crawled_at, created_at
-		FROM novels
-		WHERE id = $1
-	`, id).Scan(
-		&n.ID, &n.WebsiteID, &n.ExternalID, &n.Title, &n.Status, &n.SourceURL, &n.LastCrawledAt, &n.CreatedAt,
-	)
+	n, err := scanNovel(utils.DB.QueryRow("SELECT "+novelColumns+" FROM novels WHERE id = $1", id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return Novel{}, fmt.Errorf("novel with ID %d not found", id)
@@ -61,14 +99,7 @@ func GetNovel(id int) (Novel, error) {
 
 // GetNovelByUrl retrieves a novel by source URL
 func GetNovelByUrl(url string) (Novel, error) {
-	var n Novel
-	err := utils.DB.QueryRow(`
-		SELECT id, website_id, external_id, title, source_url, last_crawled_at, created_at
-		FROM novels
-		WHERE source_url = $1
-	`, url).Scan(
-		&n.ID, &n.WebsiteID, &n.ExternalID, &n.Title, &n.SourceURL, &n.LastCrawledAt, &n.CreatedAt,
-	)
+	n, err := scanNovel(utils.DB.QueryRow("SELECT "+novelColumns+" FROM novels WHERE source_url = $1", url))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return Novel{}, fmt.Errorf("novel with source URL %s not found", url)
@@ -79,44 +110,43 @@ func GetNovelByUrl(url string) (Novel, error) {
 	return n, nil
 }
 
-// GetNovelsByWebsite retrieves all novels for a specific website
-func GetNovelsByWebsite(websiteID int) ([]Novel, error) {
-	rows, err := utils.DB.Query(`
-		SELECT id, website_id, external_id, title, status, source_url, last_crawled_at, created_at
-		FROM novels
-		WHERE website_id = $1
-		ORDER BY id
-	`, websiteID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query novels by website: %w", err)
+// GetURLNovel returns the novel and website IDs of a novel source URL or a chapter URL,
+// both 0 when no novel has the URL
+func GetURLNovel(url string) (novelID, websiteID int, err error) {
+	var website sql.NullInt64
+	err = utils.DB.QueryRow(`
+		SELECT id, website_id FROM novels WHERE source_url = $1
+		UNION ALL
+		SELECT n.id, n.website_id FROM chapters c JOIN novels n ON n.id = c.novel_id WHERE c.url = $1
+		LIMIT 1
+	`, url).Scan(&novelID, &website)
+	if err == sql.ErrNoRows {
+		return 0, 0, nil
 	}
-	defer rows.Close()
-
-	var novels []Novel
-	for rows.Next() {
-		var n Novel
-		if err := rows.Scan(
-			&n.ID, &n.WebsiteID, &n.ExternalID, &n.Title, &n.Status, &n.SourceURL, &n.LastCrawledAt, &n.CreatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan novel row: %w", err)
-		}
-		novels = append(novels, n)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating novel rows: %w", err)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query novel by URL: %w", err)
 	}
-
-	return novels, nil
+	return novelID, int(website.Int64), nil
 }
 
 // CreateNovel creates a new novel in the database
 func CreateNovel(n *Novel) error {
-	err := utils.DB.QueryRow(`
-		INSERT INTO novels (website_id, title, external_id, source_url)
-		VALUES ($1, $2, $3, $4)
+	return createNovel(utils.DB, n)
+}
+
+// createNovel inserts a novel with q and sets its ID
+func createNovel(q execQuerier, n *Novel) error {
+	if n.Status == "" {
+		n.Status = NovelStatusOngoing
+	}
+
+	err := q.QueryRow(`
+		INSERT INTO novels (website_id, title, external_id, source_url, author, cover_url,
+		                    description, genres, tags, status)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10)
 		RETURNING id, created_at
-	`, n.WebsiteID, n.Title, n.ExternalID, n.SourceURL).Scan(&n.ID, &n.CreatedAt)
+	`, n.WebsiteID, n.Title, n.ExternalID, n.SourceURL, n.Author, n.CoverURL,
+		n.Description, pq.Array(n.Genres), pq.Array(n.Tags), n.Status).Scan(&n.ID, &n.CreatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to create novel: %w", err)
 	}
@@ -126,6 +156,11 @@ func CreateNovel(n *Novel) error {
 
 // UpdateNovel updates an existing novel
 func UpdateNovel(n *Novel) error {
+	return updateNovel(utils.DB, n)
+}
+
+// updateNovel updates the fields of a novel that are set with q
+func updateNovel(q execQuerier, n *Novel) error {
 	var columns []string
 	var values []interface{}
 	if n.Title != "" {
@@ -140,6 +175,29 @@ func UpdateNovel(n *Novel) error {
 		columns = append(columns, "source_url")
 		values = append(values, n.SourceURL)
 	}
+	if n.Author != "" {
+		columns = append(columns, "author")
+		values = append(values, n.Author)
+	}
+	if n.CoverURL != "" {
+		columns = append(columns, "cover_url")
+		values = append(values, n.CoverURL)
+	}
+	if n.Description != "" {
+		columns = append(columns, "description")
+		values = append(values, n.Description)
+	}
+	if n.Genres != nil {
+		columns = append(columns, "genres")
+		values = append(values, pq.Array(n.Genres))
+	}
+	if n.Tags != nil {
+		columns = append(columns, "tags")
+		values = append(values, pq.Array(n.Tags))
+	}
+	if len(columns) == 0 {
+		return nil
+	}
 
 	updateSQL := "UPDATE novels SET "
 	for i, column := range columns {
@@ -150,15 +208,73 @@ func UpdateNovel(n *Novel) error {
 	}
 	updateSQL += " WHERE id = $" + strconv.Itoa(len(columns)+1)
 
-	_, err := utils.DB.Exec(updateSQL, append(values, n.ID)...)
+	_, err := q.Exec(updateSQL, append(values, n.ID)...)
 	return err
 }
 
-// DeleteNovel deletes a novel by ID
+// GetNovelCover retrieves the stored cover of a novel
+func GetNovelCover(novelID int) (NovelCover, error) {
+	var c NovelCover
+	var updatedAt sql.NullTime
+	err := utils.DB.QueryRow(`
+		SELECT id, COALESCE(cover_hash, ''), COALESCE(cover_content_type, ''),
+		       COALESCE(cover_source_url, ''), cover_updated_at
+		FROM novels
+		WHERE id = $1
+	`, novelID).Scan(&c.NovelID, &c.Hash, &c.ContentType, &c.SourceURL, &updatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return NovelCover{}, fmt.Errorf("novel with ID %d not found", novelID)
+		}
+		return NovelCover{}, fmt.Errorf("failed to query novel cover: %w", err)
+	}
+	c.UpdatedAt = updatedAt.Time
+
+	return c, nil
+}
+
+// UpdateNovelCover sets the stored cover of a novel
+func UpdateNovelCover(c *NovelCover) error {
+	err := utils.DB.QueryRow(`
+		UPDATE novels
+		SET cover_hash = $1, cover_content_type = $2, cover_source_url = $3, cover_updated_at = NOW()
+		WHERE id = $4
+		RETURNING cover_updated_at
+	`, c.Hash, c.ContentType, c.SourceURL, c.NovelID).Scan(&c.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("novel with ID %d not found", c.NovelID)
+		}
+		return fmt.Errorf("failed to update novel cover: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteNovel deletes a novel by ID with its chapters and their content stored out of row
 func DeleteNovel(id int) error {
-	_, err := utils.DB.Exec("DELETE FROM novels WHERE id = $1", id)
+	tx, err := utils.DB.Begin()
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var keys pq.StringArray
+	err = tx.QueryRow(`
+		SELECT COALESCE(array_agg(DISTINCT k.key), '{}')
+		FROM chapters c, unnest(ARRAY[c.content_key, c.rich_content_key]) AS k(key)
+		WHERE c.novel_id = $1 AND k.key IS NOT NULL
+	`, id).Scan(&keys)
+	if err != nil {
+		return fmt.Errorf("failed to query novel chapter content: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM novels WHERE id = $1", id); err != nil {
 		return fmt.Errorf("failed to delete novel: %w", err)
 	}
-	return nil
+
+	if err := pruneContentBlobs(tx, keys...); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
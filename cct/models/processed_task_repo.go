@@ -0,0 +1,120 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cct/utils"
+)
+
+// Processed task statuses
+const (
+	ProcessedTaskStatusProcessing = "processing"
+	ProcessedTaskStatusProcessed  = "processed"
+)
+
+// ProcessedTask is a task result cct applied or is applying, kept until it expires so a
+// repeated result is answered with the stored outcome instead of being applied again
+type ProcessedTask struct {
+	TaskID      string          `json:"task_id"`
+	TaskType    string          `json:"task_type"`
+	Status      string          `json:"status"`
+	Outcome     json.RawMessage `json:"outcome,omitempty"`
+	ClaimedAt   time.Time       `json:"claimed_at"`
+	ProcessedAt sql.NullTime    `json:"processed_at"`
+	ExpiresAt   time.Time       `json:"expires_at"`
+}
+
+// ClaimProcessedTask claims the result of a task before it is applied. It returns nil when the
+// task was claimed, otherwise the task as stored by the claim that came first. Expired tasks
+// and claims left processing for longer than stale are claimed again.
+func ClaimProcessedTask(taskID, taskType string, ttl, stale time.Duration) (*ProcessedTask, error) {
+	now := time.Now()
+
+	var claimed string
+	err := utils.DB.QueryRow(`
+		INSERT INTO processed_tasks (task_id, task_type, status, claimed_at, expires_at)
+		VALUES ($1, $2, '`+ProcessedTaskStatusProcessing+`', $3, $4)
+		ON CONFLICT (task_id) DO UPDATE
+		SET task_type = EXCLUDED.task_type,
+		    status = EXCLUDED.status,
+		    outcome = NULL,
+		    claimed_at = EXCLUDED.claimed_at,
+		    processed_at = NULL,
+		    expires_at = EXCLUDED.expires_at
+		WHERE processed_tasks.expires_at < $3
+		   OR (processed_tasks.status = '`+ProcessedTaskStatusProcessing+`' AND processed_tasks.claimed_at < $5)
+		RETURNING task_id
+	`, taskID, taskType, now, now.Add(ttl), now.Add(-stale)).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to claim task: %w", err)
+	}
+
+	var t ProcessedTask
+	var outcome []byte
+	err = utils.DB.QueryRow(`
+		SELECT task_id, task_type, status, outcome, claimed_at, processed_at, expires_at
+		FROM processed_tasks
+		WHERE task_id = $1
+	`, taskID).Scan(&t.TaskID, &t.TaskType, &t.Status, &outcome, &t.ClaimedAt, &t.ProcessedAt, &t.ExpiresAt)
+	if err == sql.ErrNoRows {
+		// The first claim was released in between, the task is still being retried
+		return &ProcessedTask{TaskID: taskID, TaskType: taskType, Status: ProcessedTaskStatusProcessing}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query processed task: %w", err)
+	}
+	t.Outcome = outcome
+	return &t, nil
+}
+
+// CompleteProcessedTask stores the outcome of a claimed task, it expires ttl from now
+func CompleteProcessedTask(taskID string, outcome any, ttl time.Duration) error {
+	data, err := json.Marshal(outcome)
+	if err != nil {
+		return fmt.Errorf("failed to encode task outcome: %w", err)
+	}
+	now := time.Now()
+
+	_, err = utils.DB.Exec(`
+		UPDATE processed_tasks
+		SET status = '`+ProcessedTaskStatusProcessed+`', outcome = $2, processed_at = $3, expires_at = $4
+		WHERE task_id = $1
+	`, taskID, data, now, now.Add(ttl))
+	if err != nil {
+		return fmt.Errorf("failed to complete processed task: %w", err)
+	}
+	return nil
+}
+
+// ReleaseProcessedTask drops the claim of a task whose result could not be applied, so it
+// is applied when the agent sends it again
+func ReleaseProcessedTask(taskID string) error {
+	_, err := utils.DB.Exec(`
+		DELETE FROM processed_tasks
+		WHERE task_id = $1 AND status = '`+ProcessedTaskStatusProcessing+`'
+	`, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to release processed task: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredProcessedTasks deletes the processed tasks past their expiry
+func DeleteExpiredProcessedTasks() (int64, error) {
+	result, err := utils.DB.Exec("DELETE FROM processed_tasks WHERE expires_at < $1", time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired processed tasks: %w", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"cct/utils"
+
+	"github.com/google/uuid"
+)
+
+// ErrIncompleteUpload is returned when joining an upload with missing chunks
+var ErrIncompleteUpload = errors.New("incomplete upload")
+
+// SaveResultUploadChunk stores a chunk of a result upload, replacing the one sent before at
+// the same index. It returns the size of the chunks stored for the upload.
+func SaveResultUploadChunk(uploadID uuid.UUID, index int, data []byte) (int64, error) {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO result_upload_chunks (upload_id, chunk_index, data, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (upload_id, chunk_index) DO UPDATE
+		SET data = EXCLUDED.data, created_at = EXCLUDED.created_at
+	`, uploadID, index, data, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to store upload chunk: %w", err)
+	}
+
+	var size int64
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(length(data)), 0) FROM result_upload_chunks WHERE upload_id = $1
+	`, uploadID).Scan(&size)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query upload size: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit upload chunk: %w", err)
+	}
+	return size, nil
+}
+
+// JoinResultUpload joins the chunks 0 to count-1 of an upload in order
+func JoinResultUpload(uploadID uuid.UUID, count int) ([]byte, error) {
+	rows, err := utils.DB.Query(`
+		SELECT chunk_index, data
+		FROM result_upload_chunks
+		WHERE upload_id = $1 AND chunk_index < $2
+		ORDER BY chunk_index
+	`, uploadID, count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query upload chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var buf bytes.Buffer
+	next := 0
+	for rows.Next() {
+		var index int
+		var data []byte
+		if err := rows.Scan(&index, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan upload chunk: %w", err)
+		}
+		if index != next {
+			return nil, fmt.Errorf("%w: chunk %d is missing", ErrIncompleteUpload, next)
+		}
+		buf.Write(data)
+		next++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating upload chunks: %w", err)
+	}
+	if next != count {
+		return nil, fmt.Errorf("%w: chunk %d is missing", ErrIncompleteUpload, next)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// DeleteResultUpload deletes the chunks of an upload
+func DeleteResultUpload(uploadID uuid.UUID) error {
+	if _, err := utils.DB.Exec("DELETE FROM result_upload_chunks WHERE upload_id = $1", uploadID); err != nil {
+		return fmt.Errorf("failed to delete upload: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredResultUploads deletes the uploads that got no chunk for the ttl, their final
+// chunk never came
+func DeleteExpiredResultUploads(ttl time.Duration) (int64, error) {
+	cutoffTime := time.Now().Add(-ttl)
+
+	result, err := utils.DB.Exec(`
+		DELETE FROM result_upload_chunks
+		WHERE upload_id IN (
+			SELECT upload_id FROM result_upload_chunks
+			GROUP BY upload_id
+			HAVING MAX(created_at) < $1
+		)
+	`, cutoffTime)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired uploads: %w", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return count, nil
+}
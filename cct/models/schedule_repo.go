@@ -8,46 +8,55 @@ import (
 	"cct/utils"
 )
 
-// GetSchedules retrieves all novel schedules from the database
-func GetSchedules() ([]NovelSchedule, error) {
-	rows, err := utils.DB.Query(`
-		SELECT id, novel_id, enabled, interval_seconds, last_run_at, next_run_at, created_at, updated_at
-		FROM novel_schedules
-		ORDER BY id
-	`)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query schedules: %w", err)
-	}
-	defer rows.Close()
+// scheduleColumns is the column list scanned by scanSchedule
+const scheduleColumns = "id, novel_id, enabled, interval_seconds, last_run_at, next_run_at, keep_active, wound_down_at, created_at, updated_at"
+
+// scheduleList lists schedules, by ID by default
+var scheduleList = listSpec{
+	Name:    "schedules",
+	From:    "novel_schedules",
+	Columns: scheduleColumns,
+	ID:      "id",
+	IDType:  "integer",
+	Sorts: map[string]sortField{
+		"id":          {Expr: "id", Type: "integer"},
+		"last_run_at": {Expr: "COALESCE(last_run_at, '-infinity')", Type: "timestamp"},
+		"next_run_at": {Expr: "COALESCE(next_run_at, '-infinity')", Type: "timestamp"},
+	},
+	DefaultSort: "id",
+}
 
-	var schedules []NovelSchedule
-	for rows.Next() {
-		var s NovelSchedule
-		if err := rows.Scan(
-			&s.ID, &s.NovelID, &s.Enabled, &s.IntervalSeconds, &s.LastRunAt, &s.NextRunAt, &s.CreatedAt, &s.UpdatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan schedule row: %w", err)
-		}
-		schedules = append(schedules, s)
-	}
+// scanSchedule scans a row selected with scheduleColumns
+func scanSchedule(row rowScanner) (NovelSchedule, error) {
+	var s NovelSchedule
+	err := row.Scan(
+		&s.ID, &s.NovelID, &s.Enabled, &s.IntervalSeconds, &s.LastRunAt, &s.NextRunAt,
+		&s.KeepActive, &s.WoundDownAt, &s.CreatedAt, &s.UpdatedAt,
+	)
+	return s, err
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating schedule rows: %w", err)
+// ListSchedules retrieves a page of the schedules matching the filter
+func ListSchedules(filter ScheduleFilter, opts ListOptions) (*Page[NovelSchedule], error) {
+	params := []interface{}{}
+	conditions := []string{}
+
+	if filter.NovelID != 0 {
+		params = append(params, filter.NovelID)
+		conditions = append(conditions, fmt.Sprintf("novel_id = $%d", len(params)))
 	}
+	if filter.Enabled != nil {
+		params = append(params, *filter.Enabled)
+		conditions = append(conditions, fmt.Sprintf("enabled = $%d", len(params)))
+	}
+	conditions, params = addTimeRange(conditions, params, "next_run_at", filter.NextRunAfter, filter.NextRunBefore)
 
-	return schedules, nil
+	return listPage(scheduleList, conditions, params, opts, scanSchedule)
 }
 
 // GetSchedule retrieves a schedule by ID
 func GetSchedule(id int) (NovelSchedule, error) {
-	var s NovelSchedule
-	err := utils.DB.QueryRow(`
-		SELECT id, novel_id, enabled, interval_seconds, last_run_at, next_run_at, created_at, updated_at
-		FROM novel_schedules
-		WHERE id = $1
-	`, id).Scan(
-		&s.ID, &s.NovelID, &s.Enabled, &s.IntervalSeconds, &s.LastRunAt, &s.NextRunAt, &s.CreatedAt, &s.UpdatedAt,
-	)
+	s, err := scanSchedule(utils.DB.QueryRow("SELECT "+scheduleColumns+" FROM novel_schedules WHERE id = $1", id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return NovelSchedule{}, fmt.Errorf("schedule with ID %d not found", id)
@@ -58,37 +67,6 @@ func GetSchedule(id int) (NovelSchedule, error) {
 	return s, nil
 }
 
-// GetSchedulesByNovel retrieves schedules for a specific novel
-func GetSchedulesByNovel(novelID int) ([]NovelSchedule, error) {
-	rows, err := utils.DB.Query(`
-		SELECT id, novel_id, enabled, interval_seconds, last_run_at, next_run_at, created_at, updated_at
-		FROM novel_schedules
-		WHERE novel_id = $1
-		ORDER BY id
-	`, novelID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query schedules for novel %d: %w", novelID, err)
-	}
-	defer rows.Close()
-
-	var schedules []NovelSchedule
-	for rows.Next() {
-		var s NovelSchedule
-		if err := rows.Scan(
-			&s.ID, &s.NovelID, &s.Enabled, &s.IntervalSeconds, &s.LastRunAt, &s.NextRunAt, &s.CreatedAt, &s.UpdatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan schedule row: %w", err)
-		}
-		schedules = append(schedules, s)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating schedule rows: %w", err)
-	}
-
-	return schedules, nil
-}
-
 // CreateSchedule creates a new schedule in the database
 func CreateSchedule(s *NovelSchedule) error {
 	// Set next_run_at to now + interval if not provided
@@ -98,10 +76,10 @@ func CreateSchedule(s *NovelSchedule) error {
 	}
 
 	err := utils.DB.QueryRow(`
-		INSERT INTO novel_schedules (novel_id, enabled, interval_seconds, next_run_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO novel_schedules (novel_id, enabled, interval_seconds, next_run_at, keep_active)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id, created_at, updated_at
-	`, s.NovelID, s.Enabled, s.IntervalSeconds, s.NextRunAt).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+	`, s.NovelID, s.Enabled, s.IntervalSeconds, s.NextRunAt, s.KeepActive).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to create schedule: %w", err)
 	}
@@ -114,9 +92,9 @@ func UpdateSchedule(s *NovelSchedule) error {
 	s.UpdatedAt = time.Now()
 	_, err := utils.DB.Exec(`
 		UPDATE novel_schedules
-		SET enabled = $1, interval_seconds = $2, next_run_at = $3, updated_at = $4
-		WHERE id = $5
-	`, s.Enabled, s.IntervalSeconds, s.NextRunAt, s.UpdatedAt, s.ID)
+		SET enabled = $1, interval_seconds = $2, next_run_at = $3, keep_active = $4, updated_at = $5
+		WHERE id = $6
+	`, s.Enabled, s.IntervalSeconds, s.NextRunAt, s.KeepActive, s.UpdatedAt, s.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update schedule: %w", err)
 	}
@@ -135,8 +113,8 @@ func DeleteSchedule(id int) error {
 
 // GetDueSchedules retrieves schedules that are due to run
 func GetDueSchedules() ([]NovelSchedule, error) {
-	rows, err := utils.DB.Query(`
-		SELECT id, novel_id, enabled, interval_seconds, last_run_at, next_run_at, created_at, updated_at
+	schedules, err := querySchedules(`
+		SELECT ` + scheduleColumns + `
 		FROM novel_schedules
 		WHERE enabled = true AND next_run_at IS NOT NULL AND next_run_at <= NOW()
 		ORDER BY next_run_at
@@ -144,14 +122,21 @@ func GetDueSchedules() ([]NovelSchedule, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to query due schedules: %w", err)
 	}
+	return schedules, nil
+}
+
+// querySchedules runs a query selecting scheduleColumns
+func querySchedules(query string, args ...any) ([]NovelSchedule, error) {
+	rows, err := utils.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	var schedules []NovelSchedule
 	for rows.Next() {
-		var s NovelSchedule
-		if err := rows.Scan(
-			&s.ID, &s.NovelID, &s.Enabled, &s.IntervalSeconds, &s.LastRunAt, &s.NextRunAt, &s.CreatedAt, &s.UpdatedAt,
-		); err != nil {
+		s, err := scanSchedule(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan schedule row: %w", err)
 		}
 		schedules = append(schedules, s)
@@ -164,6 +149,67 @@ func GetDueSchedules() ([]NovelSchedule, error) {
 	return schedules, nil
 }
 
+// WindDownCompletedSchedules winds down the schedules of a novel once it is completed and
+// every chapter has content: their interval is raised to intervalSeconds, or they are
+// disabled when intervalSeconds is 0. Schedules that keep active or are already wound
+// down are left as is. It returns the schedules wound down.
+func WindDownCompletedSchedules(novelID, intervalSeconds int) ([]NovelSchedule, error) {
+	schedules, err := querySchedules(`
+		UPDATE novel_schedules s
+		SET active_interval_seconds = s.interval_seconds,
+		    interval_seconds = GREATEST(s.interval_seconds, $2),
+		    enabled = $2 > 0,
+		    next_run_at = COALESCE(s.last_run_at, now()) + make_interval(secs => GREATEST(s.interval_seconds, $2)),
+		    wound_down_at = now(), updated_at = now()
+		WHERE s.novel_id = $1 AND s.enabled AND NOT s.keep_active AND s.wound_down_at IS NULL
+		  AND EXISTS (SELECT 1 FROM novels n WHERE n.id = s.novel_id AND n.status = '`+NovelStatusCompleted+`')
+		  AND EXISTS (SELECT 1 FROM chapters c WHERE c.novel_id = s.novel_id AND c.removed_at IS NULL)
+		  AND NOT EXISTS (
+			SELECT 1 FROM chapters c
+			WHERE c.novel_id = s.novel_id AND c.removed_at IS NULL
+			  AND c.content_key IS NULL AND COALESCE(c.content, '') = ''
+		  )
+		RETURNING `+scheduleColumns, novelID, intervalSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wind down schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// ReviveNovelSchedules restores the interval of the schedules of a novel wound down while
+// it was completed and enables them again. It returns the schedules revived.
+func ReviveNovelSchedules(novelID int) ([]NovelSchedule, error) {
+	return reviveSchedules("novel_id = $1", novelID)
+}
+
+// ReviveSchedule restores a schedule wound down while its novel was completed
+func ReviveSchedule(id int) (NovelSchedule, error) {
+	schedules, err := reviveSchedules("id = $1", id)
+	if err != nil {
+		return NovelSchedule{}, err
+	}
+	if len(schedules) == 0 {
+		return GetSchedule(id)
+	}
+	return schedules[0], nil
+}
+
+// reviveSchedules restores the wound down schedules matching where
+func reviveSchedules(where string, args ...any) ([]NovelSchedule, error) {
+	schedules, err := querySchedules(`
+		UPDATE novel_schedules
+		SET enabled = true,
+		    interval_seconds = COALESCE(active_interval_seconds, interval_seconds),
+		    next_run_at = COALESCE(last_run_at, now()) + make_interval(secs => COALESCE(active_interval_seconds, interval_seconds)),
+		    wound_down_at = NULL, active_interval_seconds = NULL, updated_at = now()
+		WHERE wound_down_at IS NOT NULL AND `+where+`
+		RETURNING `+scheduleColumns, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revive schedules: %w", err)
+	}
+	return schedules, nil
+}
+
 // UpdateScheduleRunTime updates the last_run_at and next_run_at for a schedule
 func UpdateScheduleRunTime(id int, intervalSeconds int) error {
 	now := time.Now()
@@ -225,33 +271,37 @@ func CreateChapterCrawlLog(log *ChapterCrawlLog) error {
 	return nil
 }
 
-// GetChapterCrawlLogs retrieves crawl logs for a chapter
-func GetChapterCrawlLogs(chapterID int) ([]ChapterCrawlLog, error) {
-	rows, err := utils.DB.Query(`
-		SELECT id, chapter_id, status, error, created_at
-		FROM chapter_crawl_logs
-		WHERE chapter_id = $1
-		ORDER BY created_at DESC
-	`, chapterID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query chapter crawl logs: %w", err)
-	}
-	defer rows.Close()
+// crawlLogList lists chapter crawl logs, by creation time by default
+var crawlLogList = listSpec{
+	Name:    "chapter crawl logs",
+	From:    "chapter_crawl_logs",
+	Columns: "id, chapter_id, status, COALESCE(error, ''), created_at",
+	ID:      "id",
+	IDType:  "integer",
+	Sorts: map[string]sortField{
+		"id":         {Expr: "id", Type: "integer"},
+		"created_at": {Expr: "COALESCE(created_at, '-infinity')", Type: "timestamp"},
+	},
+	DefaultSort: "created_at",
+}
 
-	var logs []ChapterCrawlLog
-	for rows.Next() {
-		var log ChapterCrawlLog
-		if err := rows.Scan(
-			&log.ID, &log.ChapterID, &log.Status, &log.Error, &log.CreatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan chapter crawl log row: %w", err)
-		}
-		logs = append(logs, log)
-	}
+// scanCrawlLog scans a row selected with the crawlLogList columns
+func scanCrawlLog(row rowScanner) (ChapterCrawlLog, error) {
+	var log ChapterCrawlLog
+	err := row.Scan(&log.ID, &log.ChapterID, &log.Status, &log.Error, &log.CreatedAt)
+	return log, err
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating chapter crawl log rows: %w", err)
+// ListChapterCrawlLogs retrieves a page of the crawl logs of a chapter matching the filter
+func ListChapterCrawlLogs(chapterID int, filter CrawlLogFilter, opts ListOptions) (*Page[ChapterCrawlLog], error) {
+	params := []interface{}{chapterID}
+	conditions := []string{"chapter_id = $1"}
+
+	if filter.Status != "" {
+		params = append(params, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(params)))
 	}
+	conditions, params = addTimeRange(conditions, params, "created_at", filter.CreatedAfter, filter.CreatedBefore)
 
-	return logs, nil
+	return listPage(crawlLogList, conditions, params, opts, scanCrawlLog)
 }
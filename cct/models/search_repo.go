@@ -0,0 +1,157 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"cct/utils"
+
+	"github.com/lib/pq"
+)
+
+// searchConfig is the text search configuration of chapters.search_vector
+const searchConfig = "vn_unaccent"
+
+// searchHeadlineOptions selects the snippet returned with each hit
+const searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=\" … \""
+
+// snippetTextSize bounds the chapter text, in bytes, that snippets are taken from. Only
+// this prefix is decompressed and sent back for highlighting, so a chapter matching
+// further on gets a snippet of its start.
+const snippetTextSize = 32 << 10
+
+// SearchFilter restricts a chapter search
+type SearchFilter struct {
+	Query       string
+	WebsiteID   int
+	NovelID     int
+	FromChapter int // bounds of the chapter sequence, see ChapterRange
+	ToChapter   int
+	Limit       int
+	Offset      int
+}
+
+// SearchHit is a chapter matching a search. Snippet is HTML, the chapter text is escaped
+// and the matched words are wrapped in <mark>.
+type SearchHit struct {
+	ChapterID     int     `json:"chapter_id"`
+	NovelID       int     `json:"novel_id"`
+	NovelTitle    string  `json:"novel_title"`
+	WebsiteID     int     `json:"website_id"`
+	Title         string  `json:"title"`
+	ChapterNumber int     `json:"chapter_number"`
+	URL           string  `json:"url"`
+	Rank          float64 `json:"rank"`
+	Snippet       string  `json:"snippet"`
+}
+
+// SearchChapters searches chapter titles and content, best matches first.
+// The query uses web search syntax: quoted phrases, "or" and -excluded words.
+func SearchChapters(filter SearchFilter) ([]SearchHit, error) {
+	params := []interface{}{filter.Query}
+	conditions := []string{"c.search_vector @@ q.query"}
+
+	if filter.WebsiteID > 0 {
+		params = append(params, filter.WebsiteID)
+		conditions = append(conditions, fmt.Sprintf("n.website_id = $%d", len(params)))
+	}
+	if filter.NovelID > 0 {
+		params = append(params, filter.NovelID)
+		conditions = append(conditions, fmt.Sprintf("c.novel_id = $%d", len(params)))
+	}
+	conditions, params = sequenceRange("c.sequence", filter.FromChapter, filter.ToChapter, conditions, params)
+
+	params = append(params, filter.Limit)
+	limitParam := len(params)
+	params = append(params, filter.Offset)
+	offsetParam := len(params)
+	params = append(params, snippetTextSize)
+	snippetParam := len(params)
+
+	query := fmt.Sprintf(`
+		WITH q AS (SELECT websearch_to_tsquery('%[1]s', $1) AS query),
+		hits AS (
+			SELECT c.id, c.novel_id, n.title AS novel_title, COALESCE(n.website_id, 0) AS website_id, COALESCE(c.title, '') AS title,
+			       COALESCE(c.chapter_number, 0) AS chapter_number, c.url, left(COALESCE(c.content, ''), $%[5]d) AS content,
+			       c.content_key, ts_rank_cd(c.search_vector, q.query) AS rank
+			FROM chapters c
+			JOIN novels n ON n.id = c.novel_id
+			CROSS JOIN q
+			WHERE %[2]s
+			ORDER BY rank DESC, c.id
+			LIMIT $%[3]d OFFSET $%[4]d
+		)
+		SELECT hits.id, hits.novel_id, hits.novel_title, hits.website_id, hits.title, hits.chapter_number,
+		       hits.url, hits.rank, hits.content,
+		       (SELECT data FROM chapter_contents WHERE hash = hits.content_key)
+		FROM hits
+		ORDER BY hits.rank DESC, hits.id
+	`, searchConfig, strings.Join(conditions, " AND "), limitParam, offsetParam, snippetParam)
+
+	rows, err := utils.DB.Query(query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search chapters: %w", err)
+	}
+	defer rows.Close()
+
+	hits := []SearchHit{}
+	texts := []string{}
+	for rows.Next() {
+		var h SearchHit
+		var text string
+		var data []byte
+		if err := rows.Scan(
+			&h.ChapterID, &h.NovelID, &h.NovelTitle, &h.WebsiteID, &h.Title, &h.ChapterNumber,
+			&h.URL, &h.Rank, &text, &data,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan search hit: %w", err)
+		}
+		if data != nil {
+			if text, err = decodeContentPrefix(data, snippetTextSize); err != nil {
+				return nil, err
+			}
+		}
+		hits = append(hits, h)
+		texts = append(texts, truncateText(text, snippetTextSize))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search hits: %w", err)
+	}
+
+	if err := setSnippets(filter.Query, hits, texts); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// setSnippets sets the snippet of each hit from the start of the text of its chapter.
+// Content may be stored compressed, so headlines are computed over texts sent back to the
+// database, and only for the page of hits as they are expensive.
+func setSnippets(query string, hits []SearchHit, texts []string) error {
+	if len(hits) == 0 {
+		return nil
+	}
+
+	rows, err := utils.DB.Query(fmt.Sprintf(`
+		SELECT ts_headline('%[1]s',
+		                   replace(replace(replace(u.text, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+		                   websearch_to_tsquery('%[1]s', $1), '%[2]s')
+		FROM unnest($2::text[]) WITH ORDINALITY AS u(text, i)
+		ORDER BY u.i
+	`, searchConfig, searchHeadlineOptions), query, pq.Array(texts))
+	if err != nil {
+		return fmt.Errorf("failed to highlight search hits: %w", err)
+	}
+	defer rows.Close()
+
+	for i := 0; rows.Next() && i < len(hits); i++ {
+		if err := rows.Scan(&hits[i].Snippet); err != nil {
+			return fmt.Errorf("failed to scan search snippet: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating search snippets: %w", err)
+	}
+	return nil
+}
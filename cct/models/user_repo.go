@@ -9,34 +9,40 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-// GetUsers retrieves all users from the database
-func GetUsers() ([]User, error) {
-	rows, err := utils.DB.Query(`
-		SELECT id, email, password_hash, is_active, created_at
-		FROM users
-		ORDER BY id
-	`)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query users: %w", err)
-	}
-	defer rows.Close()
-
-	var users []User
-	for rows.Next() {
-		var u User
-		if err := rows.Scan(
-			&u.ID, &u.Email, &u.PasswordHash, &u.IsActive, &u.CreatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan user row: %w", err)
-		}
-		users = append(users, u)
-	}
+// userList lists users, by ID by default
+var userList = listSpec{
+	Name:    "users",
+	From:    "users",
+	Columns: "id, email, password_hash, is_active, created_at",
+	ID:      "id",
+	IDType:  "integer",
+	Sorts: map[string]sortField{
+		"id":         {Expr: "id", Type: "integer"},
+		"email":      {Expr: "email", Type: "text"},
+		"created_at": {Expr: "COALESCE(created_at, '-infinity')", Type: "timestamp"},
+	},
+	DefaultSort: "id",
+}
+
+// scanUser scans a row selected with the userList columns
+func scanUser(row rowScanner) (User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.IsActive, &u.CreatedAt)
+	return u, err
+}
+
+// ListUsers retrieves a page of the users matching the filter
+func ListUsers(filter UserFilter, opts ListOptions) (*Page[User], error) {
+	params := []interface{}{}
+	conditions := []string{}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating user rows: %w", err)
+	if filter.Active != nil {
+		params = append(params, *filter.Active)
+		conditions = append(conditions, fmt.Sprintf("is_active = $%d", len(params)))
 	}
+	conditions, params = addTimeRange(conditions, params, "created_at", filter.CreatedAfter, filter.CreatedBefore)
 
-	return users, nil
+	return listPage(userList, conditions, params, opts, scanUser)
 }
 
 // GetUser retrieves a user by ID
@@ -0,0 +1,264 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"cct/utils"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const webhookColumns = "id, url, secret, event_types, novel_ids, enabled, created_at, updated_at"
+
+// scanWebhook scans a row selected with webhookColumns
+func scanWebhook(row rowScanner) (Webhook, error) {
+	var h Webhook
+	err := row.Scan(&h.ID, &h.URL, &h.Secret, pq.Array(&h.EventTypes), pq.Array(&h.NovelIDs),
+		&h.Enabled, &h.CreatedAt, &h.UpdatedAt)
+	return h, err
+}
+
+// queryWebhooks runs a query selecting webhookColumns
+func queryWebhooks(query string, args ...interface{}) ([]Webhook, error) {
+	rows, err := utils.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		h, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook row: %w", err)
+		}
+		webhooks = append(webhooks, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook rows: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// GetWebhooks retrieves all webhooks
+func GetWebhooks() ([]Webhook, error) {
+	return queryWebhooks("SELECT " + webhookColumns + " FROM webhooks ORDER BY id")
+}
+
+// GetEventWebhooks retrieves the enabled webhooks subscribed to an event of a type about a
+// novel, novelID is 0 for events about no novel
+func GetEventWebhooks(eventType string, novelID int) ([]Webhook, error) {
+	return queryWebhooks(`
+		SELECT `+webhookColumns+`
+		FROM webhooks
+		WHERE enabled
+		  AND (cardinality(event_types) = 0 OR $1 = ANY(event_types))
+		  AND (cardinality(novel_ids) = 0 OR $2 = ANY(novel_ids))
+		ORDER BY id
+	`, eventType, novelID)
+}
+
+// GetWebhook retrieves a webhook by ID
+func GetWebhook(id int) (Webhook, error) {
+	h, err := scanWebhook(utils.DB.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = $1", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Webhook{}, fmt.Errorf("webhook with ID %d not found", id)
+		}
+		return Webhook{}, fmt.Errorf("failed to query webhook: %w", err)
+	}
+	return h, nil
+}
+
+// CreateWebhook creates a webhook, with a random secret when it has none
+func CreateWebhook(h *Webhook) error {
+	var err error
+	if h.Secret == "" {
+		h.Secret, err = GenerateToken()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = utils.DB.QueryRow(`
+		INSERT INTO webhooks (url, secret, event_types, novel_ids, enabled)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`, h.URL, h.Secret, pq.Array(h.EventTypes), pq.Array(h.NovelIDs), h.Enabled).Scan(&h.ID, &h.CreatedAt, &h.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return nil
+}
+
+// UpdateWebhook updates a webhook, its secret is kept when empty. Disabling it drops its
+// pending deliveries.
+func UpdateWebhook(h *Webhook) error {
+	err := utils.DB.QueryRow(`
+		UPDATE webhooks
+		SET url = $2, secret = COALESCE(NULLIF($3, ''), secret), event_types = $4, novel_ids = $5,
+		    enabled = $6, updated_at = $7
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`, h.ID, h.URL, h.Secret, pq.Array(h.EventTypes), pq.Array(h.NovelIDs), h.Enabled, time.Now()).Scan(&h.CreatedAt, &h.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("webhook with ID %d not found", h.ID)
+		}
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	// A disabled webhook drops its pending deliveries instead of sending them when enabled again
+	if !h.Enabled {
+		if _, err := utils.DB.Exec("DELETE FROM webhook_outbox WHERE webhook_id = $1", h.ID); err != nil {
+			return fmt.Errorf("failed to drop pending webhook deliveries: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeleteWebhook deletes a webhook and its deliveries
+func DeleteWebhook(id int) error {
+	if _, err := utils.DB.Exec("DELETE FROM webhooks WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// CreateWebhookDelivery logs a delivery attempt
+func CreateWebhookDelivery(d *WebhookDelivery) error {
+	err := utils.DB.QueryRow(`
+		INSERT INTO webhook_deliveries (webhook_id, delivery_id, event_type, attempt, status_code, error, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING id, created_at
+	`, d.WebhookID, d.DeliveryID, d.EventType, d.Attempt, d.StatusCode, d.Error, d.DurationMs).Scan(&d.ID, &d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to log webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// QueueWebhookDeliveries adds a delivery of an event to the outbox for each webhook, all
+// due now
+func QueueWebhookDeliveries(webhooks []Webhook, eventType string, payload []byte) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, h := range webhooks {
+		if _, err := tx.Exec(`
+			INSERT INTO webhook_outbox (webhook_id, delivery_id, event_type, payload)
+			VALUES ($1, $2, $3, $4)
+		`, h.ID, uuid.New(), eventType, payload); err != nil {
+			return fmt.Errorf("failed to queue webhook delivery: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ClaimWebhookDelivery takes the oldest due delivery of an enabled webhook from the outbox
+// and hides it from other claims until leaseUntil, when it is retried if it was neither
+// deleted nor rescheduled. It returns nil when no delivery is due.
+func ClaimWebhookDelivery(leaseUntil time.Time) (*WebhookOutboxEntry, error) {
+	var e WebhookOutboxEntry
+	err := utils.DB.QueryRow(`
+		UPDATE webhook_outbox o
+		SET next_attempt_at = $2
+		FROM webhooks h
+		WHERE o.id = (
+			SELECT p.id FROM webhook_outbox p
+			JOIN webhooks w ON w.id = p.webhook_id
+			WHERE p.next_attempt_at <= $1 AND w.enabled
+			ORDER BY p.next_attempt_at, p.id
+			LIMIT 1
+			FOR UPDATE OF p SKIP LOCKED
+		) AND h.id = o.webhook_id
+		RETURNING o.id, o.webhook_id, h.url, h.secret, o.delivery_id, o.event_type, o.payload, o.attempt
+	`, time.Now(), leaseUntil).Scan(&e.ID, &e.WebhookID, &e.URL, &e.Secret, &e.DeliveryID, &e.EventType, &e.Payload, &e.Attempt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim webhook delivery: %w", err)
+	}
+	return &e, nil
+}
+
+// RescheduleWebhookDelivery sets the attempt number and time of the next attempt of a
+// delivery in the outbox
+func RescheduleWebhookDelivery(id int64, attempt int, at time.Time) error {
+	if _, err := utils.DB.Exec(`
+		UPDATE webhook_outbox SET attempt = $2, next_attempt_at = $3 WHERE id = $1
+	`, id, attempt, at); err != nil {
+		return fmt.Errorf("failed to reschedule webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// DeleteWebhookOutboxEntry removes a delivery from the outbox after its last attempt
+func DeleteWebhookOutboxEntry(id int64) error {
+	if _, err := utils.DB.Exec("DELETE FROM webhook_outbox WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete webhook delivery from the outbox: %w", err)
+	}
+	return nil
+}
+
+// GetWebhookDeliveries retrieves the last delivery attempts of a webhook, newest first
+func GetWebhookDeliveries(webhookID, limit int) ([]WebhookDelivery, error) {
+	rows, err := utils.DB.Query(`
+		SELECT id, webhook_id, delivery_id, event_type, attempt, status_code, COALESCE(error, ''),
+		       duration_ms, created_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		var statusCode sql.NullInt64
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.DeliveryID, &d.EventType, &d.Attempt, &statusCode,
+			&d.Error, &d.DurationMs, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		if statusCode.Valid {
+			code := int(statusCode.Int64)
+			d.StatusCode = &code
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// DeleteExpiredWebhookDeliveries deletes the delivery attempts older than ttl
+func DeleteExpiredWebhookDeliveries(ttl time.Duration) (int64, error) {
+	result, err := utils.DB.Exec("DELETE FROM webhook_deliveries WHERE created_at < $1", time.Now().Add(-ttl))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired webhook deliveries: %w", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,285 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"cct/utils"
+)
+
+var (
+	// ErrDomainTaken is returned when adding a domain that belongs to a website already
+	ErrDomainTaken = errors.New("domain already in use")
+	// ErrDomainNotFound is returned for a domain that is not a domain of the website
+	ErrDomainNotFound = errors.New("domain not found")
+	// ErrPrimaryDomain is returned when removing the primary domain of a website
+	ErrPrimaryDomain = errors.New("primary domain can't be removed")
+)
+
+// NormalizeDomain returns the lowercased host of a domain, a host with a port or a URL
+func NormalizeDomain(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain: %w", err)
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return "", errors.New("invalid domain: empty host")
+	}
+	return host, nil
+}
+
+// GetWebsiteDomains retrieves the domains of a website, the primary domain first
+func GetWebsiteDomains(websiteID int) ([]WebsiteDomain, error) {
+	rows, err := utils.DB.Query(`
+		SELECT id, website_id, domain, is_primary, created_at
+		FROM website_domains
+		WHERE website_id = $1
+		ORDER BY is_primary DESC, domain
+	`, websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query website domains: %w", err)
+	}
+	defer rows.Close()
+
+	domains := []WebsiteDomain{}
+	for rows.Next() {
+		var d WebsiteDomain
+		if err := rows.Scan(&d.ID, &d.WebsiteID, &d.Domain, &d.IsPrimary, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan website domain: %w", err)
+		}
+		domains = append(domains, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating website domains: %w", err)
+	}
+
+	return domains, nil
+}
+
+// AddWebsiteDomain adds a domain to a website. A primary domain replaces the previous one,
+// which is kept as an alias.
+func AddWebsiteDomain(d *WebsiteDomain) error {
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := addWebsiteDomain(tx, d); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit website domain: %w", err)
+	}
+	return nil
+}
+
+// addWebsiteDomain adds a domain to a website in tx
+func addWebsiteDomain(tx *sql.Tx, d *WebsiteDomain) error {
+	if d.IsPrimary {
+		if _, err := tx.Exec("UPDATE website_domains SET is_primary = false WHERE website_id = $1", d.WebsiteID); err != nil {
+			return fmt.Errorf("failed to update primary domain: %w", err)
+		}
+	}
+
+	err := tx.QueryRow(`
+		INSERT INTO website_domains (website_id, domain, is_primary)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (domain) DO NOTHING
+		RETURNING id, created_at
+	`, d.WebsiteID, d.Domain, d.IsPrimary).Scan(&d.ID, &d.CreatedAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: %s", ErrDomainTaken, d.Domain)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add website domain: %w", err)
+	}
+	return nil
+}
+
+// DeleteWebsiteDomain removes an alias of a website
+func DeleteWebsiteDomain(websiteID int, domain string) error {
+	result, err := utils.DB.Exec(`
+		DELETE FROM website_domains
+		WHERE website_id = $1 AND domain = $2 AND NOT is_primary
+	`, websiteID, domain)
+	if err != nil {
+		return fmt.Errorf("failed to delete website domain: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	// Nothing was deleted, tell a primary domain from a missing one
+	var primary bool
+	err = utils.DB.QueryRow(`
+		SELECT is_primary FROM website_domains WHERE website_id = $1 AND domain = $2
+	`, websiteID, domain).Scan(&primary)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: %s", ErrDomainNotFound, domain)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query website domain: %w", err)
+	}
+	return fmt.Errorf("%w: %s", ErrPrimaryDomain, domain)
+}
+
+// MoveWebsiteDomain moves a website from one of its domains to a new primary domain. The
+// stored URLs of its novels and chapters on the old domain are rewritten to the new one,
+// the old domain is kept as an alias so URLs still sent on it are canonicalized.
+func MoveWebsiteDomain(websiteID int, from, to string) (DomainMove, error) {
+	move := DomainMove{From: from, To: to}
+
+	tx, err := utils.DB.Begin()
+	if err != nil {
+		return move, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var owner int
+	err = tx.QueryRow("SELECT website_id FROM website_domains WHERE domain = $1 FOR UPDATE", from).Scan(&owner)
+	if err == sql.ErrNoRows || (err == nil && owner != websiteID) {
+		return move, fmt.Errorf("%w: %s", ErrDomainNotFound, from)
+	}
+	if err != nil {
+		return move, fmt.Errorf("failed to query website domain: %w", err)
+	}
+
+	// The new domain may have been added as an alias already
+	err = tx.QueryRow("SELECT website_id FROM website_domains WHERE domain = $1 FOR UPDATE", to).Scan(&owner)
+	switch {
+	case err == sql.ErrNoRows:
+		if err := addWebsiteDomain(tx, &WebsiteDomain{WebsiteID: websiteID, Domain: to}); err != nil {
+			return move, err
+		}
+	case err != nil:
+		return move, fmt.Errorf("failed to query website domain: %w", err)
+	case owner != websiteID:
+		return move, fmt.Errorf("%w: %s", ErrDomainTaken, to)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE website_domains SET is_primary = (domain = $2) WHERE website_id = $1
+	`, websiteID, to)
+	if err != nil {
+		return move, fmt.Errorf("failed to update primary domain: %w", err)
+	}
+
+	// Matches the scheme and host of a URL on the old domain, with its port if any
+	pattern := `^([a-z][a-z0-9+.-]*://)` + regexp.QuoteMeta(from) + `(:[0-9]+)?(?=[/?#]|$)`
+	replacement := `\1` + to
+
+	if _, err := tx.Exec(`
+		UPDATE websites SET base_url = regexp_replace(base_url, $2, $3, 'i')
+		WHERE id = $1 AND base_url ~* $2
+	`, websiteID, pattern, replacement); err != nil {
+		return move, fmt.Errorf("failed to move website base URL: %w", err)
+	}
+
+	result, err := tx.Exec(`
+		UPDATE novels SET source_url = regexp_replace(source_url, $2, $3, 'i')
+		WHERE website_id = $1 AND source_url ~* $2
+	`, websiteID, pattern, replacement)
+	if err != nil {
+		return move, fmt.Errorf("failed to move novel URLs: %w", err)
+	}
+	if move.Novels, err = result.RowsAffected(); err != nil {
+		return move, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	result, err = tx.Exec(`
+		UPDATE chapters c SET url = regexp_replace(c.url, $2, $3, 'i')
+		FROM novels n
+		WHERE n.id = c.novel_id AND n.website_id = $1 AND c.url ~* $2
+	`, websiteID, pattern, replacement)
+	if err != nil {
+		return move, fmt.Errorf("failed to move chapter URLs: %w", err)
+	}
+	if move.Chapters, err = result.RowsAffected(); err != nil {
+		return move, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return move, fmt.Errorf("failed to commit domain move: %w", err)
+	}
+	return move, nil
+}
+
+// websiteHost is the website of a domain and the primary domain URLs on it are stored on
+type websiteHost struct {
+	websiteID int
+	primary   string
+}
+
+// Domains canonicalizes URLs on the domains of the websites, see LoadDomains
+type Domains struct {
+	hosts map[string]websiteHost
+}
+
+// LoadDomains loads the domains of every website
+func LoadDomains() (*Domains, error) {
+	rows, err := utils.DB.Query(`
+		SELECT d.website_id, d.domain, COALESCE(p.domain, d.domain)
+		FROM website_domains d
+		LEFT JOIN website_domains p ON p.website_id = d.website_id AND p.is_primary
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query website domains: %w", err)
+	}
+	defer rows.Close()
+
+	d := &Domains{hosts: map[string]websiteHost{}}
+	for rows.Next() {
+		var domain string
+		var h websiteHost
+		if err := rows.Scan(&h.websiteID, &domain, &h.primary); err != nil {
+			return nil, fmt.Errorf("failed to scan website domain: %w", err)
+		}
+		d.hosts[domain] = h
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating website domains: %w", err)
+	}
+
+	return d, nil
+}
+
+// lookup returns the website of the host of a URL. A nil Domains has no domains.
+func (d *Domains) lookup(rawURL string) (*url.URL, websiteHost, bool) {
+	if d == nil {
+		return nil, websiteHost{}, false
+	}
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return nil, websiteHost{}, false
+	}
+	h, ok := d.hosts[strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")]
+	return u, h, ok
+}
+
+// WebsiteID returns the ID of the website a URL is on
+func (d *Domains) WebsiteID(rawURL string) (int, bool) {
+	_, h, ok := d.lookup(rawURL)
+	return h.websiteID, ok
+}
+
+// Canonicalize returns a URL on a domain of a website on its primary domain, other URLs
+// are returned unchanged
+func (d *Domains) Canonicalize(rawURL string) string {
+	u, h, ok := d.lookup(rawURL)
+	if !ok {
+		return rawURL
+	}
+	u.Host = h.primary
+	return u.String()
+}
@@ -7,6 +7,42 @@ import (
 	"cct/utils"
 )
 
+// websiteList lists websites, by ID by default
+var websiteList = listSpec{
+	Name:    "websites",
+	From:    "websites",
+	Columns: "id, name, base_url, script_name, crawl_interval, enabled, created_at, username, password",
+	ID:      "id",
+	IDType:  "integer",
+	Sorts: map[string]sortField{
+		"id":         {Expr: "id", Type: "integer"},
+		"name":       {Expr: "name", Type: "text"},
+		"created_at": {Expr: "COALESCE(created_at, '-infinity')", Type: "timestamp"},
+	},
+	DefaultSort: "id",
+}
+
+// scanWebsite scans a row selected with the websiteList columns
+func scanWebsite(row rowScanner) (Website, error) {
+	var w Website
+	err := row.Scan(&w.ID, &w.Name, &w.BaseURL, &w.ScriptName, &w.CrawlInterval, &w.Enabled, &w.CreatedAt, &w.Username, &w.Password)
+	return w, err
+}
+
+// ListWebsites retrieves a page of the websites matching the filter
+func ListWebsites(filter WebsiteFilter, opts ListOptions) (*Page[Website], error) {
+	params := []interface{}{}
+	conditions := []string{}
+
+	if filter.Enabled != nil {
+		params = append(params, *filter.Enabled)
+		conditions = append(conditions, fmt.Sprintf("enabled = $%d", len(params)))
+	}
+	conditions, params = addTimeRange(conditions, params, "created_at", filter.CreatedAfter, filter.CreatedBefore)
+
+	return listPage(websiteList, conditions, params, opts, scanWebsite)
+}
+
 // GetWebsites retrieves all websites from the database
 func GetWebsites() ([]Website, error) {
 	rows, err := utils.DB.Query(`
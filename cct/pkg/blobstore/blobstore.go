@@ -0,0 +1,41 @@
+package blobstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"cct/config"
+)
+
+// ErrNotFound is returned when a blob does not exist in the store
+var ErrNotFound = errors.New("blob not found")
+
+// Store is a content-addressed blob store. Blobs are keyed by the SHA-256 of their
+// content, so storing the same content twice keeps a single copy.
+type Store interface {
+	// Put stores data and returns its key
+	Put(data []byte) (string, error)
+	// Open opens the blob stored under key
+	Open(key string) (io.ReadSeekCloser, error)
+	// Exists reports whether a blob is stored under key
+	Exists(key string) (bool, error)
+}
+
+// New creates the blob store selected by the configuration
+func New(cfg *config.StorageConfig) (Store, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocalStore(cfg.LocalPath)
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.Driver)
+	}
+}
+
+// Key returns the key data is stored under
+func Key(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,195 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cct/pkg/logger"
+)
+
+// Event types
+const (
+	TypeChapterUpdated   = "chapter.updated"
+	TypeNovelCompleted   = "novel.completed"
+	TypeAgentUpdated     = "agent.updated"
+	TypeWebsiteUpdated   = "website.updated"
+	TypeNovelCreated     = "novel.created"
+	TypeChapterAdded     = "chapter.added"
+	TypeTaskFailed       = "task.failed"
+	TypeWebsiteUnhealthy = "website.unhealthy"
+	TypeTaskPublished    = "task.published"
+	TypeTaskStarted      = "task.started"
+	TypeTaskFinished     = "task.finished"
+)
+
+// Types are all the event types
+var Types = []string{
+	TypeChapterUpdated,
+	TypeNovelCompleted,
+	TypeAgentUpdated,
+	TypeWebsiteUpdated,
+	TypeNovelCreated,
+	TypeChapterAdded,
+	TypeTaskFailed,
+	TypeWebsiteUnhealthy,
+	TypeTaskPublished,
+	TypeTaskStarted,
+	TypeTaskFinished,
+}
+
+// Event is something that happened in the crawler, published to every subscriber
+type Event struct {
+	ID        uint64    `json:"id"`
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	NovelID   int       `json:"novel_id,omitempty"`
+	WebsiteID int       `json:"website_id,omitempty"`
+	AgentID   string    `json:"agent_id,omitempty"`
+	Data      any       `json:"data,omitempty"`
+}
+
+// ChapterUpdated is the data of a chapter.updated event, sent when a recrawl
+// replaces the content of a chapter. Lengths are in characters.
+type ChapterUpdated struct {
+	ChapterID   int    `json:"chapter_id"`
+	URL         string `json:"url"`
+	OldHash     string `json:"old_hash"`
+	NewHash     string `json:"new_hash"`
+	OldLength   int    `json:"old_length"`
+	NewLength   int    `json:"new_length"`
+	LengthDelta int    `json:"length_delta"`
+}
+
+// NovelCompleted is the data of a novel.completed event, sent when every chapter of a
+// completed novel has content and its schedules were wound down
+type NovelCompleted struct {
+	ScheduleIDs []int `json:"schedule_ids"`
+	// IntervalSeconds is the new interval of the schedules, 0 when they were disabled
+	IntervalSeconds int `json:"interval_seconds"`
+}
+
+// AgentUpdated is the data of an agent.updated event, sent when an agent is changed or
+// deleted through the API
+type AgentUpdated struct {
+	IsActive bool `json:"is_active"`
+	Deleted  bool `json:"deleted,omitempty"`
+}
+
+// NovelCreated is the data of a novel.created event, sent when a book crawl or the API
+// creates a novel
+type NovelCreated struct {
+	Title     string `json:"title"`
+	SourceURL string `json:"source_url"`
+}
+
+// ChapterAdded is the data of a chapter.added event, sent with the chapters a book crawl
+// or the API added to a novel
+type ChapterAdded struct {
+	Chapters []AddedChapter `json:"chapters"`
+}
+
+// AddedChapter is a chapter of a chapter.added event
+type AddedChapter struct {
+	ChapterID int    `json:"chapter_id"`
+	Title     string `json:"title"`
+	URL       string `json:"url"`
+	Sequence  int    `json:"sequence"`
+}
+
+// Task is the data of the task.published, task.started, task.finished and task.failed
+// events, TaskID is the ID the task was published with.
+type Task struct {
+	TaskID   string `json:"task_id,omitempty"`
+	TaskType string `json:"task_type"`
+	Source   string `json:"source"`
+	URL      string `json:"url"`
+	// Error is why a task failed
+	Error string `json:"error,omitempty"`
+}
+
+// WebsiteUnhealthy is the data of a website.unhealthy event, sent once when the tasks of a
+// website keep failing, again only after one of its tasks succeeded
+type WebsiteUnhealthy struct {
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	LastError           string `json:"last_error"`
+}
+
+// DefaultBuffer is the number of events buffered per subscriber
+const DefaultBuffer = 256
+
+var (
+	mu          sync.RWMutex
+	subscribers = map[*subscriber]struct{}{}
+	lastID      atomic.Uint64
+)
+
+type subscriber struct {
+	ch      chan Event
+	dropped atomic.Uint64
+	// types are the event types sent to the subscriber, all of them when empty
+	types map[string]bool
+}
+
+// Subscribe returns a channel receiving every event published from now on and a
+// function that unsubscribes and closes the channel. Events are dropped for a
+// subscriber whose buffer is full, so a slow subscriber never blocks publishers.
+func Subscribe(buffer int) (<-chan Event, func()) {
+	return SubscribeTypes(buffer)
+}
+
+// SubscribeTypes is like Subscribe but only receives the events of the given types, so
+// the events of other types never fill its buffer. No types receives every event.
+func SubscribeTypes(buffer int, types ...string) (<-chan Event, func()) {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	s := &subscriber{ch: make(chan Event, buffer)}
+	if len(types) > 0 {
+		s.types = make(map[string]bool, len(types))
+		for _, t := range types {
+			s.types[t] = true
+		}
+	}
+
+	mu.Lock()
+	subscribers[s] = struct{}{}
+	mu.Unlock()
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			mu.Lock()
+			delete(subscribers, s)
+			mu.Unlock()
+			close(s.ch)
+		})
+	}
+}
+
+// Publish sends an event to every subscriber without blocking.
+// ID and Time are set when they are empty.
+func Publish(e Event) {
+	if e.ID == 0 {
+		e.ID = lastID.Add(1)
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for s := range subscribers {
+		if s.types != nil && !s.types[e.Type] {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			if n := s.dropped.Add(1); n == 1 || n%100 == 0 {
+				logger.Warn().Str("type", e.Type).Uint64("dropped", n).Msg("Event subscriber is full, dropping events")
+			}
+		}
+	}
+}
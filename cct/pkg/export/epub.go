@@ -0,0 +1,282 @@
+package export
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"cct/models"
+	"cct/pkg/render"
+
+	"github.com/google/uuid"
+)
+
+// epubLanguage is the language of the crawled novels
+const epubLanguage = "vi"
+
+// epubStyle is the stylesheet shared by every document of an EPUB
+const epubStyle = `body { font-family: serif; line-height: 1.5; margin: 0 1em; }
+h1 { font-size: 1.4em; margin: 1.5em 0 1em; text-align: center; }
+p { margin: 0 0 0.8em; text-indent: 1.5em; }
+.cover { margin: 0; padding: 0; text-align: center; }
+.cover img { max-width: 100%; max-height: 100%; }
+.missing td, .missing th { padding: 0.2em 0.5em; text-align: left; vertical-align: top; }
+`
+
+// epubItem is a content document listed in the manifest, spine and table of contents
+type epubItem struct {
+	ID    string
+	Href  string
+	Title string
+}
+
+// WriteEPUB writes book to w as an EPUB 3 with a table of contents. Chapters without
+// content are listed in an appendix instead of being included.
+func WriteEPUB(w io.Writer, book *Book) (*Stats, error) {
+	zw := zip.NewWriter(w)
+	stats := &Stats{}
+
+	// The mimetype must come first and be stored uncompressed
+	mimetype, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(mimetype, "application/epub+zip"); err != nil {
+		return nil, err
+	}
+
+	if err := writeZipFile(zw, "META-INF/container.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`); err != nil {
+		return nil, err
+	}
+	if err := writeZipFile(zw, "OEBPS/style.css", epubStyle); err != nil {
+		return nil, err
+	}
+
+	title := book.Novel.Title
+	var items []epubItem
+	var coverHref, coverType string
+
+	if book.Cover != nil && len(book.Cover.Data) > 0 {
+		coverType = book.Cover.ContentType
+		coverHref = "images/cover" + imageExtension(coverType)
+		if err := writeZipBytes(zw, "OEBPS/"+coverHref, book.Cover.Data); err != nil {
+			return nil, err
+		}
+		body := `<div class="cover"><img src="` + coverHref + `" alt="` + xmlEscape(title) + `"/></div>`
+		if err := writeZipFile(zw, "OEBPS/cover.xhtml", xhtmlDocument(title, body, "cover", "")); err != nil {
+			return nil, err
+		}
+		items = append(items, epubItem{ID: "cover", Href: "cover.xhtml", Title: "Bìa"})
+	}
+
+	err = book.Chapters(func(c models.Chapter) error {
+		if IsMissing(c) {
+			stats.Missing = append(stats.Missing, c)
+			return nil
+		}
+
+		body, err := render.Chapter(c.Content, c.RichContent, c.ContentFormat, render.FormatHTML)
+		if err != nil {
+			return fmt.Errorf("failed to render chapter %d: %w", c.Sequence, err)
+		}
+		if body, err = render.XHTML(body); err != nil {
+			return fmt.Errorf("failed to render chapter %d: %w", c.Sequence, err)
+		}
+
+		stats.Chapters++
+		item := epubItem{
+			ID:    fmt.Sprintf("chapter-%05d", stats.Chapters),
+			Href:  fmt.Sprintf("text/chapter-%05d.xhtml", stats.Chapters),
+			Title: ChapterTitle(c),
+		}
+		doc := xhtmlDocument(item.Title, "<h1>"+xmlEscape(item.Title)+"</h1>\n"+validXML(body), "", "../")
+		if err := writeZipFile(zw, "OEBPS/"+item.Href, doc); err != nil {
+			return err
+		}
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stats.Missing) > 0 {
+		item := epubItem{ID: "appendix", Href: "appendix.xhtml", Title: appendixTitle}
+		if err := writeZipFile(zw, "OEBPS/"+item.Href, xhtmlDocument(item.Title, missingChaptersTable(item.Title, stats.Missing), "", "")); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if err := writeZipFile(zw, "OEBPS/nav.xhtml", navDocument(title, items)); err != nil {
+		return nil, err
+	}
+	if err := writeZipFile(zw, "OEBPS/content.opf", packageDocument(book, items, coverHref, coverType)); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// writeZipFile writes a compressed text file to the archive
+func writeZipFile(zw *zip.Writer, name, content string) error {
+	return writeZipBytes(zw, name, []byte(content))
+}
+
+// writeZipBytes writes a compressed file to the archive
+func writeZipBytes(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// xhtmlDocument wraps body in an XHTML content document, root is the relative path
+// from the document to the OEBPS directory
+func xhtmlDocument(title, body, bodyClass, root string) string {
+	class := ""
+	if bodyClass != "" {
+		class = ` class="` + bodyClass + `"`
+	}
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="` + epubLanguage + `" xml:lang="` + epubLanguage + `">
+<head>
+<meta charset="UTF-8"/>
+<title>` + xmlEscape(title) + `</title>
+<link rel="stylesheet" type="text/css" href="` + root + `style.css"/>
+</head>
+<body` + class + `>
+` + body + `
+</body>
+</html>
+`
+}
+
+// missingChaptersTable lists the chapters without content with their source URL and last error
+func missingChaptersTable(title string, missing []models.Chapter) string {
+	var b strings.Builder
+	b.WriteString("<h1>" + xmlEscape(title) + "</h1>\n")
+	b.WriteString("<table class=\"missing\">\n<tr><th>#</th><th>Chương</th><th>Lỗi</th></tr>\n")
+	for _, c := range missing {
+		fmt.Fprintf(&b, "<tr><td>%d</td><td><a href=\"%s\">%s</a></td><td>%s</td></tr>\n",
+			c.Sequence, xmlEscape(c.URL), xmlEscape(ChapterTitle(c)), xmlEscape(MissingReason(c)))
+	}
+	b.WriteString("</table>")
+	return b.String()
+}
+
+// navDocument builds the EPUB 3 navigation document
+func navDocument(title string, items []epubItem) string {
+	var b strings.Builder
+	b.WriteString(`<nav epub:type="toc" id="toc">` + "\n<h1>Mục lục</h1>\n<ol>\n")
+	for _, item := range items {
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", item.Href, xmlEscape(item.Title))
+	}
+	b.WriteString("</ol>\n</nav>")
+	return xhtmlDocument(title, b.String(), "", "")
+}
+
+// packageDocument builds the package document with the novel metadata, the manifest and the spine
+func packageDocument(book *Book, items []epubItem, coverHref, coverType string) string {
+	novel := book.Novel
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="` + epubLanguage + `">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	fmt.Fprintf(&b, "<dc:identifier id=\"book-id\">urn:uuid:%s</dc:identifier>\n", bookID(novel))
+	fmt.Fprintf(&b, "<dc:title>%s</dc:title>\n", xmlEscape(novel.Title))
+	fmt.Fprintf(&b, "<dc:language>%s</dc:language>\n", epubLanguage)
+	if novel.Author != "" {
+		fmt.Fprintf(&b, "<dc:creator>%s</dc:creator>\n", xmlEscape(novel.Author))
+	}
+	if novel.Description != "" {
+		fmt.Fprintf(&b, "<dc:description>%s</dc:description>\n", xmlEscape(novel.Description))
+	}
+	for _, genre := range novel.Genres {
+		fmt.Fprintf(&b, "<dc:subject>%s</dc:subject>\n", xmlEscape(genre))
+	}
+	if novel.SourceURL != "" {
+		fmt.Fprintf(&b, "<dc:source>%s</dc:source>\n", xmlEscape(novel.SourceURL))
+	}
+	fmt.Fprintf(&b, "<meta property=\"dcterms:modified\">%s</meta>\n", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+	if coverHref != "" {
+		b.WriteString("<meta name=\"cover\" content=\"cover-image\"/>\n")
+	}
+	b.WriteString("</metadata>\n<manifest>\n")
+
+	b.WriteString("<item id=\"nav\" href=\"nav.xhtml\" media-type=\"application/xhtml+xml\" properties=\"nav\"/>\n")
+	b.WriteString("<item id=\"style\" href=\"style.css\" media-type=\"text/css\"/>\n")
+	if coverHref != "" {
+		fmt.Fprintf(&b, "<item id=\"cover-image\" href=\"%s\" media-type=\"%s\" properties=\"cover-image\"/>\n", coverHref, xmlEscape(coverType))
+	}
+	for _, item := range items {
+		fmt.Fprintf(&b, "<item id=\"%s\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", item.ID, item.Href)
+	}
+
+	b.WriteString("</manifest>\n<spine>\n")
+	for _, item := range items {
+		fmt.Fprintf(&b, "<itemref idref=\"%s\"/>\n", item.ID)
+	}
+	b.WriteString("</spine>\n</package>\n")
+	return b.String()
+}
+
+// bookID returns a stable identifier for the novel, so re-exports replace each other in readers
+func bookID(novel models.Novel) uuid.UUID {
+	name := novel.SourceURL
+	if name == "" {
+		name = "novel:" + strconv.Itoa(novel.ID)
+	}
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(name))
+}
+
+// imageExtension returns the file extension of an image content type
+func imageExtension(contentType string) string {
+	switch contentType {
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "image/svg+xml":
+		return ".svg"
+	}
+	return ".jpg"
+}
+
+// xmlEscape escapes text for XML content and attributes
+func xmlEscape(s string) string {
+	return html.EscapeString(validXML(s))
+}
+
+// validXML drops the characters that are not allowed in XML documents
+func validXML(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			return r
+		case r < 0x20, r == 0xFFFE, r == 0xFFFF:
+			return -1
+		}
+		return r
+	}, s)
+}
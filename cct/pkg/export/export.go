@@ -0,0 +1,132 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"cct/models"
+)
+
+// Export formats
+const (
+	FormatEPUB     = "epub"
+	FormatText     = "txt"
+	FormatMarkdown = "md"
+	FormatZIP      = "zip"
+)
+
+// Cover is the cover image of an exported novel
+type Cover struct {
+	ContentType string
+	Data        []byte
+}
+
+// Book is a novel to export. Chapters calls its function with every chapter to
+// export in reading order, so chapters are streamed instead of loaded at once.
+type Book struct {
+	Novel    models.Novel
+	Cover    *Cover
+	Chapters func(fn func(models.Chapter) error) error
+}
+
+// Stats describes what was written by an export
+type Stats struct {
+	// Chapters is the number of chapters with content
+	Chapters int
+	// Missing lists the chapters without content, listed in an appendix
+	Missing []models.Chapter
+}
+
+// ParseFormat returns the export format named by s
+func ParseFormat(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case "", FormatEPUB:
+		return FormatEPUB, nil
+	case FormatText, "text":
+		return FormatText, nil
+	case FormatMarkdown, "markdown":
+		return FormatMarkdown, nil
+	case FormatZIP:
+		return FormatZIP, nil
+	}
+	return "", fmt.Errorf("unsupported export format: %s", s)
+}
+
+// ContentType returns the media type of an export format
+func ContentType(format string) string {
+	switch format {
+	case FormatEPUB:
+		return "application/epub+zip"
+	case FormatText:
+		return "text/plain; charset=utf-8"
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatZIP:
+		return "application/zip"
+	}
+	return "application/octet-stream"
+}
+
+// FileName returns the download name of an export of novel
+func FileName(novel models.Novel, format string) string {
+	name := slugReplacer.Replace(strings.TrimSpace(novel.Title))
+	if name == "" {
+		name = "novel-" + strconv.Itoa(novel.ID)
+	}
+	return name + "." + format
+}
+
+// slugReplacer removes the characters that are not allowed in file names
+var slugReplacer = strings.NewReplacer(
+	"/", "-", `\`, "-", ":", "-", "*", "", "?", "", `"`, "", "<", "", ">", "", "|", "", "\n", " ", "\r", "",
+)
+
+// Write writes book to w in format
+func Write(w io.Writer, format string, book *Book) (*Stats, error) {
+	switch format {
+	case FormatEPUB:
+		return WriteEPUB(w, book)
+	case FormatText:
+		return WriteText(w, book)
+	case FormatMarkdown:
+		return WriteMarkdown(w, book)
+	case FormatZIP:
+		return WriteZIP(w, book)
+	}
+	return nil, fmt.Errorf("unsupported export format: %s", format)
+}
+
+// IsMissing reports whether a chapter has no content to export, because it was never
+// crawled or its last crawl failed
+func IsMissing(c models.Chapter) bool {
+	return strings.TrimSpace(c.Content) == "" && strings.TrimSpace(c.RichContent) == ""
+}
+
+// appendixTitle is the title of the appendix listing the missing chapters
+const appendixTitle = "Phụ lục: chương còn thiếu"
+
+// MissingReason returns why a chapter has no content
+func MissingReason(c models.Chapter) string {
+	if c.Error != "" {
+		return c.Error
+	}
+	return "Chưa tải"
+}
+
+// VolumeTitle returns the heading of a volume, empty for chapters without volume
+func VolumeTitle(volume int) string {
+	if volume <= 0 {
+		return ""
+	}
+	return "Quyển " + strconv.Itoa(volume)
+}
+
+// ChapterTitle returns the title of a chapter, or its number when it has none
+func ChapterTitle(c models.Chapter) string {
+	if title := strings.TrimSpace(c.Title); title != "" {
+		return title
+	}
+	return "Chương " + strconv.Itoa(c.ChapterNumber)
+}
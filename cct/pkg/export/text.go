@@ -0,0 +1,140 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"cct/models"
+	"cct/pkg/render"
+)
+
+// WriteText writes book to w as one plain text document, chapters are separated by
+// their title and preceded by their volume when it changes. Chapters without content
+// are listed at the end.
+func WriteText(w io.Writer, book *Book) (*Stats, error) {
+	bw := bufio.NewWriter(w)
+	stats := &Stats{}
+	novel := book.Novel
+
+	fmt.Fprintf(bw, "%s\n", novel.Title)
+	if novel.Author != "" {
+		fmt.Fprintf(bw, "Tác giả: %s\n", novel.Author)
+	}
+	if len(novel.Genres) > 0 {
+		fmt.Fprintf(bw, "Thể loại: %s\n", strings.Join(novel.Genres, ", "))
+	}
+	if novel.SourceURL != "" {
+		fmt.Fprintf(bw, "Nguồn: %s\n", novel.SourceURL)
+	}
+	if description := strings.TrimSpace(novel.Description); description != "" {
+		fmt.Fprintf(bw, "\n%s\n", description)
+	}
+
+	volume := 0
+	err := book.Chapters(func(c models.Chapter) error {
+		if IsMissing(c) {
+			stats.Missing = append(stats.Missing, c)
+			return nil
+		}
+
+		text, err := render.Chapter(c.Content, c.RichContent, c.ContentFormat, render.FormatText)
+		if err != nil {
+			return fmt.Errorf("failed to render chapter %d: %w", c.Sequence, err)
+		}
+
+		if c.Volume != volume {
+			volume = c.Volume
+			if title := VolumeTitle(volume); title != "" {
+				fmt.Fprintf(bw, "\n\n\n%s\n", strings.ToUpper(title))
+			}
+		}
+
+		stats.Chapters++
+		_, err = fmt.Fprintf(bw, "\n\n%s\n\n%s\n", ChapterTitle(c), strings.TrimSpace(text))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stats.Missing) > 0 {
+		fmt.Fprintf(bw, "\n\n%s\n\n", appendixTitle)
+		for _, c := range stats.Missing {
+			fmt.Fprintf(bw, "%d. %s - %s - %s\n", c.Sequence, ChapterTitle(c), c.URL, MissingReason(c))
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// WriteMarkdown writes book to w as one Markdown document with a heading per chapter and
+// volume, using the structured chapter content when available. Chapters without content are
+// listed in an appendix.
+func WriteMarkdown(w io.Writer, book *Book) (*Stats, error) {
+	bw := bufio.NewWriter(w)
+	stats := &Stats{}
+	novel := book.Novel
+
+	fmt.Fprintf(bw, "# %s\n\n", markdownLine(novel.Title))
+	if novel.Author != "" {
+		fmt.Fprintf(bw, "- Tác giả: %s\n", markdownLine(novel.Author))
+	}
+	if len(novel.Genres) > 0 {
+		fmt.Fprintf(bw, "- Thể loại: %s\n", markdownLine(strings.Join(novel.Genres, ", ")))
+	}
+	if novel.SourceURL != "" {
+		fmt.Fprintf(bw, "- Nguồn: <%s>\n", novel.SourceURL)
+	}
+	if description := strings.TrimSpace(novel.Description); description != "" {
+		fmt.Fprintf(bw, "\n%s", render.TextToMarkdown(description))
+	}
+
+	volume := 0
+	err := book.Chapters(func(c models.Chapter) error {
+		if IsMissing(c) {
+			stats.Missing = append(stats.Missing, c)
+			return nil
+		}
+
+		text, err := render.Chapter(c.Content, c.RichContent, c.ContentFormat, render.FormatMarkdown)
+		if err != nil {
+			return fmt.Errorf("failed to render chapter %d: %w", c.Sequence, err)
+		}
+
+		if c.Volume != volume {
+			volume = c.Volume
+			if title := VolumeTitle(volume); title != "" {
+				fmt.Fprintf(bw, "\n# %s\n", title)
+			}
+		}
+
+		stats.Chapters++
+		_, err = fmt.Fprintf(bw, "\n## %s\n\n%s\n", markdownLine(ChapterTitle(c)), strings.TrimSpace(text))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stats.Missing) > 0 {
+		fmt.Fprintf(bw, "\n## %s\n\n", appendixTitle)
+		for _, c := range stats.Missing {
+			fmt.Fprintf(bw, "- #%d [%s](<%s>): %s\n", c.Sequence, markdownLine(ChapterTitle(c)), c.URL, markdownLine(MissingReason(c)))
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// markdownLine escapes a single line of text for Markdown
+func markdownLine(s string) string {
+	return strings.TrimSpace(render.TextToMarkdown(strings.Join(strings.Fields(s), " ")))
+}
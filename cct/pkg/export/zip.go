@@ -0,0 +1,137 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"cct/models"
+	"cct/pkg/render"
+)
+
+// zipMetadata is the metadata.json of a ZIP export
+type zipMetadata struct {
+	Novel      models.Novel `json:"novel"`
+	ExportedAt time.Time    `json:"exported_at"`
+	Cover      string       `json:"cover,omitempty"`
+	Chapters   []zipChapter `json:"chapters"`
+	Missing    []zipChapter `json:"missing"`
+}
+
+// zipChapter describes a chapter of a ZIP export
+type zipChapter struct {
+	ChapterNumber int        `json:"chapter_number"`
+	Sequence      int        `json:"sequence"`
+	Volume        int        `json:"volume,omitempty"`
+	Part          int        `json:"part,omitempty"`
+	Title         string     `json:"title"`
+	URL           string     `json:"url"`
+	File          string     `json:"file,omitempty"`
+	RichFile      string     `json:"rich_file,omitempty"`
+	ContentFormat string     `json:"content_format,omitempty"`
+	ContentHash   string     `json:"content_hash,omitempty"`
+	CrawledAt     *time.Time `json:"crawled_at,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// WriteZIP writes book to w as a ZIP with one plain text file per chapter, the rich
+// content next to it when the chapter has one, the cover and a metadata.json listing
+// the chapters and the missing ones
+func WriteZIP(w io.Writer, book *Book) (*Stats, error) {
+	zw := zip.NewWriter(w)
+	stats := &Stats{}
+	meta := zipMetadata{
+		Novel:      book.Novel,
+		ExportedAt: time.Now().UTC(),
+		Chapters:   []zipChapter{},
+		Missing:    []zipChapter{},
+	}
+
+	if book.Cover != nil && len(book.Cover.Data) > 0 {
+		meta.Cover = "cover" + imageExtension(book.Cover.ContentType)
+		if err := writeZipBytes(zw, meta.Cover, book.Cover.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	err := book.Chapters(func(c models.Chapter) error {
+		entry := zipChapter{
+			ChapterNumber: c.ChapterNumber,
+			Sequence:      c.Sequence,
+			Volume:        c.Volume,
+			Part:          c.Part,
+			Title:         ChapterTitle(c),
+			URL:           c.URL,
+			ContentHash:   c.ContentHash,
+			Error:         c.Error,
+		}
+		if c.CrawledAt.Valid {
+			entry.CrawledAt = &c.CrawledAt.Time
+		}
+
+		if IsMissing(c) {
+			entry.Error = MissingReason(c)
+			meta.Missing = append(meta.Missing, entry)
+			stats.Missing = append(stats.Missing, c)
+			return nil
+		}
+
+		text, err := render.Chapter(c.Content, c.RichContent, c.ContentFormat, render.FormatText)
+		if err != nil {
+			return fmt.Errorf("failed to render chapter %d: %w", c.Sequence, err)
+		}
+
+		stats.Chapters++
+		name := fmt.Sprintf("chapters/%05d", stats.Chapters)
+		entry.File = name + ".txt"
+		if err := writeZipFile(zw, entry.File, entry.Title+"\n\n"+text+"\n"); err != nil {
+			return err
+		}
+
+		if c.RichContent != "" {
+			rich := c.RichContent
+			if c.ContentFormat == render.FormatHTML {
+				if rich, err = render.SanitizeHTML(rich); err != nil {
+					return fmt.Errorf("failed to sanitize chapter %d: %w", c.Sequence, err)
+				}
+			}
+			entry.ContentFormat = c.ContentFormat
+			entry.RichFile = name + richExtension(c.ContentFormat)
+			if err := writeZipFile(zw, entry.RichFile, rich); err != nil {
+				return err
+			}
+		}
+
+		meta.Chapters = append(meta.Chapters, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeZipBytes(zw, "metadata.json", data); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// richExtension returns the file extension of rich chapter content
+func richExtension(format string) string {
+	switch format {
+	case models.ContentFormatHTML:
+		return ".html"
+	case models.ContentFormatMarkdown:
+		return ".md"
+	}
+	return ".rich.txt"
+}
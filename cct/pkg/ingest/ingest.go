@@ -0,0 +1,110 @@
+package ingest
+
+import (
+	"database/sql"
+	"time"
+
+	"cct/models"
+	"cct/pkg/logger"
+
+	"github.com/zrik/protocol"
+)
+
+// Result is a stored book
+type Result struct {
+	// Novel is the novel as sent by the source, with its ID
+	Novel models.Novel
+	// Chapters are the listed chapters with their IDs
+	Chapters []models.Chapter
+	Summary  models.BookIngest
+}
+
+// Store stores the novel and chapter list of a book result in one transaction, nothing
+// is stored when it fails. New novels are created on the website websiteID. The novel and
+// chapter URLs are stored canonicalized by domains.
+func Store(websiteID int, book protocol.Book, domains *models.Domains) (*Result, error) {
+	start := time.Now()
+
+	novel := bookNovel(book, websiteID)
+	novel.SourceURL = domains.Canonicalize(novel.SourceURL)
+	chapters := make([]models.Chapter, 0, len(book.Chapters))
+	for i, c := range book.Chapters {
+		chapter := listedChapter(c, i)
+		chapter.URL = domains.Canonicalize(chapter.URL)
+		chapters = append(chapters, chapter)
+	}
+
+	summary, err := models.IngestBook(&novel, chapters)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info().
+		Int("novel_id", summary.NovelID).
+		Bool("created", summary.Created).
+		Int("added", summary.Chapters.Added).
+		Int("updated", summary.Chapters.Updated).
+		Int("unchanged", summary.Chapters.Unchanged).
+		Int("removed", summary.Chapters.Removed).
+		Int("restored", summary.Chapters.Restored).
+		Dur("duration", time.Since(start)).
+		Msg("Stored book result")
+
+	return &Result{Novel: novel, Chapters: chapters, Summary: summary}, nil
+}
+
+// bookNovel maps a book to a novel. Empty fields are not written over the stored ones.
+func bookNovel(book protocol.Book, websiteID int) models.Novel {
+	return models.Novel{
+		WebsiteID:   websiteID,
+		ExternalID:  book.BookId,
+		Title:       book.BookName,
+		Author:      book.AuthorName,
+		CoverURL:    book.BookImageUrl,
+		Description: book.Description,
+		Genres:      book.Genres,
+		Tags:        book.Tags,
+		Status:      novelStatus(book.Status),
+		SourceURL:   book.BookUrl,
+		LastCrawledAt: sql.NullTime{
+			Time:  time.Now(),
+			Valid: true,
+		},
+	}
+}
+
+// novelStatus maps the status reported by a source to a novel status.
+// Unknown statuses are ignored so they don't overwrite the stored one.
+func novelStatus(status string) string {
+	switch status {
+	case models.NovelStatusOngoing, models.NovelStatusCompleted:
+		return status
+	}
+	return ""
+}
+
+// listedChapter maps the chapter at index in the list of a book to a chapter
+func listedChapter(c protocol.Chapter, index int) models.Chapter {
+	return models.Chapter{
+		ExternalID:    c.ChapterId,
+		Title:         c.ChapterName,
+		URL:           c.ChapterUrl,
+		ChapterNumber: c.ChapterNumber,
+		Sequence:      chapterSequence(c, index),
+		Volume:        c.Volume,
+		Part:          c.Part,
+		CrawledAt: sql.NullTime{
+			Time:  time.Now(),
+			Valid: true,
+		},
+	}
+}
+
+// chapterSequence returns the sequence of the chapter at index in the list of a book task,
+// its position when the agent didn't send it
+func chapterSequence(c protocol.Chapter, index int) int {
+	if c.Sequence > 0 {
+		return c.Sequence
+	}
+	return index + 1
+}
@@ -9,7 +9,11 @@ import (
 
 	"cct/config"
 	"cct/models"
+	"cct/pkg/events"
 	"cct/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/zrik/protocol"
 )
 
 // AgentService represents a service for sending messages to agents
@@ -67,7 +71,7 @@ func (s *AgentService) refreshActiveAgents() error {
 }
 
 // PublishTaskToActiveAgents publishes a task to all active agents
-func (s *AgentService) PublishTaskToActiveAgents(ctx context.Context, task Task) error {
+func (s *AgentService) PublishTaskToActiveAgents(ctx context.Context, task protocol.Task) error {
 	// Refresh the list of active agents
 	if err := s.refreshActiveAgents(); err != nil {
 		return err
@@ -87,6 +91,7 @@ func (s *AgentService) PublishTaskToActiveAgents(ctx context.Context, task Task)
 	}
 
 	logger.Info().
+		Str("task_id", task.TaskID).
 		Str("topic", task.Topic).
 		Str("source", string(task.Source)).
 		Int("agent_count", agentCount).
@@ -95,22 +100,74 @@ func (s *AgentService) PublishTaskToActiveAgents(ctx context.Context, task Task)
 	return nil
 }
 
-// PublishBookTask publishes a book task to active agents
-func (s *AgentService) PublishBookTask(ctx context.Context, source SourceType, bookURL string) error {
-	task := CreateBookTask(source, bookURL)
-	return s.PublishTaskToActiveAgents(ctx, task)
+// PublishBookTask publishes a book task to active agents, with the stored cover of the
+// book so agents don't download an unchanged cover again
+func (s *AgentService) PublishBookTask(ctx context.Context, source protocol.SourceType, bookURL string) error {
+	task := protocol.NewBookTask(source, bookURL, storedCoverURL(bookURL))
+	return s.publishTask(ctx, task, protocol.TaskTypeBook, bookURL)
+}
+
+// storedCoverURL returns the source URL of the stored cover of the novel at bookURL, empty
+// when the novel or its cover is unknown
+func storedCoverURL(bookURL string) string {
+	novelID, _, err := models.GetURLNovel(bookURL)
+	if err != nil {
+		logger.Warn().Err(err).Str("url", bookURL).Msg("Failed to look up the novel of a book task")
+		return ""
+	}
+	if novelID == 0 {
+		return ""
+	}
+
+	cover, err := models.GetNovelCover(novelID)
+	if err != nil {
+		logger.Warn().Err(err).Int("novel_id", novelID).Msg("Failed to get novel cover")
+		return ""
+	}
+	if cover.Hash == "" {
+		return ""
+	}
+	return cover.SourceURL
 }
 
 // PublishChapterTask publishes a chapter task to active agents
-func (s *AgentService) PublishChapterTask(ctx context.Context, source SourceType, chapterURL string) error {
-	task := CreateChapterTask(source, chapterURL)
-	return s.PublishTaskToActiveAgents(ctx, task)
+func (s *AgentService) PublishChapterTask(ctx context.Context, source protocol.SourceType, chapterURL string) error {
+	return s.publishTask(ctx, protocol.NewChapterTask(source, chapterURL), protocol.TaskTypeChapter, chapterURL)
 }
 
 // PublishSessionTask publishes a session task to active agents
-func (s *AgentService) PublishSessionTask(ctx context.Context, source SourceType, url string) error {
-	task := CreateSessionTask(source, url)
-	return s.PublishTaskToActiveAgents(ctx, task)
+func (s *AgentService) PublishSessionTask(ctx context.Context, source protocol.SourceType, url string) error {
+	return s.publishTask(ctx, protocol.NewSessionTask(source, url), protocol.TaskTypeSession, url)
+}
+
+// publishTask gives a task a new ID, publishes it to active agents and announces it
+func (s *AgentService) publishTask(ctx context.Context, task protocol.Task, taskType protocol.TaskType, url string) error {
+	task.TaskID = uuid.NewString()
+	if err := s.PublishTaskToActiveAgents(ctx, task); err != nil {
+		return err
+	}
+	publishTaskEvent(task.TaskID, taskType, task.Source, url)
+	return nil
+}
+
+// publishTaskEvent announces a published task, with the novel its URL belongs to when known
+func publishTaskEvent(taskID string, taskType protocol.TaskType, source protocol.SourceType, url string) {
+	novelID, websiteID, err := models.GetURLNovel(url)
+	if err != nil {
+		logger.Warn().Err(err).Str("url", url).Msg("Failed to look up the novel of a task")
+	}
+
+	events.Publish(events.Event{
+		Type:      events.TypeTaskPublished,
+		NovelID:   novelID,
+		WebsiteID: websiteID,
+		Data: events.Task{
+			TaskID:   taskID,
+			TaskType: string(taskType),
+			Source:   string(source),
+			URL:      url,
+		},
+	})
 }
 
 // GetActiveAgentCount returns the number of active agents
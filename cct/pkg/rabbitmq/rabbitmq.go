@@ -9,53 +9,12 @@ import (
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/zrik/protocol"
 
 	"cct/config"
 	"cct/pkg/logger"
 )
 
-// SourceType represents the source of a task
-type SourceType string
-
-// TaskType represents the type of a task
-type TaskType string
-
-// Known source types
-const (
-	SourceTypeSangTacViet SourceType = "sangtacviet"
-	SourceTypeWikiDich    SourceType = "wikidich"
-	SourceTypeMetruyenchu SourceType = "metruyenchu"
-)
-
-// Known task types
-const (
-	TaskTypeBook    TaskType = "book"
-	TaskTypeChapter TaskType = "chapter"
-	TaskTypeSession TaskType = "session"
-)
-
-// Task represents a task to be processed
-type Task struct {
-	Topic   string          `json:"topic"`
-	Payload json.RawMessage `json:"payload"`
-	Source  SourceType      `json:"source"`
-}
-
-// BookTask represents a book task
-type BookTask struct {
-	BookURL string `json:"book_url"`
-}
-
-// ChapterTask represents a chapter task
-type ChapterTask struct {
-	ChapterURL string `json:"chapter_url"`
-}
-
-// SessionTask represents a session task
-type SessionTask struct {
-	URL string `json:"url"`
-}
-
 // Service represents a RabbitMQ service
 type Service struct {
 	config     *config.RabbitMQConfig
@@ -161,7 +120,7 @@ func (s *Service) Close() error {
 }
 
 // PublishTask publishes a task to RabbitMQ
-func (s *Service) PublishTask(ctx context.Context, task Task) error {
+func (s *Service) PublishTask(ctx context.Context, task protocol.Task) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -187,47 +146,3 @@ func (s *Service) PublishTask(ctx context.Context, task Task) error {
 		},
 	)
 }
-
-// GetTopicFromTaskTypeAndSource returns the topic for a task type and source
-func GetTopicFromTaskTypeAndSource(taskType TaskType, source SourceType) string {
-	return fmt.Sprintf("crawl.%s.%s", source, taskType)
-}
-
-// CreateBookTask creates a new book task
-func CreateBookTask(source SourceType, bookURL string) Task {
-	payload, _ := json.Marshal(BookTask{
-		BookURL: bookURL,
-	})
-
-	return Task{
-		Topic:   GetTopicFromTaskTypeAndSource(TaskTypeBook, source),
-		Payload: payload,
-		Source:  source,
-	}
-}
-
-// CreateChapterTask creates a new chapter task
-func CreateChapterTask(source SourceType, chapterURL string) Task {
-	payload, _ := json.Marshal(ChapterTask{
-		ChapterURL: chapterURL,
-	})
-
-	return Task{
-		Topic:   GetTopicFromTaskTypeAndSource(TaskTypeChapter, source),
-		Payload: payload,
-		Source:  source,
-	}
-}
-
-// CreateSessionTask creates a new session task
-func CreateSessionTask(source SourceType, url string) Task {
-	payload, _ := json.Marshal(SessionTask{
-		URL: url,
-	})
-
-	return Task{
-		Topic:   GetTopicFromTaskTypeAndSource(TaskTypeSession, source),
-		Payload: payload,
-		Source:  source,
-	}
-}
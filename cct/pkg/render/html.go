@@ -0,0 +1,310 @@
+package render
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// blockTags are separated from their siblings by a blank line
+var blockTags = map[string]bool{
+	"p": true, "div": true, "blockquote": true, "pre": true, "hr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true,
+}
+
+// parseFragment parses an HTML fragment as the content of a <div>
+func parseFragment(fragment string) ([]*html.Node, error) {
+	return html.ParseFragment(strings.NewReader(fragment), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	})
+}
+
+// HTMLToText returns the text of an HTML fragment with one line per paragraph
+func HTMLToText(fragment string) (string, error) {
+	nodes, err := parseFragment(fragment)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(collapseSpace(n.Data))
+			return
+		case html.ElementNode:
+			if n.Data == "br" {
+				b.WriteString("\n")
+				return
+			}
+			if blockTags[n.Data] || n.Data == "li" {
+				b.WriteString("\n")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type == html.ElementNode && (blockTags[n.Data] || n.Data == "li") {
+			b.WriteString("\n")
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+
+	return joinLines(b.String(), "\n"), nil
+}
+
+// HTMLToMarkdown converts a sanitized HTML fragment to Markdown
+func HTMLToMarkdown(fragment string) (string, error) {
+	nodes, err := parseFragment(fragment)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, n := range nodes {
+		writeMarkdown(&b, n)
+	}
+
+	return strings.TrimSpace(squeezeBlankLines(b.String())) + "\n", nil
+}
+
+// writeMarkdown writes the Markdown of node n to b
+func writeMarkdown(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(escapeInline(collapseSpace(n.Data)))
+		return
+	case html.ElementNode:
+	default:
+		writeChildren(b, n)
+		return
+	}
+
+	switch n.Data {
+	case "br":
+		b.WriteString("  \n")
+	case "hr":
+		b.WriteString("\n\n---\n\n")
+	case "p", "div":
+		b.WriteString("\n\n")
+		writeChildren(b, n)
+		b.WriteString("\n\n")
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(n.Data[1:])
+		b.WriteString("\n\n" + strings.Repeat("#", level) + " ")
+		b.WriteString(strings.TrimSpace(inner(n)))
+		b.WriteString("\n\n")
+	case "em", "i":
+		wrapInline(b, n, "*")
+	case "strong", "b":
+		wrapInline(b, n, "**")
+	case "s", "del":
+		wrapInline(b, n, "~~")
+	case "code":
+		b.WriteString("`" + textContent(n) + "`")
+	case "pre":
+		b.WriteString("\n\n```\n" + strings.TrimRight(textContent(n), "\n") + "\n```\n\n")
+	case "blockquote":
+		content := strings.TrimSpace(squeezeBlankLines(inner(n)))
+		b.WriteString("\n\n")
+		for _, line := range strings.Split(content, "\n") {
+			b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+		b.WriteString("\n")
+	case "ul", "ol":
+		b.WriteString("\n\n")
+		i := 0
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || c.Data != "li" {
+				continue
+			}
+			i++
+			marker := "- "
+			if n.Data == "ol" {
+				marker = strconv.Itoa(i) + ". "
+			}
+			item := strings.TrimSpace(squeezeBlankLines(inner(c)))
+			indent := strings.Repeat(" ", len(marker))
+			b.WriteString(marker + strings.ReplaceAll(item, "\n", "\n"+indent) + "\n")
+		}
+		b.WriteString("\n")
+	default:
+		writeChildren(b, n)
+	}
+}
+
+// writeChildren writes the Markdown of the children of n to b
+func writeChildren(b *strings.Builder, n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeMarkdown(b, c)
+	}
+}
+
+// inner returns the Markdown of the children of n
+func inner(n *html.Node) string {
+	var b strings.Builder
+	writeChildren(&b, n)
+	return b.String()
+}
+
+// wrapInline writes the children of n surrounded by marker, keeping the
+// surrounding whitespace outside of the markers
+func wrapInline(b *strings.Builder, n *html.Node, marker string) {
+	content := inner(n)
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		b.WriteString(content)
+		return
+	}
+	if strings.HasPrefix(content, " ") {
+		b.WriteString(" ")
+	}
+	b.WriteString(marker + trimmed + marker)
+	if strings.HasSuffix(content, " ") {
+		b.WriteString(" ")
+	}
+}
+
+// textContent returns the raw text of n and its children
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+// collapseSpace replaces runs of whitespace with a single space
+func collapseSpace(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		if s == "" {
+			return ""
+		}
+		return " "
+	}
+	out := strings.Join(fields, " ")
+	if strings.TrimLeft(s, " \t\r\n") != s {
+		out = " " + out
+	}
+	if strings.TrimRight(s, " \t\r\n") != s {
+		out += " "
+	}
+	return out
+}
+
+// squeezeBlankLines trims trailing spaces off lines, except Markdown hard breaks,
+// and keeps at most one blank line between blocks
+func squeezeBlankLines(s string) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		if !strings.HasSuffix(line, "  ") || strings.TrimSpace(line) == "" {
+			line = strings.TrimRight(line, " ")
+		}
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// joinLines trims every line of s and joins the non-empty ones with sep
+func joinLines(s, sep string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, sep)
+}
+
+// markdownEscaper escapes characters that would start Markdown inline markup
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "~", `\~`,
+)
+
+// escapeInline escapes Markdown inline markup in text
+func escapeInline(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// TextToMarkdown converts plain text to Markdown with one paragraph per line
+func TextToMarkdown(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, escapeInline(line))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n\n") + "\n"
+}
+
+// XHTML serializes an HTML fragment as well-formed XHTML, keeping only the tags allowed by
+// SanitizeHTML, without attributes
+func XHTML(fragment string) (string, error) {
+	nodes, err := parseFragment(fragment)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	var write func(*html.Node)
+	write = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(html.EscapeString(n.Data))
+			return
+		case html.ElementNode:
+			if droppedTags[n.Data] {
+				return
+			}
+			if !allowedTags[n.Data] {
+				break
+			}
+			if n.Data == "br" || n.Data == "hr" {
+				b.WriteString("<" + n.Data + "/>")
+				return
+			}
+			b.WriteString("<" + n.Data + ">")
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				write(c)
+			}
+			b.WriteString("</" + n.Data + ">")
+			return
+		case html.CommentNode, html.DoctypeNode:
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			write(c)
+		}
+	}
+	for _, n := range nodes {
+		write(n)
+	}
+
+	return b.String(), nil
+}
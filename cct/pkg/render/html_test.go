@@ -0,0 +1,37 @@
+package render
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"paragraphs", "<p>One</p><p>Two</p>", "One\nTwo"},
+		{"line breaks", "<p>One<br>Two</p>", "One\nTwo"},
+		{"inline tags", "<p>One <b>two</b> <em>three</em></p>", "One two three"},
+		{"collapsed space", "<p>  One \n  two  </p>", "One two"},
+		{"empty paragraphs", "<p>One</p><p> </p><p>Two</p>", "One\nTwo"},
+		{"list items", "<ul><li>One</li><li>Two</li></ul>", "One\nTwo"},
+		{"escaped text", "<p>1 &lt; 2 &amp; 3</p>", "1 < 2 & 3"},
+		// Blocks start on a new line. Text before a block was joined to its first line,
+		// "Intro<p>One</p>" gave "IntroOne".
+		{"text before a block", "Intro<p>One</p>", "Intro\nOne"},
+		{"inline before a block", "<b>Intro</b><div>One</div>", "Intro\nOne"},
+		{"text before a list item", "<ul>Items<li>One</li></ul>", "Items\nOne"},
+		{"nested blocks", "<blockquote>Quote<p>One</p>End</blockquote>", "Quote\nOne\nEnd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HTMLToText(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("HTMLToText(%q)\ngot:  %q\nwant: %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
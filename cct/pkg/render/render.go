@@ -0,0 +1,138 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Output formats of a chapter
+const (
+	FormatText     = "text"
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+)
+
+// contentTypes maps output formats to response content types
+var contentTypes = map[string]string{
+	FormatText:     "text/plain; charset=utf-8",
+	FormatHTML:     "text/html; charset=utf-8",
+	FormatMarkdown: "text/markdown; charset=utf-8",
+}
+
+// ContentType returns the response content type of format
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// ParseFormat validates an output format given by a client
+func ParseFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "text", "txt", "plain":
+		return FormatText, nil
+	case "html":
+		return FormatHTML, nil
+	case "markdown", "md":
+		return FormatMarkdown, nil
+	default:
+		return "", fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// FormatFromAccept returns the first output format accepted by an Accept header,
+// in the client's order of preference. It returns an empty string when the client
+// accepts JSON, anything or none of the output formats.
+func FormatFromAccept(accept string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, q := parseMediaRange(part)
+		if q <= bestQ {
+			continue
+		}
+
+		var format string
+		switch mediaType {
+		case "text/plain":
+			format = FormatText
+		case "text/html":
+			format = FormatHTML
+		case "text/markdown", "text/x-markdown":
+			format = FormatMarkdown
+		case "application/json", "*/*":
+			format = ""
+		default:
+			continue
+		}
+		best, bestQ = format, q
+	}
+	return best
+}
+
+// parseMediaRange returns the media type and quality of one Accept header entry
+func parseMediaRange(part string) (string, float64) {
+	params := strings.Split(part, ";")
+	mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+	q := 1.0
+	for _, param := range params[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if ok && strings.TrimSpace(key) == "q" {
+			if _, err := fmt.Sscanf(strings.TrimSpace(value), "%g", &q); err != nil {
+				q = 0
+			}
+		}
+	}
+	return mediaType, q
+}
+
+// Chapter renders a chapter in format. content is the plain text of the chapter,
+// richContent is the structured content stored in richFormat, if any.
+func Chapter(content, richContent, richFormat, format string) (string, error) {
+	switch format {
+	case FormatText:
+		if content == "" && richContent != "" {
+			switch richFormat {
+			case FormatHTML:
+				return HTMLToText(richContent)
+			case FormatMarkdown:
+				return richContent, nil
+			}
+		}
+		return content, nil
+
+	case FormatHTML:
+		if richContent != "" && richFormat == FormatHTML {
+			// Content stored before it was sanitized on ingest is sanitized here
+			return SanitizeHTML(richContent)
+		}
+		return TextToHTML(content), nil
+
+	case FormatMarkdown:
+		if richContent != "" {
+			switch richFormat {
+			case FormatMarkdown:
+				return richContent, nil
+			case FormatHTML:
+				return HTMLToMarkdown(richContent)
+			}
+		}
+		return TextToMarkdown(content), nil
+
+	default:
+		return "", fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// TextToHTML turns every non-empty line of plain text into a paragraph
+func TextToHTML(text string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(html.EscapeString(line))
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package render
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// allowedTags are the structural tags kept in chapter HTML, without any attributes
+var allowedTags = map[string]bool{
+	"p": true, "br": true, "hr": true,
+	"em": true, "i": true, "strong": true, "b": true, "u": true, "s": true, "del": true,
+	"sup": true, "sub": true, "small": true,
+	"blockquote": true, "pre": true, "code": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true,
+}
+
+// droppedTags are removed together with their content
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "iframe": true, "object": true, "embed": true,
+	"template": true, "svg": true, "math": true,
+	"button": true, "form": true, "input": true, "select": true, "textarea": true,
+}
+
+// SanitizeHTML keeps only the allowed tags of an HTML fragment, without any attributes.
+// Other tags are unwrapped so their text is kept, except for scripts, styles, embedded
+// content and form controls which are removed entirely. Chapter HTML from agents and
+// clients goes through it before it is stored or served.
+func SanitizeHTML(fragment string) (string, error) {
+	nodes, err := parseFragment(fragment)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, n := range nodes {
+		writeSanitized(&b, n)
+	}
+
+	return strings.TrimSpace(b.String()), nil
+}
+
+// writeSanitized writes the sanitized node n to b
+func writeSanitized(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.CommentNode, html.DoctypeNode:
+		return
+	case html.ElementNode:
+		if droppedTags[n.Data] {
+			return
+		}
+		if allowedTags[n.Data] {
+			if n.Data == "br" || n.Data == "hr" {
+				b.WriteString("<" + n.Data + ">")
+				return
+			}
+			b.WriteString("<" + n.Data + ">")
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				writeSanitized(b, c)
+			}
+			b.WriteString("</" + n.Data + ">")
+			return
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeSanitized(b, c)
+	}
+}
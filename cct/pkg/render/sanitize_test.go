@@ -0,0 +1,50 @@
+package render
+
+import "testing"
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"allowed tags", "<p>One <em>two</em></p><hr><p>three<br>four</p>", "<p>One <em>two</em></p><hr><p>three<br>four</p>"},
+		{"attributes", `<p class="x" onclick="alert(1)">text</p>`, "<p>text</p>"},
+		{"script", "<p>a</p><script>alert(1)</script><p>b</p>", "<p>a</p><p>b</p>"},
+		{"event handler on unknown tag", `<img src=x onerror="alert(1)"><p>a</p>`, "<p>a</p>"},
+		{"unwrapped tags", `<div><span style="color:red">a</span> <a href="javascript:alert(1)">b</a></div>`, "a b"},
+		{"embedded content", "<iframe src=x></iframe><svg><script>alert(1)</script></svg><p>a</p>", "<p>a</p>"},
+		{"text is escaped", "<p>1 &lt; 2 &amp; &lt;script&gt;</p>", "<p>1 &lt; 2 &amp; &lt;script&gt;</p>"},
+		{"comments", "<p>a<!-- <script>alert(1)</script> --></p>", "<p>a</p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SanitizeHTML(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("SanitizeHTML(%q)\ngot:  %q\nwant: %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChapterHTMLIsSanitized(t *testing.T) {
+	got, err := Chapter("", `<p onclick="x()">a</p><script>alert(1)</script>`, FormatHTML, FormatHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<p>a</p>"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got, err = XHTML(`<p>a<script>alert(1)</script><img src=x onerror="x()"><br></p>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<p>a<br/></p>"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"cct/models"
 	"cct/pkg/logger"
 	"cct/pkg/rabbitmq"
+
+	"github.com/zrik/protocol"
 )
 
 // Scheduler manages scheduled crawl tasks
@@ -142,7 +144,7 @@ func (s *Scheduler) processSchedule(schedule models.NovelSchedule) {
 	}
 
 	// Convert website name to source type
-	sourceType := rabbitmq.SourceType(website.Name)
+	sourceType := protocol.SourceType(website.Name)
 
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -178,7 +180,7 @@ func (s *Scheduler) processSchedule(schedule models.NovelSchedule) {
 }
 
 // ProcessBookCrawlResult processes the result of a book crawl task
-func (s *Scheduler) ProcessBookCrawlResult(novelID int, chapters []models.Chapter) error {
+func (s *Scheduler) ProcessBookCrawlResult(novelID int) error {
 	// Update novel's last_crawled_at
 	err := models.UpdateNovelLastCrawledAt(novelID)
 	if err != nil {
@@ -209,37 +211,45 @@ func (s *Scheduler) ProcessBookCrawlResult(novelID int, chapters []models.Chapte
 		return err
 	}
 
-	sourceType := rabbitmq.SourceType(website.Name)
+	sourceType := protocol.SourceType(website.Name)
+
+	// Only chapters without content or whose last crawl failed are crawled, the
+	// chapters of the book listing have no content
+	chapters, err := models.GetChaptersToCrawl(novelID)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Int("novel_id", novelID).
+			Msg("Failed to get chapters to crawl")
+		return err
+	}
 
 	// Create chapter crawl tasks for chapters that need content
 	for _, chapter := range chapters {
-		// Check if chapter needs crawling (no content or failed status)
-		if chapter.Content == "" || chapter.Error != "" {
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-
-			err = s.agentService.PublishChapterTask(ctx, sourceType, chapter.URL)
-			if err != nil {
-				logger.Error().
-					Err(err).
-					Int("chapter_id", chapter.ID).
-					Str("chapter_url", chapter.URL).
-					Msg("Failed to publish chapter task")
-				cancel()
-				continue
-			}
-
-			cancel()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 
-			logger.Info().
+		err = s.agentService.PublishChapterTask(ctx, sourceType, chapter.URL)
+		if err != nil {
+			logger.Error().
+				Err(err).
 				Int("chapter_id", chapter.ID).
 				Str("chapter_url", chapter.URL).
-				Msg("Published chapter crawl task")
+				Msg("Failed to publish chapter task")
+			cancel()
+			continue
 		}
+
+		cancel()
+
+		logger.Info().
+			Int("chapter_id", chapter.ID).
+			Str("chapter_url", chapter.URL).
+			Msg("Published chapter crawl task")
 	}
 
 	logger.Info().
 		Int("novel_id", novelID).
-		Int("total_chapters", len(chapters)).
+		Int("queued_chapters", len(chapters)).
 		Msg("Processed book crawl result")
 
 	return nil
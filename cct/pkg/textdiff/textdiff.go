@@ -0,0 +1,182 @@
+package textdiff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxCells bounds the size of the LCS table, (lines of a) * (lines of b) after the common
+// prefix and suffix are removed. The table takes 4 bytes per cell, 16 MB at most per diff.
+const MaxCells = 4_000_000
+
+// ErrTooLarge is returned when two texts are too large to be compared
+var ErrTooLarge = errors.New("texts are too large to diff")
+
+// Op is the kind of a diff line
+type Op byte
+
+const (
+	Equal  Op = ' '
+	Delete Op = '-'
+	Insert Op = '+'
+)
+
+// Line is one line of a diff
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Result is the line diff of two texts
+type Result struct {
+	Lines   []Line
+	Added   int
+	Removed int
+}
+
+// Lines compares two texts line by line
+func Lines(a, b string) (*Result, error) {
+	return Compare(splitLines(a), splitLines(b))
+}
+
+// Compare returns the shortest edit between a and b, based on their longest common subsequence
+func Compare(a, b []string) (*Result, error) {
+	// Common prefix and suffix are kept out of the LCS table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(ma)*len(mb) > MaxCells {
+		return nil, ErrTooLarge
+	}
+
+	result := &Result{}
+	for _, line := range a[:prefix] {
+		result.Lines = append(result.Lines, Line{Equal, line})
+	}
+
+	// lcs[i][j] is the length of the LCS of ma[i:] and mb[j:]
+	lcs := make([][]int32, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			result.Lines = append(result.Lines, Line{Equal, ma[i]})
+			i++
+			j++
+		case i < len(ma) && (j == len(mb) || lcs[i+1][j] >= lcs[i][j+1]):
+			result.Lines = append(result.Lines, Line{Delete, ma[i]})
+			result.Removed++
+			i++
+		default:
+			result.Lines = append(result.Lines, Line{Insert, mb[j]})
+			result.Added++
+			j++
+		}
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		result.Lines = append(result.Lines, Line{Equal, line})
+	}
+	return result, nil
+}
+
+// Unified formats the diff in unified format with context lines around each change
+func (r *Result) Unified(fromName, toName string, context int) string {
+	if r.Added == 0 && r.Removed == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Line numbers in a and b before each diff line
+	aLine := make([]int, len(r.Lines)+1)
+	bLine := make([]int, len(r.Lines)+1)
+	for k, line := range r.Lines {
+		aLine[k+1], bLine[k+1] = aLine[k], bLine[k]
+		if line.Op != Insert {
+			aLine[k+1]++
+		}
+		if line.Op != Delete {
+			bLine[k+1]++
+		}
+	}
+
+	for k := 0; k < len(r.Lines); {
+		if r.Lines[k].Op == Equal {
+			k++
+			continue
+		}
+
+		// Grow the hunk while changes are closer than two contexts apart
+		start := max(k-context, 0)
+		end := k
+		for end < len(r.Lines) {
+			if r.Lines[end].Op != Equal {
+				end++
+				continue
+			}
+			next := end
+			for next < len(r.Lines) && r.Lines[next].Op == Equal {
+				next++
+			}
+			if next == len(r.Lines) || next-end > 2*context {
+				end = min(end+context, len(r.Lines))
+				break
+			}
+			end = next
+		}
+
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n",
+			hunkRange(aLine[start], aLine[end]-aLine[start]),
+			hunkRange(bLine[start], bLine[end]-bLine[start]))
+		for _, line := range r.Lines[start:end] {
+			b.WriteByte(byte(line.Op))
+			b.WriteString(line.Text)
+			b.WriteByte('\n')
+		}
+		k = end
+	}
+
+	return b.String()
+}
+
+// hunkRange formats the range of a hunk header, start is the count of lines before the hunk
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits text into lines, without a trailing empty line
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
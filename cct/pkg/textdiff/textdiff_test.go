@@ -0,0 +1,181 @@
+package textdiff
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name           string
+		a, b           []string
+		want           []Line
+		added, removed int
+	}{
+		{name: "both empty"},
+		{
+			name:  "empty old side",
+			b:     []string{"x", "y"},
+			want:  []Line{{Insert, "x"}, {Insert, "y"}},
+			added: 2,
+		},
+		{
+			name:    "empty new side",
+			a:       []string{"x", "y"},
+			want:    []Line{{Delete, "x"}, {Delete, "y"}},
+			removed: 2,
+		},
+		{
+			name: "equal",
+			a:    []string{"x", "y"},
+			b:    []string{"x", "y"},
+			want: []Line{{Equal, "x"}, {Equal, "y"}},
+		},
+		{
+			name:    "replaced line",
+			a:       []string{"a", "b", "c"},
+			b:       []string{"a", "B", "c"},
+			want:    []Line{{Equal, "a"}, {Delete, "b"}, {Insert, "B"}, {Equal, "c"}},
+			added:   1,
+			removed: 1,
+		},
+		{
+			name:    "insert and delete",
+			a:       []string{"a", "b", "c", "d"},
+			b:       []string{"a", "c", "d", "e"},
+			want:    []Line{{Equal, "a"}, {Delete, "b"}, {Equal, "c"}, {Equal, "d"}, {Insert, "e"}},
+			added:   1,
+			removed: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Compare(tt.a, tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Lines, tt.want) {
+				t.Errorf("lines\ngot:  %v\nwant: %v", got.Lines, tt.want)
+			}
+			if got.Added != tt.added || got.Removed != tt.removed {
+				t.Errorf("got +%d -%d, want +%d -%d", got.Added, got.Removed, tt.added, tt.removed)
+			}
+		})
+	}
+}
+
+func TestCompareTooLarge(t *testing.T) {
+	a := make([]string, 2001)
+	b := make([]string, 2001)
+	for i := range a {
+		a[i] = "a" + strconv.Itoa(i)
+		b[i] = "b" + strconv.Itoa(i)
+	}
+	if _, err := Compare(a, b); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("got %v, want ErrTooLarge", err)
+	}
+
+	// The common prefix and suffix don't count
+	same := append(append([]string{}, a...), "changed")
+	if _, err := Compare(append(a, "x"), same); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// numbered returns the lines "1" to "n"
+func numbered(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = strconv.Itoa(i + 1)
+	}
+	return lines
+}
+
+// replaced returns lines with the lines at the given 1-based positions suffixed with "x"
+func replaced(lines []string, positions ...int) []string {
+	out := append([]string{}, lines...)
+	for _, p := range positions {
+		out[p-1] += "x"
+	}
+	return out
+}
+
+func TestUnified(t *testing.T) {
+	lines := numbered(20)
+
+	tests := []struct {
+		name    string
+		a, b    []string
+		context int
+		want    string
+	}{
+		{name: "equal", a: lines, b: lines, context: 3, want: ""},
+		{
+			name:    "empty old side",
+			b:       []string{"x"},
+			context: 3,
+			want:    "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n",
+		},
+		{
+			name:    "empty new side",
+			a:       []string{"x", "y"},
+			context: 3,
+			want:    "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-x\n-y\n",
+		},
+		{
+			name:    "context clipped at the start",
+			a:       lines[:5],
+			b:       replaced(lines[:5], 2),
+			context: 3,
+			want:    "--- a\n+++ b\n@@ -1,5 +1,5 @@\n 1\n-2\n+2x\n 3\n 4\n 5\n",
+		},
+		{
+			name:    "changes two contexts apart share a hunk",
+			a:       lines,
+			b:       replaced(lines, 5, 12),
+			context: 3,
+			want:    "--- a\n+++ b\n@@ -2,14 +2,14 @@\n 2\n 3\n 4\n-5\n+5x\n 6\n 7\n 8\n 9\n 10\n 11\n-12\n+12x\n 13\n 14\n 15\n",
+		},
+		{
+			name:    "changes further apart split hunks",
+			a:       lines,
+			b:       replaced(lines, 5, 13),
+			context: 3,
+			want: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+5x\n 6\n 7\n 8\n" +
+				"@@ -10,7 +10,7 @@\n 10\n 11\n 12\n-13\n+13x\n 14\n 15\n 16\n",
+		},
+		{
+			name:    "no context",
+			a:       lines[:3],
+			b:       replaced(lines[:3], 2),
+			context: 0,
+			want:    "--- a\n+++ b\n@@ -2 +2 @@\n-2\n+2x\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Compare(tt.a, tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := result.Unified("a", "b", tt.context); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLines(t *testing.T) {
+	result, err := Lines("a\nb\n", "a\nc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Line{{Equal, "a"}, {Delete, "b"}, {Insert, "c"}}
+	if !reflect.DeepEqual(result.Lines, want) {
+		t.Errorf("got %v, want %v", result.Lines, want)
+	}
+}
@@ -0,0 +1,294 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"cct/config"
+	"cct/models"
+	"cct/pkg/events"
+	"cct/pkg/logger"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// EventTypes are the event types delivered to webhooks
+var EventTypes = []string{
+	events.TypeNovelCreated,
+	events.TypeChapterAdded,
+	events.TypeChapterUpdated,
+	events.TypeTaskFailed,
+	events.TypeWebsiteUnhealthy,
+}
+
+// eventBuffer is the number of events waiting to be queued in the outbox
+const eventBuffer = 1024
+
+// pollInterval is how often idle workers look for due deliveries in the outbox
+const pollInterval = 5 * time.Second
+
+// claimMargin is added to the timeout for how long a claimed delivery is hidden from
+// other workers, it is retried after that when cct stopped while sending it
+const claimMargin = time.Minute
+
+// pruneInterval is how often expired delivery attempts are deleted
+const pruneInterval = time.Hour
+
+// IsEventType reports whether events of a type are delivered to webhooks
+func IsEventType(eventType string) bool {
+	return slices.Contains(EventTypes, eventType)
+}
+
+// Sign returns the signature of a payload sent at a Unix timestamp: "sha256=" followed by
+// the hex HMAC-SHA256, keyed with the webhook secret, of the timestamp, a dot and the payload
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher delivers the published events to the webhooks subscribed to them. Deliveries
+// go through the webhook_outbox table, so retries and deliveries not sent yet survive
+// restarts.
+type Dispatcher struct {
+	cfg    *config.WebhooksConfig
+	client *http.Client
+	wakeCh chan struct{}
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+
+	unsubscribe func()
+}
+
+// NewDispatcher creates a webhook dispatcher
+func NewDispatcher(cfg *config.Config) *Dispatcher {
+	return &Dispatcher{
+		cfg:    &cfg.Webhooks,
+		client: &http.Client{Timeout: cfg.Webhooks.Timeout},
+		wakeCh: make(chan struct{}, 1),
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start subscribes to the events and starts the delivery workers
+func (d *Dispatcher) Start() {
+	var ch <-chan events.Event
+	ch, d.unsubscribe = events.SubscribeTypes(eventBuffer, EventTypes...)
+
+	d.wg.Add(1)
+	go d.dispatch(ch)
+
+	workers := max(d.cfg.Workers, 1)
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+
+	d.wg.Add(1)
+	go d.prune()
+
+	logger.Info().Int("workers", workers).Msg("Webhook dispatcher started")
+}
+
+// Stop stops the dispatcher once the received events are queued, deliveries left in the
+// outbox are sent after the next start
+func (d *Dispatcher) Stop() {
+	d.unsubscribe()
+	close(d.stopCh)
+	d.wg.Wait()
+	logger.Info().Msg("Webhook dispatcher stopped")
+}
+
+// dispatch queues a delivery of every event in the outbox for each webhook subscribed to it
+func (d *Dispatcher) dispatch(ch <-chan events.Event) {
+	defer d.wg.Done()
+
+	for e := range ch {
+		webhooks, err := models.GetEventWebhooks(e.Type, e.NovelID)
+		if err != nil {
+			logger.Error().Err(err).Str("type", e.Type).Uint64("event_id", e.ID).Msg("Failed to get webhooks for event")
+			continue
+		}
+		if len(webhooks) == 0 {
+			continue
+		}
+
+		payload, err := json.Marshal(e)
+		if err != nil {
+			logger.Error().Err(err).Str("type", e.Type).Msg("Failed to encode webhook payload")
+			continue
+		}
+		if err := models.QueueWebhookDeliveries(webhooks, e.Type, payload); err != nil {
+			logger.Error().Err(err).Str("type", e.Type).Uint64("event_id", e.ID).Msg("Failed to queue webhook deliveries")
+			continue
+		}
+
+		select {
+		case d.wakeCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// work sends the due deliveries of the outbox, waiting for new ones when there are none
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		default:
+		}
+
+		dl, err := models.ClaimWebhookDelivery(time.Now().Add(d.cfg.Timeout + claimMargin))
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to claim webhook delivery")
+		}
+		if dl != nil {
+			d.deliver(dl)
+			continue
+		}
+
+		select {
+		case <-d.stopCh:
+			return
+		case <-d.wakeCh:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// deliver makes an attempt to deliver an event and logs it. A failed attempt is retried
+// after a backoff until MaxAttempts, the delivery is removed from the outbox after that.
+func (d *Dispatcher) deliver(dl *models.WebhookOutboxEntry) {
+	start := time.Now()
+	statusCode, err := d.send(dl)
+
+	attempt := models.WebhookDelivery{
+		WebhookID:  dl.WebhookID,
+		DeliveryID: dl.DeliveryID,
+		EventType:  dl.EventType,
+		Attempt:    dl.Attempt,
+		DurationMs: int(time.Since(start).Milliseconds()),
+	}
+	if statusCode != 0 {
+		attempt.StatusCode = &statusCode
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	if err := models.CreateWebhookDelivery(&attempt); err != nil {
+		logger.Error().Err(err).Int("webhook_id", dl.WebhookID).Msg("Failed to log webhook delivery")
+	}
+
+	switch {
+	case err == nil:
+		logger.Debug().
+			Int("webhook_id", dl.WebhookID).
+			Str("type", dl.EventType).
+			Int("attempt", dl.Attempt).
+			Msg("Delivered webhook")
+	case dl.Attempt >= d.cfg.MaxAttempts:
+		logger.Warn().
+			Err(err).
+			Int("webhook_id", dl.WebhookID).
+			Str("delivery_id", dl.DeliveryID.String()).
+			Int("attempts", dl.Attempt).
+			Msg("Giving up on webhook delivery")
+	default:
+		delay := d.retryDelay(dl.Attempt)
+		logger.Debug().
+			Err(err).
+			Int("webhook_id", dl.WebhookID).
+			Int("attempt", dl.Attempt).
+			Dur("retry_in", delay).
+			Msg("Webhook delivery failed, retrying")
+
+		if err := models.RescheduleWebhookDelivery(dl.ID, dl.Attempt+1, time.Now().Add(delay)); err != nil {
+			logger.Error().Err(err).Int("webhook_id", dl.WebhookID).Msg("Failed to reschedule webhook delivery")
+		}
+		return
+	}
+
+	if err := models.DeleteWebhookOutboxEntry(dl.ID); err != nil {
+		logger.Error().Err(err).Int("webhook_id", dl.WebhookID).Msg("Failed to remove webhook delivery from the outbox")
+	}
+}
+
+// send posts a delivery, returning the response status code, 0 when there was no response
+func (d *Dispatcher) send(dl *models.WebhookOutboxEntry) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, dl.URL, bytes.NewReader(dl.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cct-webhooks")
+	req.Header.Set(HeaderEvent, dl.EventType)
+	req.Header.Set(HeaderDelivery, dl.DeliveryID.String())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(dl.Secret, timestamp, dl.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryDelay returns the wait after a failed attempt, RetryDelay doubled for every attempt
+// before it up to MaxRetryDelay
+func (d *Dispatcher) retryDelay(attempt int) time.Duration {
+	delay := d.cfg.RetryDelay
+	for i := 1; i < attempt && delay < d.cfg.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.MaxRetryDelay)
+}
+
+// prune deletes the expired delivery attempts
+func (d *Dispatcher) prune() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case <-ticker.C:
+			if n, err := models.DeleteExpiredWebhookDeliveries(d.cfg.DeliveryTTL); err != nil {
+				logger.Error().Err(err).Msg("Failed to delete expired webhook deliveries")
+			} else if n > 0 {
+				logger.Debug().Int64("deliveries", n).Msg("Deleted expired webhook deliveries")
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package webhooks
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"cct/config"
+	"cct/models"
+
+	"github.com/google/uuid"
+)
+
+func TestSign(t *testing.T) {
+	// Expected values computed with another HMAC implementation, receivers check this format
+	tests := []struct {
+		secret    string
+		timestamp int64
+		payload   string
+		want      string
+	}{
+		{
+			secret:    "secret",
+			timestamp: 1700000000,
+			payload:   `{"type":"chapter.updated"}`,
+			want:      "sha256=5096129b0d2dea231349f95ae4d275240927c6eca2267613d78702d95582fd76",
+		},
+		{
+			want: "sha256=b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := Sign(tt.secret, tt.timestamp, []byte(tt.payload)); got != tt.want {
+			t.Errorf("Sign(%q, %d, %q) = %s, want %s", tt.secret, tt.timestamp, tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	d := &Dispatcher{cfg: &config.WebhooksConfig{RetryDelay: 10 * time.Second, MaxRetryDelay: 5 * time.Minute}}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{5, 160 * time.Second},
+		{6, 5 * time.Minute},
+		{50, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := d.retryDelay(tt.attempt); got != tt.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+
+	d.cfg.RetryDelay = time.Hour
+	if got := d.retryDelay(1); got != 5*time.Minute {
+		t.Errorf("retryDelay(1) = %s with a retry delay above the maximum, want %s", got, 5*time.Minute)
+	}
+}
+
+func TestSend(t *testing.T) {
+	payload := []byte(`{"type":"novel.created"}`)
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		if r.Header.Get(HeaderEvent) == "fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	d := &Dispatcher{cfg: &config.WebhooksConfig{}, client: srv.Client()}
+	dl := &models.WebhookOutboxEntry{URL: srv.URL, Secret: "s", DeliveryID: uuid.New(), EventType: "novel.created", Payload: payload}
+	status, err := d.send(dl)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("send = %d, %v", status, err)
+	}
+	if string(body) != string(payload) {
+		t.Errorf("body %s, want %s", body, payload)
+	}
+	if got.Header.Get(HeaderEvent) != dl.EventType || got.Header.Get(HeaderDelivery) != dl.DeliveryID.String() {
+		t.Errorf("event headers %v", got.Header)
+	}
+	timestamp, err := strconv.ParseInt(got.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig := got.Header.Get(HeaderSignature); sig != Sign("s", timestamp, payload) {
+		t.Errorf("signature %s does not match the timestamp and payload", sig)
+	}
+
+	dl.EventType = "fail"
+	if status, err := d.send(dl); err == nil || status != http.StatusBadGateway {
+		t.Errorf("send = %d, %v for a failing receiver, want %d and an error", status, err, http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,245 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: controlpb/control.proto
+
+// The agent control plane of cct: registration, heartbeats with commands pushed to the
+// agent, and task result uploads. Results carry the same schema as the REST API, see the
+// protocol package.
+
+package controlpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	AgentControl_Register_FullMethodName      = "/crawler.control.v1.AgentControl/Register"
+	AgentControl_Heartbeat_FullMethodName     = "/crawler.control.v1.AgentControl/Heartbeat"
+	AgentControl_StartTask_FullMethodName     = "/crawler.control.v1.AgentControl/StartTask"
+	AgentControl_UploadResults_FullMethodName = "/crawler.control.v1.AgentControl/UploadResults"
+)
+
+// AgentControlClient is the client API for AgentControl service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// AgentControl is served by cct and called by the agents
+type AgentControlClient interface {
+	// Register returns the active agent with the name and IP address of the caller.
+	// Agents are created through the REST API, an unknown agent is NOT_FOUND.
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*Agent, error)
+	// Heartbeat records a heartbeat for every message sent by the agent. Commands for the
+	// agent are pushed back on the same stream while it is open.
+	Heartbeat(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[HeartbeatRequest, Command], error)
+	// StartTask announces that the agent started a task, its result comes later
+	StartTask(ctx context.Context, in *TaskStarted, opts ...grpc.CallOption) (*StartTaskResponse, error)
+	// UploadResults processes the task results sent on the stream as they arrive and
+	// returns their outcomes once the agent closes it
+	UploadResults(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[TaskResult, UploadResultsResponse], error)
+}
+
+type agentControlClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAgentControlClient(cc grpc.ClientConnInterface) AgentControlClient {
+	return &agentControlClient{cc}
+}
+
+func (c *agentControlClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*Agent, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Agent)
+	err := c.cc.Invoke(ctx, AgentControl_Register_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentControlClient) Heartbeat(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[HeartbeatRequest, Command], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &AgentControl_ServiceDesc.Streams[0], AgentControl_Heartbeat_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[HeartbeatRequest, Command]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AgentControl_HeartbeatClient = grpc.BidiStreamingClient[HeartbeatRequest, Command]
+
+func (c *agentControlClient) StartTask(ctx context.Context, in *TaskStarted, opts ...grpc.CallOption) (*StartTaskResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StartTaskResponse)
+	err := c.cc.Invoke(ctx, AgentControl_StartTask_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentControlClient) UploadResults(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[TaskResult, UploadResultsResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &AgentControl_ServiceDesc.Streams[1], AgentControl_UploadResults_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[TaskResult, UploadResultsResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AgentControl_UploadResultsClient = grpc.ClientStreamingClient[TaskResult, UploadResultsResponse]
+
+// AgentControlServer is the server API for AgentControl service.
+// All implementations must embed UnimplementedAgentControlServer
+// for forward compatibility.
+//
+// AgentControl is served by cct and called by the agents
+type AgentControlServer interface {
+	// Register returns the active agent with the name and IP address of the caller.
+	// Agents are created through the REST API, an unknown agent is NOT_FOUND.
+	Register(context.Context, *RegisterRequest) (*Agent, error)
+	// Heartbeat records a heartbeat for every message sent by the agent. Commands for the
+	// agent are pushed back on the same stream while it is open.
+	Heartbeat(grpc.BidiStreamingServer[HeartbeatRequest, Command]) error
+	// StartTask announces that the agent started a task, its result comes later
+	StartTask(context.Context, *TaskStarted) (*StartTaskResponse, error)
+	// UploadResults processes the task results sent on the stream as they arrive and
+	// returns their outcomes once the agent closes it
+	UploadResults(grpc.ClientStreamingServer[TaskResult, UploadResultsResponse]) error
+	mustEmbedUnimplementedAgentControlServer()
+}
+
+// UnimplementedAgentControlServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAgentControlServer struct{}
+
+func (UnimplementedAgentControlServer) Register(context.Context, *RegisterRequest) (*Agent, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedAgentControlServer) Heartbeat(grpc.BidiStreamingServer[HeartbeatRequest, Command]) error {
+	return status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
+}
+func (UnimplementedAgentControlServer) StartTask(context.Context, *TaskStarted) (*StartTaskResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartTask not implemented")
+}
+func (UnimplementedAgentControlServer) UploadResults(grpc.ClientStreamingServer[TaskResult, UploadResultsResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method UploadResults not implemented")
+}
+func (UnimplementedAgentControlServer) mustEmbedUnimplementedAgentControlServer() {}
+func (UnimplementedAgentControlServer) testEmbeddedByValue()                      {}
+
+// UnsafeAgentControlServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AgentControlServer will
+// result in compilation errors.
+type UnsafeAgentControlServer interface {
+	mustEmbedUnimplementedAgentControlServer()
+}
+
+func RegisterAgentControlServer(s grpc.ServiceRegistrar, srv AgentControlServer) {
+	// If the following call pancis, it indicates UnimplementedAgentControlServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AgentControl_ServiceDesc, srv)
+}
+
+func _AgentControl_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentControlServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AgentControl_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentControlServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentControl_Heartbeat_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AgentControlServer).Heartbeat(&grpc.GenericServerStream[HeartbeatRequest, Command]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AgentControl_HeartbeatServer = grpc.BidiStreamingServer[HeartbeatRequest, Command]
+
+func _AgentControl_StartTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TaskStarted)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentControlServer).StartTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AgentControl_StartTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentControlServer).StartTask(ctx, req.(*TaskStarted))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentControl_UploadResults_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AgentControlServer).UploadResults(&grpc.GenericServerStream[TaskResult, UploadResultsResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type AgentControl_UploadResultsServer = grpc.ClientStreamingServer[TaskResult, UploadResultsResponse]
+
+// AgentControl_ServiceDesc is the grpc.ServiceDesc for AgentControl service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AgentControl_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "crawler.control.v1.AgentControl",
+	HandlerType: (*AgentControlServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _AgentControl_Register_Handler,
+		},
+		{
+			MethodName: "StartTask",
+			Handler:    _AgentControl_StartTask_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Heartbeat",
+			Handler:       _AgentControl_Heartbeat_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "UploadResults",
+			Handler:       _AgentControl_UploadResults_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "controlpb/control.proto",
+}
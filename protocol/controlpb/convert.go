@@ -0,0 +1,77 @@
+// Package controlpb is the gRPC control API between cct and the agents, generated from
+// control.proto, with conversions to the protocol types.
+package controlpb
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative ../controlpb/control.proto
+
+import (
+	"github.com/zrik/protocol"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// APIKeyMetadata is the metadata key holding the API key of a call, like the Api-Key header
+// of the REST API
+const APIKeyMetadata = "api-key"
+
+// FromTaskResult converts a task result to its message
+func FromTaskResult(r *protocol.TaskResult) *TaskResult {
+	return &TaskResult{
+		SchemaVersion: int32(r.SchemaVersion),
+		TaskId:        r.TaskID,
+		TaskType:      string(r.TaskType),
+		Source:        string(r.Source),
+		Status:        string(r.Status),
+		Message:       r.Message,
+		Data:          r.Data,
+		Url:           r.URL,
+		CompletedAt:   timestamppb.New(r.CompletedAt),
+	}
+}
+
+// ToProtocol converts the message to a task result, it is not validated
+func (r *TaskResult) ToProtocol() *protocol.TaskResult {
+	result := &protocol.TaskResult{
+		SchemaVersion: int(r.GetSchemaVersion()),
+		TaskID:        r.GetTaskId(),
+		TaskType:      protocol.TaskType(r.GetTaskType()),
+		Source:        protocol.SourceType(r.GetSource()),
+		Status:        protocol.ResultStatus(r.GetStatus()),
+		Message:       r.GetMessage(),
+		Data:          r.GetData(),
+		URL:           r.GetUrl(),
+	}
+	if r.GetCompletedAt() != nil {
+		result.CompletedAt = r.GetCompletedAt().AsTime()
+	}
+	return result
+}
+
+// FromTaskStarted converts a started task report to its message
+func FromTaskStarted(s *protocol.TaskStarted) *TaskStarted {
+	return &TaskStarted{
+		SchemaVersion: int32(s.SchemaVersion),
+		TaskId:        s.TaskID,
+		TaskType:      string(s.TaskType),
+		Source:        string(s.Source),
+		Url:           s.URL,
+		AgentId:       s.AgentID,
+		StartedAt:     timestamppb.New(s.StartedAt),
+	}
+}
+
+// ToProtocol converts the message to a started task report, it is not validated
+func (s *TaskStarted) ToProtocol() *protocol.TaskStarted {
+	started := &protocol.TaskStarted{
+		SchemaVersion: int(s.GetSchemaVersion()),
+		TaskID:        s.GetTaskId(),
+		TaskType:      protocol.TaskType(s.GetTaskType()),
+		Source:        protocol.SourceType(s.GetSource()),
+		URL:           s.GetUrl(),
+		AgentID:       s.GetAgentId(),
+	}
+	if s.GetStartedAt() != nil {
+		started.StartedAt = s.GetStartedAt().AsTime()
+	}
+	return started
+}
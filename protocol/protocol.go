@@ -0,0 +1,72 @@
+// Package protocol is the message schema shared by the control API and the agents:
+// the tasks published to the agents and the results they report back.
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SchemaVersion is the version of the task and result schema written by this package.
+// It is raised on changes older readers can't handle. Version 2 added BookTask.CoverURL.
+const SchemaVersion = 2
+
+// ErrUnsupportedVersion is returned for messages written with a schema version this
+// package can't read
+var ErrUnsupportedVersion = errors.New("unsupported schema version")
+
+// checkVersion checks a message schema version. 0 is the legacy version of messages written
+// before versioning, which are read as version 1.
+func checkVersion(version int) error {
+	if version < 0 || version > SchemaVersion {
+		return fmt.Errorf("%w %d, supported versions are 0 (unversioned) to %d", ErrUnsupportedVersion, version, SchemaVersion)
+	}
+	return nil
+}
+
+// SourceType represents the source of a task
+type SourceType string
+
+// TaskType represents the type of a task
+type TaskType string
+
+// Known source types
+const (
+	SourceTypeSangTacViet SourceType = "sangtacviet"
+	SourceTypeWikiDich    SourceType = "wikidich"
+	SourceTypeMetruyenchu SourceType = "metruyenchu"
+)
+
+// Known task types
+const (
+	TaskTypeBook    TaskType = "book"
+	TaskTypeChapter TaskType = "chapter"
+	TaskTypeSession TaskType = "session"
+)
+
+// valid reports whether t is a known task type
+func (t TaskType) valid() bool {
+	switch t {
+	case TaskTypeBook, TaskTypeChapter, TaskTypeSession:
+		return true
+	}
+	return false
+}
+
+// TopicPrefix is the prefix for all topics
+const TopicPrefix = "crawl."
+
+// Topic returns the topic tasks of a type and source are published on
+func Topic(taskType TaskType, source SourceType) string {
+	return TopicPrefix + string(source) + "." + string(taskType)
+}
+
+// ParseTopic parses a topic to extract its source and task type
+func ParseTopic(topic string) (SourceType, TaskType, error) {
+	parts := strings.Split(topic, ".")
+	if len(parts) != 3 || parts[0]+"." != TopicPrefix {
+		return "", "", fmt.Errorf("invalid topic format: %s", topic)
+	}
+	return SourceType(parts[1]), TaskType(parts[2]), nil
+}
@@ -0,0 +1,273 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ResultStatus represents the status of a task result
+type ResultStatus string
+
+const (
+	// ResultStatusSuccess indicates a successful task, Data holds its result
+	ResultStatusSuccess ResultStatus = "success"
+	// ResultStatusError indicates a failed task, Message holds the error
+	ResultStatusError ResultStatus = "error"
+)
+
+// TaskResult is the result of a task reported by an agent. Data is a Book for book tasks
+// and a ChapterContent for chapter tasks.
+type TaskResult struct {
+	SchemaVersion int             `json:"schema_version"`
+	TaskID        string          `json:"task_id"`
+	TaskType      TaskType        `json:"task_type"`
+	Source        SourceType      `json:"source"`
+	Status        ResultStatus    `json:"status"`
+	Message       string          `json:"message"`
+	Data          json.RawMessage `json:"data,omitempty"`
+	URL           string          `json:"url"`
+	CompletedAt   time.Time       `json:"completed_at"`
+}
+
+// NewResult creates a result of the current schema version
+func NewResult(taskID string, taskType TaskType, source SourceType, url string) *TaskResult {
+	return &TaskResult{
+		SchemaVersion: SchemaVersion,
+		TaskID:        taskID,
+		TaskType:      taskType,
+		Source:        source,
+		URL:           url,
+		CompletedAt:   time.Now(),
+	}
+}
+
+// TaskStarted is reported by an agent when it starts a task, before its result
+type TaskStarted struct {
+	SchemaVersion int        `json:"schema_version"`
+	TaskID        string     `json:"task_id"`
+	TaskType      TaskType   `json:"task_type"`
+	Source        SourceType `json:"source"`
+	URL           string     `json:"url"`
+	AgentID       string     `json:"agent_id,omitempty"`
+	StartedAt     time.Time  `json:"started_at"`
+}
+
+// NewTaskStarted creates a started task report of the current schema version
+func NewTaskStarted(taskID string, taskType TaskType, source SourceType, url, agentID string) *TaskStarted {
+	return &TaskStarted{
+		SchemaVersion: SchemaVersion,
+		TaskID:        taskID,
+		TaskType:      taskType,
+		Source:        source,
+		URL:           url,
+		AgentID:       agentID,
+		StartedAt:     time.Now(),
+	}
+}
+
+// Content formats of ChapterContent.RichContent
+const (
+	ContentFormatText     = "text"
+	ContentFormatHTML     = "html"
+	ContentFormatMarkdown = "markdown"
+)
+
+// Book is the result of a book task
+type Book struct {
+	BookUrl      string
+	BookId       string
+	BookName     string
+	BookImageUrl string
+	BookImage    *Image
+	AuthorName   string
+	Description  string
+	Genres       []string
+	Tags         []string
+	Status       string
+	Chapters     []Chapter
+	BookHost     string
+}
+
+// Chapter is a chapter listed by a book task
+type Chapter struct {
+	ChapterId     string
+	ChapterName   string
+	ChapterUrl    string
+	ChapterNumber int
+	// Sequence is the 1-based position of the chapter in the source chapter list,
+	// older agents don't send it
+	Sequence int
+	// Volume and Part group chapters when the source has them, 0 otherwise
+	Volume int
+	Part   int
+}
+
+// Image is a downloaded image, Data is base64 encoded in JSON
+type Image struct {
+	ContentType string
+	Data        []byte
+}
+
+// ChapterContent is the result of a chapter task
+type ChapterContent struct {
+	// Content is the chapter as plain text
+	Content string
+	// RichContent keeps the chapter structure in ContentFormat
+	RichContent   string
+	ContentFormat string
+	// Pages is the number of pages the chapter was merged from
+	Pages int
+}
+
+// ErrInvalidResult is returned for results that don't match the schema
+var ErrInvalidResult = errors.New("invalid task result")
+
+// DecodeResult reads a task result, rejecting unknown fields, trailing data, unsupported
+// schema versions and results missing required fields
+func DecodeResult(r io.Reader) (*TaskResult, error) {
+	var result TaskResult
+	if err := decodeMessage(r, &result); err != nil {
+		return nil, err
+	}
+	if err := result.Validate(); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Validate checks the schema version and required fields of a result
+func (r *TaskResult) Validate() error {
+	if err := checkVersion(r.SchemaVersion); err != nil {
+		return err
+	}
+	switch {
+	case r.TaskID == "":
+		return fmt.Errorf("%w: task_id is required", ErrInvalidResult)
+	case !r.TaskType.valid():
+		return fmt.Errorf("%w: unknown task_type %q", ErrInvalidResult, r.TaskType)
+	case r.Source == "":
+		return fmt.Errorf("%w: source is required", ErrInvalidResult)
+	case r.URL == "":
+		return fmt.Errorf("%w: url is required", ErrInvalidResult)
+	}
+	switch r.Status {
+	case ResultStatusSuccess:
+		if len(r.Data) == 0 && r.TaskType != TaskTypeSession {
+			return fmt.Errorf("%w: data is required for successful %s results", ErrInvalidResult, r.TaskType)
+		}
+	case ResultStatusError:
+	default:
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidResult, r.Status)
+	}
+	return nil
+}
+
+// DecodeTaskStarted reads a started task report like DecodeResult reads a result
+func DecodeTaskStarted(r io.Reader) (*TaskStarted, error) {
+	var started TaskStarted
+	if err := decodeMessage(r, &started); err != nil {
+		return nil, err
+	}
+	if err := started.Validate(); err != nil {
+		return nil, err
+	}
+	return &started, nil
+}
+
+// Validate checks the schema version and required fields of a started task report
+func (s *TaskStarted) Validate() error {
+	if err := checkVersion(s.SchemaVersion); err != nil {
+		return err
+	}
+	switch {
+	case s.TaskID == "":
+		return fmt.Errorf("%w: task_id is required", ErrInvalidResult)
+	case !s.TaskType.valid():
+		return fmt.Errorf("%w: unknown task_type %q", ErrInvalidResult, s.TaskType)
+	case s.Source == "":
+		return fmt.Errorf("%w: source is required", ErrInvalidResult)
+	case s.URL == "":
+		return fmt.Errorf("%w: url is required", ErrInvalidResult)
+	}
+	return nil
+}
+
+// Book decodes the data of a successful book result
+func (r *TaskResult) Book() (*Book, error) {
+	if r.TaskType != TaskTypeBook {
+		return nil, fmt.Errorf("%w: %s result has no book", ErrInvalidResult, r.TaskType)
+	}
+	var book Book
+	if err := decodeStrict(r.Data, &book); err != nil {
+		return nil, fmt.Errorf("%w: book: %v", ErrInvalidResult, err)
+	}
+	if book.BookUrl == "" {
+		return nil, fmt.Errorf("%w: book has no BookUrl", ErrInvalidResult)
+	}
+	for i, c := range book.Chapters {
+		if c.ChapterUrl == "" {
+			return nil, fmt.Errorf("%w: chapter %d has no ChapterUrl", ErrInvalidResult, i)
+		}
+	}
+	return &book, nil
+}
+
+// ChapterContent decodes the data of a successful chapter result. Results written before
+// versioning may hold the plain text content as a JSON string instead.
+func (r *TaskResult) ChapterContent() (*ChapterContent, error) {
+	if r.TaskType != TaskTypeChapter {
+		return nil, fmt.Errorf("%w: %s result has no chapter content", ErrInvalidResult, r.TaskType)
+	}
+	var content ChapterContent
+	if err := decodeStrict(r.Data, &content); err != nil {
+		if r.SchemaVersion != 0 || json.Unmarshal(r.Data, &content.Content) != nil {
+			return nil, fmt.Errorf("%w: chapter content: %v", ErrInvalidResult, err)
+		}
+	}
+	switch content.ContentFormat {
+	case "", ContentFormatText, ContentFormatHTML, ContentFormatMarkdown:
+	default:
+		return nil, fmt.Errorf("%w: unknown content format %q", ErrInvalidResult, content.ContentFormat)
+	}
+	return &content, nil
+}
+
+// decodeMessage reads a message into v like decodeStrict. Its schema version is checked
+// first, so a message of a newer version is rejected as such and not for its new fields.
+func decodeMessage(r io.Reader, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidResult, err)
+	}
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidResult, err)
+	}
+	if err := checkVersion(header.SchemaVersion); err != nil {
+		return err
+	}
+	if err := decodeStrict(data, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidResult, err)
+	}
+	return nil
+}
+
+// decodeStrict decodes a JSON value into v, rejecting unknown fields and trailing data
+func decodeStrict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	// More reports false for a stray closing delimiter, so look for the end of input
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after the value")
+	}
+	return nil
+}
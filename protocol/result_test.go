@@ -0,0 +1,202 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{
+			name: "valid",
+			body: `{"schema_version":1,"task_id":"t1","task_type":"chapter","source":"stv","status":"success","url":"https://a/1","data":{"Content":"x"}}`,
+		},
+		{
+			name: "version 0",
+			body: `{"task_id":"t1","task_type":"chapter","source":"stv","status":"success","url":"https://a/1","data":"x"}`,
+		},
+		{
+			name:    "unknown version",
+			body:    `{"schema_version":3,"task_id":"t1","task_type":"chapter","source":"stv","status":"success","url":"https://a/1","data":"x"}`,
+			wantErr: ErrUnsupportedVersion,
+		},
+		{
+			name:    "unknown version with new fields",
+			body:    `{"schema_version":3,"task_id":"t1","task_type":"chapter","source":"stv","status":"success","url":"https://a/1","data":"x","priority":1}`,
+			wantErr: ErrUnsupportedVersion,
+		},
+		{
+			name:    "negative version",
+			body:    `{"schema_version":-1,"task_id":"t1","task_type":"chapter","source":"stv","status":"error","url":"https://a/1"}`,
+			wantErr: ErrUnsupportedVersion,
+		},
+		{
+			name:    "missing task ID",
+			body:    `{"schema_version":1,"task_type":"chapter","source":"stv","status":"error","url":"https://a/1"}`,
+			wantErr: ErrInvalidResult,
+		},
+		{
+			name:    "unknown task type",
+			body:    `{"schema_version":1,"task_id":"t1","task_type":"page","source":"stv","status":"error","url":"https://a/1"}`,
+			wantErr: ErrInvalidResult,
+		},
+		{
+			name:    "missing source",
+			body:    `{"schema_version":1,"task_id":"t1","task_type":"chapter","status":"error","url":"https://a/1"}`,
+			wantErr: ErrInvalidResult,
+		},
+		{
+			name:    "missing URL",
+			body:    `{"schema_version":1,"task_id":"t1","task_type":"chapter","source":"stv","status":"error"}`,
+			wantErr: ErrInvalidResult,
+		},
+		{
+			name:    "unknown status",
+			body:    `{"schema_version":1,"task_id":"t1","task_type":"chapter","source":"stv","status":"done","url":"https://a/1"}`,
+			wantErr: ErrInvalidResult,
+		},
+		{
+			name:    "success without data",
+			body:    `{"schema_version":1,"task_id":"t1","task_type":"book","source":"stv","status":"success","url":"https://a/1"}`,
+			wantErr: ErrInvalidResult,
+		},
+		{
+			name: "session success without data",
+			body: `{"schema_version":1,"task_id":"t1","task_type":"session","source":"stv","status":"success","url":"https://a/1"}`,
+		},
+		{
+			name:    "unknown field",
+			body:    `{"schema_version":1,"task_id":"t1","task_type":"chapter","source":"stv","status":"error","url":"https://a/1","extra":1}`,
+			wantErr: ErrInvalidResult,
+		},
+		{
+			name:    "trailing data",
+			body:    `{"schema_version":1,"task_id":"t1","task_type":"chapter","source":"stv","status":"error","url":"https://a/1"} {}`,
+			wantErr: ErrInvalidResult,
+		},
+		{
+			name:    "not JSON",
+			body:    `task`,
+			wantErr: ErrInvalidResult,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeResult(strings.NewReader(tt.body))
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeTaskStarted(t *testing.T) {
+	started := NewTaskStarted("t1", TaskTypeBook, SourceTypeSangTacViet, "https://a/1", "agent")
+	data, _ := json.Marshal(started)
+	got, err := DecodeTaskStarted(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.TaskID != "t1" || got.AgentID != "agent" || got.TaskType != TaskTypeBook {
+		t.Errorf("unexpected report %+v", got)
+	}
+
+	if _, err := DecodeTaskStarted(strings.NewReader(`{"schema_version":1,"task_type":"book","source":"stv","url":"u"}`)); !errors.Is(err, ErrInvalidResult) {
+		t.Errorf("got error %v for a report without task ID, want ErrInvalidResult", err)
+	}
+	if _, err := DecodeTaskStarted(strings.NewReader(`{"schema_version":3,"attempt":2}`)); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("got error %v for an unknown version, want ErrUnsupportedVersion", err)
+	}
+}
+
+func TestChapterContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		data    string
+		want    ChapterContent
+		wantErr bool
+	}{
+		{
+			name:    "structured",
+			version: 1,
+			data:    `{"Content":"text","RichContent":"<p>text</p>","ContentFormat":"html","Pages":2}`,
+			want:    ChapterContent{Content: "text", RichContent: "<p>text</p>", ContentFormat: "html", Pages: 2},
+		},
+		{
+			name:    "version 0 string",
+			version: 0,
+			data:    `"plain text"`,
+			want:    ChapterContent{Content: "plain text"},
+		},
+		{name: "string needs version 0", version: 1, data: `"plain text"`, wantErr: true},
+		{name: "unknown field", version: 1, data: `{"Content":"x","Title":"y"}`, wantErr: true},
+		{name: "unknown field in version 0", version: 0, data: `{"Content":"x","Title":"y"}`, wantErr: true},
+		{name: "trailing data", version: 1, data: `{"Content":"x"} {"Content":"y"}`, wantErr: true},
+		{name: "unknown format", version: 1, data: `{"Content":"x","RichContent":"x","ContentFormat":"rtf"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &TaskResult{SchemaVersion: tt.version, TaskType: TaskTypeChapter, Data: json.RawMessage(tt.data)}
+			got, err := r.ChapterContent()
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidResult) {
+					t.Fatalf("got error %v, want ErrInvalidResult", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != tt.want {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+
+	book := &TaskResult{SchemaVersion: 1, TaskType: TaskTypeBook, Data: json.RawMessage(`{}`)}
+	if _, err := book.ChapterContent(); !errors.Is(err, ErrInvalidResult) {
+		t.Errorf("got error %v for a book result, want ErrInvalidResult", err)
+	}
+}
+
+func TestBook(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "valid", data: `{"BookUrl":"https://a/1","Chapters":[{"ChapterUrl":"https://a/1/1","Sequence":1}]}`},
+		{name: "missing book URL", data: `{"BookName":"x"}`, wantErr: true},
+		{name: "missing chapter URL", data: `{"BookUrl":"https://a/1","Chapters":[{"ChapterName":"x"}]}`, wantErr: true},
+		{name: "unknown field", data: `{"BookUrl":"https://a/1","Rating":5}`, wantErr: true},
+		{name: "trailing data", data: `{"BookUrl":"https://a/1"}]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &TaskResult{SchemaVersion: 1, TaskType: TaskTypeBook, Data: json.RawMessage(tt.data)}
+			_, err := r.Book()
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidResult) {
+				t.Errorf("got error %v, want ErrInvalidResult", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Task represents a task published to the agents
+type Task struct {
+	SchemaVersion int `json:"schema_version"`
+	// TaskID is set by the publisher, agents report the start and result of the task under
+	// it so a redelivered task is applied once. Tasks published before it existed have none.
+	TaskID  string          `json:"task_id,omitempty"`
+	Topic   string          `json:"topic"`
+	Payload json.RawMessage `json:"payload"`
+	Source  SourceType      `json:"source"`
+}
+
+// BookTask represents a task to crawl a book
+type BookTask struct {
+	BookURL string `json:"book_url"`
+	// CoverURL is the URL of the cover the control API already stored for the book, the
+	// cover is only downloaded again when the book page shows another one
+	CoverURL string `json:"cover_url,omitempty"`
+}
+
+// ChapterTask represents a task to crawl a chapter
+type ChapterTask struct {
+	ChapterURL string `json:"chapter_url"`
+}
+
+// SessionTask represents a task to extract a session
+type SessionTask struct {
+	URL string `json:"url"`
+}
+
+// NewBookTask creates a new book task, coverURL is the stored cover of the book if any
+func NewBookTask(source SourceType, bookURL, coverURL string) Task {
+	return newTask(TaskTypeBook, source, BookTask{BookURL: bookURL, CoverURL: coverURL})
+}
+
+// NewChapterTask creates a new chapter task
+func NewChapterTask(source SourceType, chapterURL string) Task {
+	return newTask(TaskTypeChapter, source, ChapterTask{ChapterURL: chapterURL})
+}
+
+// NewSessionTask creates a new session task
+func NewSessionTask(source SourceType, url string) Task {
+	return newTask(TaskTypeSession, source, SessionTask{URL: url})
+}
+
+// newTask creates a task of the current schema version
+func newTask(taskType TaskType, source SourceType, payload any) Task {
+	data, _ := json.Marshal(payload)
+	return Task{
+		SchemaVersion: SchemaVersion,
+		Topic:         Topic(taskType, source),
+		Payload:       data,
+		Source:        source,
+	}
+}
+
+// ParseTask checks the schema version of a task and decodes its payload by the task type
+// of its topic, into a BookTask, ChapterTask or SessionTask
+func ParseTask(task Task) (any, error) {
+	if err := checkVersion(task.SchemaVersion); err != nil {
+		return nil, fmt.Errorf("task %s: %w", task.Topic, err)
+	}
+	_, taskType, err := ParseTopic(task.Topic)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload any
+	var url string
+	switch taskType {
+	case TaskTypeBook:
+		var t BookTask
+		err = decodeStrict(task.Payload, &t)
+		payload, url = t, t.BookURL
+	case TaskTypeChapter:
+		var t ChapterTask
+		err = decodeStrict(task.Payload, &t)
+		payload, url = t, t.ChapterURL
+	case TaskTypeSession:
+		var t SessionTask
+		err = decodeStrict(task.Payload, &t)
+		payload, url = t, t.URL
+	default:
+		return nil, fmt.Errorf("unknown task type: %s", taskType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s task: %w", taskType, err)
+	}
+	if url == "" {
+		return nil, fmt.Errorf("invalid %s task: missing URL", taskType)
+	}
+	return payload, nil
+}
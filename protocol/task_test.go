@@ -0,0 +1,104 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseTask(t *testing.T) {
+	tests := []struct {
+		name    string
+		task    Task
+		want    any
+		wantErr error
+	}{
+		{
+			name: "book",
+			task: NewBookTask(SourceTypeSangTacViet, "https://a/1", ""),
+			want: BookTask{BookURL: "https://a/1"},
+		},
+		{
+			name: "book with a stored cover",
+			task: NewBookTask(SourceTypeSangTacViet, "https://a/1", "https://a/cover.png"),
+			want: BookTask{BookURL: "https://a/1", CoverURL: "https://a/cover.png"},
+		},
+		{
+			name: "chapter",
+			task: NewChapterTask(SourceTypeSangTacViet, "https://a/1/2"),
+			want: ChapterTask{ChapterURL: "https://a/1/2"},
+		},
+		{
+			name: "session",
+			task: NewSessionTask(SourceTypeSangTacViet, "https://a"),
+			want: SessionTask{URL: "https://a"},
+		},
+		{
+			name: "version 0",
+			task: Task{Topic: "crawl.sangtacviet.book", Payload: json.RawMessage(`{"book_url":"https://a/1"}`)},
+			want: BookTask{BookURL: "https://a/1"},
+		},
+		{
+			name:    "unknown version",
+			task:    Task{SchemaVersion: SchemaVersion + 1, Topic: "crawl.sangtacviet.book", Payload: json.RawMessage(`{"book_url":"u"}`)},
+			wantErr: ErrUnsupportedVersion,
+		},
+		{
+			name: "invalid topic",
+			task: Task{SchemaVersion: 1, Topic: "sangtacviet.book", Payload: json.RawMessage(`{"book_url":"u"}`)},
+		},
+		{
+			name: "unknown task type",
+			task: Task{SchemaVersion: 1, Topic: "crawl.sangtacviet.page", Payload: json.RawMessage(`{"url":"u"}`)},
+		},
+		{
+			name: "missing URL",
+			task: Task{SchemaVersion: 1, Topic: "crawl.sangtacviet.chapter", Payload: json.RawMessage(`{}`)},
+		},
+		{
+			name: "unknown field",
+			task: Task{SchemaVersion: 1, Topic: "crawl.sangtacviet.chapter", Payload: json.RawMessage(`{"chapter_url":"u","book_url":"b"}`)},
+		},
+		{
+			name: "trailing data",
+			task: Task{SchemaVersion: 1, Topic: "crawl.sangtacviet.chapter", Payload: json.RawMessage(`{"chapter_url":"u"} {}`)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTask(tt.task)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTopic(t *testing.T) {
+	source, taskType, err := ParseTopic(Topic(TaskTypeChapter, SourceTypeWikiDich))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != SourceTypeWikiDich || taskType != TaskTypeChapter {
+		t.Errorf("got %s %s", source, taskType)
+	}
+
+	for _, topic := range []string{"", "crawl.wikidich", "task.wikidich.book", "crawl.wikidich.book.extra"} {
+		if _, _, err := ParseTopic(topic); err == nil {
+			t.Errorf("expected an error for topic %q", topic)
+		}
+	}
+}